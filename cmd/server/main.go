@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/SarkiMudboy/easebox-api/internal/config"
 	"github.com/SarkiMudboy/easebox-api/internal/database"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
 	"github.com/SarkiMudboy/easebox-api/internal/handler"
+	"github.com/SarkiMudboy/easebox-api/internal/mapmatch"
 	"github.com/SarkiMudboy/easebox-api/internal/repository/postgres"
 	"github.com/SarkiMudboy/easebox-api/internal/service"
 )
@@ -24,17 +32,261 @@ func main () {
 
 	sessionRepo := postgres.NewSessionRepository(db)
 	locationRepo := postgres.NewLocationRepository(db)
+	deliveryRepo := postgres.NewDeliveryRepository(db)
+	riderRepo := postgres.NewRiderRepository(db)
+	organizationRepo := postgres.NewOrganizationRepository(db)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
+	shareLinkRepo := postgres.NewShareLinkRepository(db)
+	outboxRepo := postgres.NewOutboxRepository(db)
+	transactor := postgres.NewTransactor(db)
+	auditLog := service.NewAuditLog(postgres.NewAuditRepository(db))
 
-	locationService := service.NewLocationService(locationRepo, sessionRepo)
+	webhookService := service.NewWebhookService(webhookRepo)
+	webhookDispatcher := service.NewWebhookDispatcher(webhookRepo, nil, cfg.Webhook)
+	go webhookDispatcher.Run(context.Background())
 
-	wsHandler := handler.NewWebSocketHandler(locationService)
+	// final fares are priced by the relay as sessions stop
+	pricingService := service.NewPricingService(postgres.NewPricingRepository(db), sessionRepo, locationRepo, riderRepo, deliveryRepo, cfg.Pricing)
+
+	// events are written to the outbox with the change that raised them, then relayed
+	eventBus := service.NewEventBus(postgres.NewEventChannel(db, cfg.DB.Addr))
+	go func() {
+		if err := eventBus.Run(context.Background()); err != nil {
+			log.Printf("Live events are not followed: %v", err)
+		}
+	}()
+	sinks := []service.EventSink{eventBus, service.NewWebhookSink(webhookService), pricingService}
+	if cfg.Outbox.LogEvents {
+		sinks = append(sinks, service.LogSink{})
+	}
+	outboxRelay := service.NewOutboxRelay(outboxRepo, cfg.Outbox, sinks...)
+	go outboxRelay.Run(context.Background())
+
+	// location_updates is partitioned by month, see migration 000010
+	partitionMaintainer := service.NewPartitionMaintainer(postgres.NewPartitionRepository(db), cfg.Partition)
+	go partitionMaintainer.Run(context.Background())
+
+	// raw points past an organization's retention are archived, then deleted
+	retentionRepo := postgres.NewRetentionRepository(db)
+	archiveStore, err := service.NewArchiveStore(cfg.Retention)
+	if err != nil {
+		log.Fatalf("Failed to set up archive store: %v", err)
+	}
+	retentionWorker := service.NewRetentionWorker(retentionRepo, locationRepo, transactor, archiveStore, cfg.Retention)
+	go retentionWorker.Run(context.Background())
+
+	locationService := service.NewLocationService(locationRepo, sessionRepo, deliveryRepo, transactor, service.NewOutboxPublisher(outboxRepo), auditLog, cfg.Tracking)
+
+	// cached sessions are dropped as any instance changes them
+	go func() {
+		if err := locationService.FollowSessionChanges(context.Background(), postgres.NewSessionChangeListener(cfg.DB.Addr)); err != nil {
+			log.Printf("Session changes are not followed: %v", err)
+		}
+	}()
+
+	// sessions left running by riders who went silent are stopped
+	if cfg.Tracking.StaleSessionTimeout > 0 {
+		go service.NewSessionReaper(sessionRepo, locationService, cfg.Tracking).Run(context.Background())
+	}
+
+	// location writes are batched unless INGEST_ASYNC=false
+	var pipeline *service.LocationPipeline
+	if cfg.Ingest.Async {
+		pipeline = service.NewLocationPipeline(locationRepo, outboxRepo, transactor, cfg.Ingest)
+		locationService.UsePipeline(pipeline)
+		pipeline.Start()
+	}
+
+	var matcher *mapmatch.Matcher
+	if cfg.MapMatch.GraphPath != "" {
+		graph, err := mapmatch.LoadGraph(cfg.MapMatch.GraphPath)
+		if err != nil {
+			log.Fatalf("Failed to load road graph: %v", err)
+		}
+		matcher = mapmatch.NewMatcher(graph, mapmatch.Config{
+			SearchRadius:  cfg.MapMatch.SearchRadius,
+			Sigma:         cfg.MapMatch.GPSSigma,
+			Beta:          cfg.MapMatch.Beta,
+			MaxCandidates: cfg.MapMatch.MaxCandidates,
+		})
+		log.Printf("Loaded road graph: %d nodes, %d edges", len(graph.Nodes), len(graph.Edges))
+	}
+
+	matchingService := service.NewMatchingService(locationRepo, matcher)
+
+	// offers reach riders connected to any instance
+	hub := handler.NewHub(postgres.NewRiderChannel(db, cfg.DB.Addr))
+	go func() {
+		if err := hub.Run(context.Background()); err != nil {
+			log.Printf("Rider messages are not delivered: %v", err)
+		}
+	}()
+	dispatchService := service.NewDispatchService(deliveryRepo, postgres.NewDispatchRepository(db), transactor, service.NewOutboxPublisher(outboxRepo), auditLog, hub, cfg.Dispatch)
+	// dispatches abandoned by an instance that stopped are taken over
+	go dispatchService.Run(context.Background())
+
+	riderService := service.NewRiderService(riderRepo, locationRepo)
+	organizationService := service.NewOrganizationService(organizationRepo)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	riderCredentialService := service.NewRiderCredentialService(postgres.NewRiderCredentialRepository(db))
+	ticketService := service.NewTicketService(postgres.NewTicketRepository(db))
+	fleetService := service.NewFleetService(sessionRepo, locationRepo)
+	watchService := service.NewWatchService(deliveryRepo, outboxRepo, eventBus)
+	shareService := service.NewShareService(shareLinkRepo, deliveryRepo, locationRepo, cfg.Share)
+
+	wsHandler := handler.NewWebSocketHandler(locationService, dispatchService, hub, cfg.WebSocket)
+	routeHandler := handler.NewRouteHandler(matchingService, locationService)
+	deliveryHandler := handler.NewDeliveryHandler(dispatchService)
+	riderHandler := handler.NewRiderHandler(riderService)
+	organizationHandler := handler.NewOrganizationHandler(organizationService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	credentialHandler := handler.NewCredentialHandler(riderCredentialService, ticketService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	shareHandler := handler.NewShareHandler(shareService, "./web/static/share.html")
+	replayHandler := handler.NewReplayHandler(locationService)
+	watchHandler := handler.NewWatchHandler(watchService)
+	privacyService := service.NewPrivacyService(riderRepo, sessionRepo, locationRepo, retentionRepo, postgres.NewPrivacyRepository(db), transactor, archiveStore)
+	privacyHandler := handler.NewPrivacyHandler(privacyService)
+
+	// photos and signatures captured at drop-off
+	proofStore, err := service.NewBlobStore(cfg.Proof)
+	if err != nil {
+		log.Fatalf("Failed to set up proof store: %v", err)
+	}
+	proofService := service.NewProofService(postgres.NewProofRepository(db), deliveryRepo, sessionRepo, locationRepo, transactor, auditLog, proofStore, cfg.Proof)
+	proofHandler := handler.NewProofHandler(proofService, cfg.Proof.MaxUploadBytes)
+	pricingHandler := handler.NewPricingHandler(pricingService)
+	retentionHandler := handler.NewRetentionHandler(service.NewRetentionService(retentionRepo))
+	partitionHandler := handler.NewPartitionHandler(partitionMaintainer)
+	auditHandler := handler.NewAuditHandler(auditLog)
+	fleetHandler := handler.NewFleetHandler(fleetService, eventBus, "./web/static/dashboard.html")
+
+	// scoped wraps operator routes, which name their organization in the path
+	tenantMiddleware := handler.NewTenantMiddleware(organizationService)
+	scoped := func(h http.HandlerFunc) http.Handler {
+		return tenantMiddleware.Wrap(h)
+	}
+
+	// withKey wraps integration routes, which authenticate with an organization's api key
+	apiKeyMiddleware := handler.NewAPIKeyMiddleware(apiKeyService, ticketService)
+	withKey := func(scope string, h http.HandlerFunc) http.Handler {
+		return apiKeyMiddleware.Require(scope, h)
+	}
+
+	// asRider wraps rider facing routes, which authenticate with the rider's
+	// credential or an api key holding the scope
+	riderMiddleware := handler.NewRiderMiddleware(riderCredentialService, ticketService, apiKeyMiddleware)
+	asRider := func(scope string, h http.HandlerFunc) http.Handler {
+		return riderMiddleware.Require(scope, h)
+	}
+
+	// admin wraps operator routes
+	adminMiddleware := handler.NewAdminMiddleware(cfg.App.AdminToken)
+	admin := func(h http.Handler) http.Handler {
+		return adminMiddleware.Wrap(h)
+	}
+
+	http.Handle("/track", asRider(domain.ScopeWriteTracking, wsHandler.HandleConnection))
+	http.Handle("POST /track/tickets", asRider(domain.ScopeWriteTracking, credentialHandler.HandleTicket(domain.ScopeWriteTracking)))
+	http.Handle("PUT /riders/{riderID}/availability", asRider(domain.ScopeWriteRiders, riderHandler.HandleSetAvailability))
+	http.Handle("POST /riders/{riderID}/shift/start", asRider(domain.ScopeWriteRiders, riderHandler.HandleStartShift))
+	http.Handle("POST /riders/{riderID}/shift/end", asRider(domain.ScopeWriteRiders, riderHandler.HandleEndShift))
+	http.Handle("POST /deliveries/{deliveryID}/proofs", asRider(domain.ScopeWriteDeliveries, proofHandler.HandleUpload))
+	http.Handle("POST /deliveries/{deliveryID}/proofs/pin", asRider(domain.ScopeWriteDeliveries, proofHandler.HandleVerifyPIN))
+
+	http.Handle("GET /sessions/{sessionID}/route", withKey(domain.ScopeReadTracking, routeHandler.HandleRoute))
+	http.Handle("GET /sessions/{sessionID}/matched-route", withKey(domain.ScopeReadTracking, routeHandler.HandleMatchedRoute))
+	http.Handle("GET /sessions/{sessionID}/stats", withKey(domain.ScopeReadTracking, routeHandler.HandleStats))
+	http.Handle("GET /sessions/{sessionID}/fare", withKey(domain.ScopeReadTracking, pricingHandler.HandleSessionFare))
+	http.Handle("GET /sessions/{sessionID}/summary", withKey(domain.ScopeReadTracking, retentionHandler.HandleSessionSummary))
+	http.Handle("GET /sessions/{sessionID}/replay", withKey(domain.ScopeReadTracking, replayHandler.HandleReplay))
+	http.Handle("GET /fleet/sessions", withKey(domain.ScopeReadTracking, fleetHandler.HandleListSessions))
+	http.Handle("GET /fleet/positions", withKey(domain.ScopeReadTracking, fleetHandler.HandlePositions))
+	http.Handle("GET /fleet/live", withKey(domain.ScopeReadTracking, fleetHandler.HandleLive))
+	http.Handle("POST /fleet/tickets", withKey(domain.ScopeReadTracking, credentialHandler.HandleTicket(domain.ScopeReadTracking)))
+	http.Handle("POST /deliveries", withKey(domain.ScopeWriteDeliveries, deliveryHandler.HandleCreate))
+	http.Handle("POST /fares/quote", withKey(domain.ScopeReadDeliveries, pricingHandler.HandleQuote))
+	http.Handle("GET /deliveries/{deliveryID}", withKey(domain.ScopeReadDeliveries, deliveryHandler.HandleGet))
+	http.Handle("POST /deliveries/{deliveryID}/dispatch", withKey(domain.ScopeWriteDeliveries, deliveryHandler.HandleDispatch))
+	http.Handle("GET /deliveries/{deliveryID}/watch", withKey(domain.ScopeReadDeliveries, watchHandler.HandleWebSocket))
+	http.Handle("GET /deliveries/{deliveryID}/events", withKey(domain.ScopeReadDeliveries, watchHandler.HandleEvents))
+	http.Handle("POST /deliveries/tickets", withKey(domain.ScopeReadDeliveries, credentialHandler.HandleTicket(domain.ScopeReadDeliveries)))
+	http.Handle("POST /deliveries/{deliveryID}/share-links", withKey(domain.ScopeWriteDeliveries, shareHandler.HandleCreate))
+	http.Handle("GET /deliveries/{deliveryID}/share-links", withKey(domain.ScopeReadDeliveries, shareHandler.HandleList))
+	http.Handle("DELETE /deliveries/{deliveryID}/share-links/{linkID}", withKey(domain.ScopeWriteDeliveries, shareHandler.HandleRevoke))
+	http.Handle("POST /deliveries/{deliveryID}/pin", withKey(domain.ScopeWriteDeliveries, proofHandler.HandleIssuePIN))
+	http.Handle("GET /deliveries/{deliveryID}/proofs", withKey(domain.ScopeReadDeliveries, proofHandler.HandleList))
+	http.Handle("GET /deliveries/{deliveryID}/proofs/{proofID}/content", withKey(domain.ScopeReadDeliveries, proofHandler.HandleContent))
+	http.Handle("POST /riders", withKey(domain.ScopeWriteRiders, riderHandler.HandleCreate))
+	http.Handle("GET /riders/{riderID}", withKey(domain.ScopeReadRiders, riderHandler.HandleGet))
+	http.Handle("POST /riders/{riderID}/credentials", withKey(domain.ScopeWriteRiders, credentialHandler.HandleIssue))
+	http.Handle("GET /riders/{riderID}/credentials", withKey(domain.ScopeReadRiders, credentialHandler.HandleList))
+	http.Handle("DELETE /riders/{riderID}/credentials/{credentialID}", withKey(domain.ScopeWriteRiders, credentialHandler.HandleRevoke))
+	http.Handle("GET /riders/{riderID}/locations", withKey(domain.ScopeReadTracking, riderHandler.HandleLocations))
+	http.Handle("GET /audit", withKey(domain.ScopeReadAudit, auditHandler.HandleList))
+	http.Handle("POST /webhooks", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleCreate))
+	http.Handle("GET /webhooks", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleList))
+	http.Handle("DELETE /webhooks/{endpointID}", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleDelete))
+	http.Handle("GET /webhooks/{endpointID}/deliveries", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleListDeliveries))
+	http.Handle("GET /webhooks/deliveries/{deliveryID}/attempts", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleListAttempts))
+	http.Handle("POST /webhooks/deliveries/{deliveryID}/retry", withKey(domain.ScopeManageWebhooks, webhookHandler.HandleRetry))
+
+	http.Handle("POST /organizations", admin(http.HandlerFunc(organizationHandler.HandleCreate)))
+	http.Handle("GET /organizations/{organizationID}", admin(http.HandlerFunc(organizationHandler.HandleGet)))
+	http.Handle("POST /organizations/{organizationID}/api-keys", admin(scoped(apiKeyHandler.HandleCreate)))
+	http.Handle("GET /organizations/{organizationID}/api-keys", admin(scoped(apiKeyHandler.HandleList)))
+	http.Handle("POST /organizations/{organizationID}/api-keys/{keyID}/rotate", admin(scoped(apiKeyHandler.HandleRotate)))
+	http.Handle("DELETE /organizations/{organizationID}/api-keys/{keyID}", admin(scoped(apiKeyHandler.HandleRevoke)))
+	http.Handle("GET /organizations/{organizationID}/retention", admin(scoped(retentionHandler.HandleGetPolicy)))
+	http.Handle("PUT /organizations/{organizationID}/retention", admin(scoped(retentionHandler.HandleSetPolicy)))
+	http.Handle("DELETE /organizations/{organizationID}/retention", admin(scoped(retentionHandler.HandleDeletePolicy)))
+	http.Handle("GET /organizations/{organizationID}/riders/{riderID}/export", admin(scoped(privacyHandler.HandleExport)))
+	http.Handle("POST /organizations/{organizationID}/riders/{riderID}/erasure", admin(scoped(privacyHandler.HandleErase)))
+	http.Handle("GET /organizations/{organizationID}/riders/{riderID}/privacy-requests", admin(scoped(privacyHandler.HandleListRequests)))
+	http.Handle("GET /organizations/{organizationID}/rate-cards", admin(scoped(pricingHandler.HandleListRateCards)))
+	http.Handle("PUT /organizations/{organizationID}/rate-cards/{vehicleType}", admin(scoped(pricingHandler.HandleSetRateCard)))
+	http.Handle("DELETE /organizations/{organizationID}/rate-cards/{vehicleType}", admin(scoped(pricingHandler.HandleDeleteRateCard)))
+	http.Handle("GET /organizations/{organizationID}/surge-zones", admin(scoped(pricingHandler.HandleListSurgeZones)))
+	http.Handle("POST /organizations/{organizationID}/surge-zones", admin(scoped(pricingHandler.HandleCreateSurgeZone)))
+	http.Handle("DELETE /organizations/{organizationID}/surge-zones/{zoneID}", admin(scoped(pricingHandler.HandleDeleteSurgeZone)))
+	http.Handle("GET /organizations/{organizationID}/audit", admin(scoped(auditHandler.HandleList)))
+	http.Handle("GET /admin/partitions", admin(http.HandlerFunc(partitionHandler.HandleStatus)))
+	http.Handle("POST /admin/partitions/maintain", admin(http.HandlerFunc(partitionHandler.HandleMaintain)))
+	http.HandleFunc("GET /dashboard", fleetHandler.HandlePage)
+
+	// public, the share token is the only credential
+	http.HandleFunc("GET /t/{token}", shareHandler.HandlePage)
+	http.HandleFunc("GET /shared/{token}", shareHandler.HandleTracking)
 
-	http.HandleFunc("/track", wsHandler.HandleConnection)
 	http.Handle("/", http.FileServer(http.Dir("./web/static")))
 
-	log.Printf("Server starting on %s:%s", cfg.App.ServerAddress, cfg.App.Port)
-	if err := http.ListenAndServe(":" + cfg.App.Port, nil); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	server := &http.Server{Addr: ":" + cfg.App.Port}
+
+	go func() {
+		log.Printf("Server starting on %s:%s", cfg.App.ServerAddress, cfg.App.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Printf("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+
+	// flush whatever locations are still queued before the database closes
+	if pipeline != nil {
+		if err := pipeline.Close(ctx); err != nil {
+			log.Printf("Location pipeline did not drain: %v", err)
+		}
 	}
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,24 @@
+package actor
+
+import (
+	"context"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+)
+
+type contextKey struct{}
+
+// With returns a copy of ctx carrying the actor a request is made by
+func With(ctx context.Context, actor domain.Actor) context.Context {
+	return context.WithValue(ctx, contextKey{}, actor)
+}
+
+// FromContext returns the actor of the context. Work started by the server
+// itself, such as background jobs, is attributed to the system.
+func FromContext(ctx context.Context) domain.Actor {
+	if actor, ok := ctx.Value(contextKey{}).(domain.Actor); ok {
+		return actor
+	}
+	return domain.Actor{Type: domain.ActorSystem}
+}
+
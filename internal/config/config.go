@@ -5,23 +5,52 @@ import "github.com/SarkiMudboy/easebox-api/pkg/env"
 type AppConfig struct {
 	Port string
 	ServerAddress string
+	// bearer token for operator endpoints, they are disabled when empty
+	AdminToken string
 }
 
 func loadAppConfig() *AppConfig {
 	return &AppConfig{
 		Port: env.GetString("PORT", "8080"),
 		ServerAddress: env.GetString("BASE_URL", "http://localhost"),
+		AdminToken: env.GetString("ADMIN_API_TOKEN", ""),
 	}
 }
 
 type Config struct {
-	App *AppConfig
-	DB  *DBConfig
+	App      *AppConfig
+	DB       *DBConfig
+	MapMatch *MapMatchConfig
+	Dispatch *DispatchConfig
+	Webhook  *WebhookConfig
+	Tracking *TrackingConfig
+	Outbox   *OutboxConfig
+	Share    *ShareConfig
+	WebSocket *WebSocketConfig
+	Ingest   *IngestConfig
+	Partition *PartitionConfig
+	Retention *RetentionConfig
+	Proof    *ProofConfig
+	Pricing  *PricingConfig
 }
 
 func Load() *Config {
+	app := loadAppConfig()
+
 	return &Config{
-		App: loadAppConfig(),
+		App: app,
 		DB: loadDBConfig(),
+		MapMatch: loadMapMatchConfig(),
+		Dispatch: loadDispatchConfig(),
+		Webhook: loadWebhookConfig(),
+		Tracking: loadTrackingConfig(),
+		Outbox: loadOutboxConfig(),
+		Share: loadShareConfig(app),
+		WebSocket: loadWebSocketConfig(),
+		Ingest: loadIngestConfig(),
+		Partition: loadPartitionConfig(),
+		Retention: loadRetentionConfig(),
+		Proof: loadProofConfig(),
+		Pricing: loadPricingConfig(),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type DispatchConfig struct {
+	// radius (meters) around the pickup in which riders are considered
+	SearchRadius float64
+	// how long a rider has to accept or decline an offer
+	OfferTimeout time.Duration
+	// riders with this many active deliveries are not offered new ones
+	MaxLoad int
+	// extra distance (meters) added to a rider's rank per active delivery
+	LoadPenalty float64
+	// max riders offered a job before it is marked unassigned
+	MaxCandidates int
+	// how often deliveries whose dispatch was abandoned are claimed again
+	ReclaimInterval time.Duration
+}
+
+func loadDispatchConfig() *DispatchConfig {
+	return &DispatchConfig{
+		SearchRadius:    env.GetFloat("DISPATCH_SEARCH_RADIUS", 5000),
+		OfferTimeout:    time.Duration(env.GetInt("DISPATCH_OFFER_TIMEOUT", 30)) * time.Second,
+		MaxLoad:         env.GetInt("DISPATCH_MAX_LOAD", 3),
+		LoadPenalty:     env.GetFloat("DISPATCH_LOAD_PENALTY", 1000),
+		MaxCandidates:   env.GetInt("DISPATCH_MAX_CANDIDATES", 5),
+		ReclaimInterval: time.Duration(max(1, env.GetInt("DISPATCH_RECLAIM_INTERVAL", 60))) * time.Second,
+	}
+}
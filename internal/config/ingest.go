@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+// a location row binds 9 parameters in one insert, postgres allows 65535
+const maxIngestBatchSize = 65535 / 9
+
+type IngestConfig struct {
+	// when false locations are written synchronously, one insert per point
+	Async bool
+	// points of one session always go to the same shard, keeping their order
+	Shards    int
+	QueueSize int
+	// a shard flushes when it holds BatchSize points or FlushInterval passed
+	BatchSize     int
+	FlushInterval time.Duration
+	// a failed flush is retried until it succeeds, backing off up to the max
+	FlushBaseBackoff time.Duration
+	FlushMaxBackoff  time.Duration
+	// how long a client waits for room in a full queue before being told to back off
+	EnqueueTimeout time.Duration
+}
+
+func loadIngestConfig() *IngestConfig {
+	return &IngestConfig{
+		Async:            env.GetString("INGEST_ASYNC", "true") == "true",
+		Shards:           max(1, env.GetInt("INGEST_SHARDS", 4)),
+		QueueSize:        env.GetInt("INGEST_QUEUE_SIZE", 2048),
+		BatchSize:        min(max(1, env.GetInt("INGEST_BATCH_SIZE", 500)), maxIngestBatchSize),
+		FlushInterval:    time.Duration(max(1, env.GetInt("INGEST_FLUSH_INTERVAL_MS", 200))) * time.Millisecond,
+		FlushBaseBackoff: time.Duration(max(1, env.GetInt("INGEST_FLUSH_BASE_BACKOFF_MS", 100))) * time.Millisecond,
+		FlushMaxBackoff:  time.Duration(max(1, env.GetInt("INGEST_FLUSH_MAX_BACKOFF_MS", 5000))) * time.Millisecond,
+		EnqueueTimeout:   time.Duration(env.GetInt("INGEST_ENQUEUE_TIMEOUT_MS", 100)) * time.Millisecond,
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "github.com/SarkiMudboy/easebox-api/pkg/env"
+
+type MapMatchConfig struct {
+	// path to the pre-processed OSM road graph, matching is disabled when empty
+	GraphPath     string
+	SearchRadius  float64
+	GPSSigma      float64
+	Beta          float64
+	MaxCandidates int
+}
+
+func loadMapMatchConfig() *MapMatchConfig {
+	return &MapMatchConfig{
+		GraphPath:     env.GetString("MAP_GRAPH_PATH", ""),
+		SearchRadius:  env.GetFloat("MAP_MATCH_SEARCH_RADIUS", 50),
+		GPSSigma:      env.GetFloat("MAP_MATCH_GPS_SIGMA", 10),
+		Beta:          env.GetFloat("MAP_MATCH_BETA", 5),
+		MaxCandidates: env.GetInt("MAP_MATCH_MAX_CANDIDATES", 8),
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// delay before republishing an event a sink failed on, doubled on every further attempt
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// sinks still failing after this many attempts get a dead letter
+	MaxAttempts int
+	// published events are kept this long before being pruned
+	Retention time.Duration
+	// write every relayed event to the log, noisy since it includes locations
+	LogEvents bool
+}
+
+func loadOutboxConfig() *OutboxConfig {
+	return &OutboxConfig{
+		PollInterval: time.Duration(env.GetInt("OUTBOX_POLL_INTERVAL", 1)) * time.Second,
+		BatchSize:    env.GetInt("OUTBOX_BATCH_SIZE", 100),
+		BaseBackoff:  time.Duration(env.GetInt("OUTBOX_BASE_BACKOFF", 5)) * time.Second,
+		MaxBackoff:   time.Duration(env.GetInt("OUTBOX_MAX_BACKOFF", 600)) * time.Second,
+		MaxAttempts:  max(env.GetInt("OUTBOX_MAX_ATTEMPTS", 12), 1),
+		Retention:    time.Duration(env.GetInt("OUTBOX_RETENTION_HOURS", 72)) * time.Hour,
+		LogEvents:    env.GetString("OUTBOX_LOG_EVENTS", "false") == "true",
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type PartitionConfig struct {
+	CheckInterval time.Duration
+	// monthly location partitions are created this many months ahead
+	PremakeMonths int
+	// partitions whose month ended more than this many months ago are
+	// detached, 0 keeps every partition attached
+	RetainMonths int
+}
+
+func loadPartitionConfig() *PartitionConfig {
+	return &PartitionConfig{
+		CheckInterval: time.Duration(max(1, env.GetInt("PARTITION_CHECK_INTERVAL_MINUTES", 60))) * time.Minute,
+		PremakeMonths: max(0, env.GetInt("PARTITION_PREMAKE_MONTHS", 3)),
+		RetainMonths:  max(0, env.GetInt("PARTITION_RETAIN_MONTHS", 0)),
+	}
+}
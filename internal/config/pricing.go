@@ -0,0 +1,23 @@
+package config
+
+import (
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type PricingConfig struct {
+	// quotes only know the straight line between pickup and drop-off, it is
+	// multiplied by RouteFactor to approximate the road distance
+	RouteFactor float64
+	// meters per second used to estimate a quote's duration
+	AverageSpeed float64
+	// priced when a session has no rider or the rider has no vehicle type
+	DefaultVehicle string
+}
+
+func loadPricingConfig() *PricingConfig {
+	return &PricingConfig{
+		RouteFactor:    max(env.GetFloat("PRICING_ROUTE_FACTOR", 1.3), 1),
+		AverageSpeed:   env.GetFloat("PRICING_AVERAGE_SPEED", 6),
+		DefaultVehicle: env.GetString("PRICING_DEFAULT_VEHICLE", "motorcycle"),
+	}
+}
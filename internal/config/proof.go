@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type ProofConfig struct {
+	// largest photo or signature accepted, in bytes
+	MaxUploadBytes int64
+
+	// recipient PINs expire after PINTTL and are locked after PINMaxAttempts wrong guesses
+	PINLength      int
+	PINTTL         time.Duration
+	PINMaxAttempts int
+	// keys the PIN hashes, PINs cannot be issued or verified without it
+	PINSecret string
+
+	// "local" keeps files below LocalDir, "s3" uploads them to the bucket
+	Store    string
+	LocalDir string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+func loadProofConfig() *ProofConfig {
+	return &ProofConfig{
+		MaxUploadBytes: int64(env.GetInt("PROOF_MAX_UPLOAD_KB", 5120)) * 1024,
+		PINLength:      min(max(env.GetInt("PROOF_PIN_LENGTH", 6), 4), 10),
+		PINTTL:         time.Duration(env.GetInt("PROOF_PIN_TTL_HOURS", 24)) * time.Hour,
+		PINMaxAttempts: max(env.GetInt("PROOF_PIN_MAX_ATTEMPTS", 5), 1),
+		PINSecret:      env.GetString("PROOF_PIN_SECRET", ""),
+		Store:          env.GetString("PROOF_STORE", "local"),
+		LocalDir:       env.GetString("PROOF_DIR", "./data/proofs"),
+		S3Endpoint:     env.GetString("PROOF_S3_ENDPOINT", "http://localhost:9000"),
+		S3Region:       env.GetString("PROOF_S3_REGION", "us-east-1"),
+		S3Bucket:       env.GetString("PROOF_S3_BUCKET", "easebox-proofs"),
+		S3AccessKey:    env.GetString("PROOF_S3_ACCESS_KEY", ""),
+		S3SecretKey:    env.GetString("PROOF_S3_SECRET_KEY", ""),
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"log"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type RetentionConfig struct {
+	Interval time.Duration
+	// sessions archived per query, a pass continues until none are left
+	BatchSize int
+	// tolerance (meters) used to simplify the route kept in a session summary
+	RouteTolerance float64
+
+	// "local" writes archives below LocalDir, "s3" uploads them to the bucket
+	Store    string
+	LocalDir string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+func loadRetentionConfig() *RetentionConfig {
+	// a pass stops on a short batch, one that is never full would loop forever
+	batchSize := env.GetInt("RETENTION_BATCH_SIZE", 50)
+	if batchSize <= 0 {
+		log.Fatalf("RETENTION_BATCH_SIZE must be positive, got %d", batchSize)
+	}
+
+	return &RetentionConfig{
+		Interval:       time.Duration(max(1, env.GetInt("RETENTION_INTERVAL_MINUTES", 60))) * time.Minute,
+		BatchSize:      batchSize,
+		RouteTolerance: max(0, env.GetFloat("RETENTION_ROUTE_TOLERANCE", 10)),
+		Store:          env.GetString("ARCHIVE_STORE", "local"),
+		LocalDir:       env.GetString("ARCHIVE_DIR", "./data/archive"),
+		S3Endpoint:     env.GetString("ARCHIVE_S3_ENDPOINT", "http://localhost:9000"),
+		S3Region:       env.GetString("ARCHIVE_S3_REGION", "us-east-1"),
+		S3Bucket:       env.GetString("ARCHIVE_S3_BUCKET", "easebox-archive"),
+		S3AccessKey:    env.GetString("ARCHIVE_S3_ACCESS_KEY", ""),
+		S3SecretKey:    env.GetString("ARCHIVE_S3_SECRET_KEY", ""),
+	}
+}
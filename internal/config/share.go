@@ -0,0 +1,24 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type ShareConfig struct {
+	// lifetime of a share link unless the request asks for less
+	LinkTTL time.Duration
+	// public base url links point at, defaults to the server address
+	BaseURL string
+	// meters per second assumed for the ETA when the rider's speed is unknown
+	AverageSpeed float64
+}
+
+func loadShareConfig(app *AppConfig) *ShareConfig {
+	return &ShareConfig{
+		LinkTTL:      time.Duration(env.GetInt("SHARE_LINK_TTL_HOURS", 24)) * time.Hour,
+		BaseURL:      env.GetString("SHARE_BASE_URL", app.ServerAddress+":"+app.Port),
+		AverageSpeed: env.GetFloat("SHARE_AVERAGE_SPEED", 6),
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type TrackingConfig struct {
+	// distance (meters) from a pickup or drop-off at which a rider counts as arrived
+	ArrivalRadius float64
+	// how long session state is reused between location points before it is reloaded
+	SessionCacheTTL  time.Duration
+	SessionCacheSize int
+	// active sessions that have not sent a point for this long are stopped,
+	// 0 leaves them running
+	StaleSessionTimeout time.Duration
+	ReapInterval        time.Duration
+}
+
+func loadTrackingConfig() *TrackingConfig {
+	return &TrackingConfig{
+		ArrivalRadius:       env.GetFloat("TRACKING_ARRIVAL_RADIUS", 100),
+		SessionCacheTTL:     time.Duration(env.GetInt("TRACKING_SESSION_CACHE_TTL_SECONDS", 30)) * time.Second,
+		SessionCacheSize:    env.GetInt("TRACKING_SESSION_CACHE_SIZE", 10000),
+		StaleSessionTimeout: time.Duration(max(0, env.GetInt("TRACKING_STALE_SESSION_MINUTES", 60))) * time.Minute,
+		ReapInterval:        time.Duration(max(1, env.GetInt("TRACKING_REAP_INTERVAL_MINUTES", 5))) * time.Minute,
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/env"
+)
+
+type WebhookConfig struct {
+	// deliveries are dead lettered after this many failed attempts
+	MaxAttempts int
+	// delay before the first retry, doubled on every further attempt
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// timeout of a single HTTP call to a receiver
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func loadWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		MaxAttempts:  env.GetInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff:  time.Duration(env.GetInt("WEBHOOK_BASE_BACKOFF", 10)) * time.Second,
+		MaxBackoff:   time.Duration(env.GetInt("WEBHOOK_MAX_BACKOFF", 3600)) * time.Second,
+		Timeout:      time.Duration(env.GetInt("WEBHOOK_TIMEOUT", 10)) * time.Second,
+		PollInterval: time.Duration(env.GetInt("WEBHOOK_POLL_INTERVAL", 2)) * time.Second,
+		BatchSize:    env.GetInt("WEBHOOK_BATCH_SIZE", 20),
+	}
+}
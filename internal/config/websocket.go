@@ -0,0 +1,28 @@
+package config
+
+import "github.com/SarkiMudboy/easebox-api/pkg/env"
+
+type WebSocketConfig struct {
+	// frames larger than this close the connection
+	MaxFrameBytes int64
+	// messages per second a single connection may send, with bursts up to ConnBurst
+	ConnRate  float64
+	ConnBurst int
+	// location updates per second a session may record across all its
+	// connections, with bursts up to SessionBurst
+	SessionRate  float64
+	SessionBurst int
+	// consecutive throttled messages after which the connection is closed
+	MaxThrottled int
+}
+
+func loadWebSocketConfig() *WebSocketConfig {
+	return &WebSocketConfig{
+		MaxFrameBytes: int64(env.GetInt("WS_MAX_FRAME_BYTES", 16*1024)),
+		ConnRate:      env.GetFloat("WS_CONN_RATE", 10),
+		ConnBurst:     env.GetInt("WS_CONN_BURST", 20),
+		SessionRate:   env.GetFloat("WS_SESSION_RATE", 2),
+		SessionBurst:  env.GetInt("WS_SESSION_BURST", 10),
+		MaxThrottled:  env.GetInt("WS_MAX_THROTTLED", 100),
+	}
+}
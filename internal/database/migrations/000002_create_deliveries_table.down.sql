@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trigger_update_deliveries_updated_at ON deliveries;
+
+DROP TABLE IF EXISTS deliveries CASCADE;
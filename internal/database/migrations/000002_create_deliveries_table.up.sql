@@ -0,0 +1,32 @@
+-- ============================================
+-- Deliveries Table
+-- ============================================
+-- Stores delivery jobs and the rider (session) they are assigned to
+CREATE TABLE deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+
+    pickup GEOGRAPHY(POINT, 4326) NOT NULL,
+    dropoff GEOGRAPHY(POINT, 4326) NOT NULL,
+
+    -- pending, dispatching, assigned, in_transit, delivered, cancelled, unassigned
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    assigned_session_id VARCHAR(255),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Indices for deliveries table
+CREATE INDEX idx_deliveries_status
+    ON deliveries(status);
+
+-- Used to compute rider load when ranking dispatch candidates
+CREATE INDEX idx_deliveries_assigned_session
+    ON deliveries(assigned_session_id, status)
+    WHERE assigned_session_id IS NOT NULL;
+
+-- Trigger to auto-update updated_at on deliveries
+CREATE TRIGGER trigger_update_deliveries_updated_at
+    BEFORE UPDATE ON deliveries
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
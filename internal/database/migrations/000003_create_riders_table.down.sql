@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tracking_sessions_rider_start;
+ALTER TABLE tracking_sessions DROP COLUMN IF EXISTS rider_id;
+
+DROP TRIGGER IF EXISTS trigger_update_riders_updated_at ON riders;
+
+DROP TABLE IF EXISTS riders CASCADE;
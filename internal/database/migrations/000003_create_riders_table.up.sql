@@ -0,0 +1,45 @@
+-- ============================================
+-- Riders Table
+-- ============================================
+-- Stores rider profiles, their availability and current shift
+CREATE TABLE riders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL UNIQUE,
+    email VARCHAR(255),
+
+    -- bicycle, motorcycle, car, van
+    vehicle_type VARCHAR(32) NOT NULL,
+    vehicle_plate VARCHAR(32),
+
+    -- offline, available, busy, on_break
+    availability VARCHAR(32) NOT NULL DEFAULT 'offline',
+    shift_start TIMESTAMPTZ,
+    shift_end TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT check_shift_end_after_start CHECK (shift_end IS NULL OR shift_start IS NULL OR shift_end >= shift_start)
+);
+
+CREATE INDEX idx_riders_availability
+    ON riders(availability);
+
+-- Trigger to auto-update updated_at on riders
+CREATE TRIGGER trigger_update_riders_updated_at
+    BEFORE UPDATE ON riders
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================
+-- Link tracking sessions to riders
+-- ============================================
+-- Nullable so sessions started by older rider apps keep working
+ALTER TABLE tracking_sessions
+    ADD COLUMN rider_id UUID REFERENCES riders(id) ON DELETE SET NULL;
+
+-- "where has rider X been today?"
+CREATE INDEX idx_tracking_sessions_rider_start
+    ON tracking_sessions(rider_id, start_time DESC)
+    WHERE rider_id IS NOT NULL;
@@ -0,0 +1,25 @@
+DROP POLICY IF EXISTS tenant_isolation_riders ON riders;
+DROP POLICY IF EXISTS tenant_isolation_deliveries ON deliveries;
+DROP POLICY IF EXISTS tenant_isolation_location_updates ON location_updates;
+DROP POLICY IF EXISTS tenant_isolation_tracking_sessions ON tracking_sessions;
+
+ALTER TABLE riders DISABLE ROW LEVEL SECURITY;
+ALTER TABLE deliveries DISABLE ROW LEVEL SECURITY;
+ALTER TABLE location_updates DISABLE ROW LEVEL SECURITY;
+ALTER TABLE tracking_sessions DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE tracking_sessions DROP CONSTRAINT IF EXISTS fk_tracking_sessions_rider_organization;
+ALTER TABLE tracking_sessions ADD CONSTRAINT tracking_sessions_rider_id_fkey
+    FOREIGN KEY (rider_id) REFERENCES riders(id) ON DELETE SET NULL;
+ALTER TABLE riders DROP CONSTRAINT IF EXISTS riders_id_organization_key;
+
+ALTER TABLE riders DROP CONSTRAINT IF EXISTS riders_organization_phone_key;
+ALTER TABLE riders ADD CONSTRAINT riders_phone_key UNIQUE (phone);
+
+ALTER TABLE riders DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE location_updates DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE tracking_sessions DROP COLUMN IF EXISTS organization_id;
+
+DROP TRIGGER IF EXISTS trigger_update_organizations_updated_at ON organizations;
+DROP TABLE IF EXISTS organizations CASCADE;
@@ -0,0 +1,78 @@
+-- ============================================
+-- Organizations Table
+-- ============================================
+-- Business customers (tenants). Every tenant scoped row references one.
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trigger_update_organizations_updated_at
+    BEFORE UPDATE ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Existing rows are moved to a default organization before the columns become mandatory
+INSERT INTO organizations (id, name)
+    VALUES ('00000000-0000-0000-0000-000000000001', 'Default organization');
+
+-- ============================================
+-- Tenant columns
+-- ============================================
+ALTER TABLE tracking_sessions ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE location_updates ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE deliveries ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE riders ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+UPDATE tracking_sessions SET organization_id = '00000000-0000-0000-0000-000000000001';
+UPDATE location_updates SET organization_id = '00000000-0000-0000-0000-000000000001';
+UPDATE deliveries SET organization_id = '00000000-0000-0000-0000-000000000001';
+UPDATE riders SET organization_id = '00000000-0000-0000-0000-000000000001';
+
+ALTER TABLE tracking_sessions ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE location_updates ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE deliveries ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE riders ALTER COLUMN organization_id SET NOT NULL;
+
+-- Rider phone numbers are unique per organization, not globally
+ALTER TABLE riders DROP CONSTRAINT riders_phone_key;
+ALTER TABLE riders ADD CONSTRAINT riders_organization_phone_key UNIQUE (organization_id, phone);
+
+-- Sessions may only reference riders of the same organization
+ALTER TABLE riders ADD CONSTRAINT riders_id_organization_key UNIQUE (id, organization_id);
+ALTER TABLE tracking_sessions DROP CONSTRAINT tracking_sessions_rider_id_fkey;
+ALTER TABLE tracking_sessions ADD CONSTRAINT fk_tracking_sessions_rider_organization
+    FOREIGN KEY (rider_id, organization_id) REFERENCES riders(id, organization_id);
+
+-- Indices for tenant scoped queries
+CREATE INDEX idx_tracking_sessions_organization
+    ON tracking_sessions(organization_id, start_time DESC);
+CREATE INDEX idx_location_updates_organization_session
+    ON location_updates(organization_id, session_id, recorded_at DESC);
+CREATE INDEX idx_deliveries_organization_status
+    ON deliveries(organization_id, status);
+CREATE INDEX idx_riders_organization
+    ON riders(organization_id);
+
+-- ============================================
+-- Row level security
+-- ============================================
+-- The API filters every query by organization in the repositories. These policies
+-- are a second line of defence for any role that is not the table owner
+-- (reporting users, ad-hoc access): such sessions only see rows of the
+-- organization set with SET app.current_organization = '<uuid>'.
+ALTER TABLE tracking_sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE location_updates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE riders ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_tracking_sessions ON tracking_sessions
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+CREATE POLICY tenant_isolation_location_updates ON location_updates
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+CREATE POLICY tenant_isolation_deliveries ON deliveries
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+CREATE POLICY tenant_isolation_riders ON riders
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
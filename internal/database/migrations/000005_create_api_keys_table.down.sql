@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trigger_update_api_keys_updated_at ON api_keys;
+
+DROP TABLE IF EXISTS api_keys CASCADE;
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS webhook_dead_letters CASCADE;
+DROP TABLE IF EXISTS webhook_attempts CASCADE;
+
+DROP TRIGGER IF EXISTS trigger_update_webhook_deliveries_updated_at ON webhook_deliveries;
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+
+DROP TRIGGER IF EXISTS trigger_update_webhook_endpoints_updated_at ON webhook_endpoints;
+DROP TABLE IF EXISTS webhook_endpoints CASCADE;
+
+ALTER TABLE deliveries DROP COLUMN IF EXISTS delivered_at;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS arrived_at_dropoff_at;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS picked_up_at;
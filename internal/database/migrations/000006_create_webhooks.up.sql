@@ -0,0 +1,104 @@
+-- ============================================
+-- Delivery progress
+-- ============================================
+-- Set from the rider's position, used to raise arrival and completion events
+ALTER TABLE deliveries ADD COLUMN picked_up_at TIMESTAMPTZ;
+ALTER TABLE deliveries ADD COLUMN arrived_at_dropoff_at TIMESTAMPTZ;
+ALTER TABLE deliveries ADD COLUMN delivered_at TIMESTAMPTZ;
+
+-- ============================================
+-- Webhook Endpoints Table
+-- ============================================
+-- Per organization receivers of delivery and tracking events
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    -- used to HMAC sign payloads, the receiver holds a copy
+    secret VARCHAR(255) NOT NULL,
+    -- empty means every event type
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_endpoints_organization
+    ON webhook_endpoints(organization_id) WHERE active = true;
+
+CREATE TRIGGER trigger_update_webhook_endpoints_updated_at
+    BEFORE UPDATE ON webhook_endpoints
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================
+-- Webhook Deliveries Table
+-- ============================================
+-- One row per (event, endpoint). Doubles as the retry queue.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    event_id UUID NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+
+    -- pending, succeeded, dead
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_webhook_deliveries_event_endpoint UNIQUE (event_id, endpoint_id)
+);
+
+-- Polled by the dispatcher
+CREATE INDEX idx_webhook_deliveries_due
+    ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX idx_webhook_deliveries_endpoint
+    ON webhook_deliveries(endpoint_id, created_at DESC);
+
+CREATE TRIGGER trigger_update_webhook_deliveries_updated_at
+    BEFORE UPDATE ON webhook_deliveries
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================
+-- Webhook Attempts Table
+-- ============================================
+-- Log of every HTTP call made for a delivery
+CREATE TABLE webhook_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER,
+    error TEXT,
+    duration_ms INTEGER NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_attempts_delivery
+    ON webhook_attempts(delivery_id, attempt);
+
+-- ============================================
+-- Webhook Dead Letters Table
+-- ============================================
+-- Deliveries that exhausted their retries, kept for inspection and replay
+CREATE TABLE webhook_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id UUID NOT NULL UNIQUE REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    last_error TEXT,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_dead_letters_organization
+    ON webhook_dead_letters(organization_id, failed_at DESC);
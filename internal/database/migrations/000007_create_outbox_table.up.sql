@@ -0,0 +1,29 @@
+-- ============================================
+-- Outbox Table
+-- ============================================
+-- Domain events written in the same transaction as the state change that
+-- raised them. A relay publishes them to the configured sinks afterwards,
+-- giving at-least-once delivery even if the process crashes in between.
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    published_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Polled by the relay, in order of insertion
+CREATE INDEX idx_outbox_events_unpublished
+    ON outbox_events(next_attempt_at, id) WHERE published_at IS NULL;
+
+-- Used to prune published events
+CREATE INDEX idx_outbox_events_published_at
+    ON outbox_events(published_at) WHERE published_at IS NOT NULL;
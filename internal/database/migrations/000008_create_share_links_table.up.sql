@@ -0,0 +1,19 @@
+-- ============================================
+-- Share Links Table
+-- ============================================
+-- Expiring links that let a recipient follow a single delivery without
+-- authenticating. Only a hash of the token is stored.
+CREATE TABLE share_links (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    delivery_id UUID NOT NULL REFERENCES deliveries(id) ON DELETE CASCADE,
+
+    token_hash BYTEA NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_share_links_delivery
+    ON share_links(organization_id, delivery_id);
@@ -0,0 +1,6 @@
+DROP VIEW IF EXISTS active_session_latest_locations;
+
+DROP TRIGGER IF EXISTS trigger_upsert_session_latest_location ON location_updates;
+DROP FUNCTION IF EXISTS upsert_session_latest_location();
+
+DROP TABLE IF EXISTS session_latest_locations CASCADE;
@@ -0,0 +1,76 @@
+-- ============================================
+-- Session Latest Locations Table
+-- ============================================
+-- One row per session holding its most recent point, kept up to date by a
+-- trigger on location_updates. Answers "where is everyone now" without
+-- scanning the location history.
+CREATE TABLE session_latest_locations (
+    session_id VARCHAR(255) PRIMARY KEY REFERENCES tracking_sessions(session_id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    location_id BIGINT NOT NULL,
+    delivery_id UUID,
+    location GEOGRAPHY(POINT, 4326) NOT NULL,
+    accuracy DOUBLE PRECISION NOT NULL,
+    speed DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    recorded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_session_latest_locations_organization
+    ON session_latest_locations(organization_id);
+CREATE INDEX idx_session_latest_locations_geography
+    ON session_latest_locations USING GIST(location);
+
+-- Points may arrive out of order, an older point never replaces a newer one
+CREATE OR REPLACE FUNCTION upsert_session_latest_location()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO session_latest_locations
+        (session_id, organization_id, location_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at)
+    VALUES
+        (NEW.session_id, NEW.organization_id, NEW.id, NEW.delivery_id, NEW.location, NEW.accuracy, NEW.speed, NEW.heading, NEW.recorded_at, NEW.created_at)
+    ON CONFLICT (session_id) DO UPDATE SET
+        location_id = EXCLUDED.location_id,
+        delivery_id = EXCLUDED.delivery_id,
+        location = EXCLUDED.location,
+        accuracy = EXCLUDED.accuracy,
+        speed = EXCLUDED.speed,
+        heading = EXCLUDED.heading,
+        recorded_at = EXCLUDED.recorded_at,
+        created_at = EXCLUDED.created_at,
+        updated_at = NOW()
+    WHERE session_latest_locations.recorded_at <= EXCLUDED.recorded_at;
+
+    RETURN NULL;
+END;
+
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_upsert_session_latest_location
+    AFTER INSERT ON location_updates
+    FOR EACH ROW
+    EXECUTE FUNCTION upsert_session_latest_location();
+
+-- Existing history
+INSERT INTO session_latest_locations
+    (session_id, organization_id, location_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at)
+SELECT DISTINCT ON (session_id)
+    session_id, organization_id, id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at
+FROM location_updates
+ORDER BY session_id, recorded_at DESC;
+
+-- Latest position of every session that is still tracking
+CREATE VIEW active_session_latest_locations AS
+    SELECT l.*
+    FROM session_latest_locations l
+    JOIN tracking_sessions ts ON ts.session_id = l.session_id
+    WHERE ts.is_active = true;
+
+ALTER TABLE session_latest_locations ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_session_latest_locations ON session_latest_locations
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
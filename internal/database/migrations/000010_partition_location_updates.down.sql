@@ -0,0 +1,58 @@
+-- Back to a single table. Points in detached partitions are not restored.
+ALTER TABLE location_updates RENAME TO location_updates_partitioned;
+ALTER TABLE location_updates_partitioned DROP CONSTRAINT location_updates_pkey;
+
+CREATE TABLE location_updates (
+    id BIGINT PRIMARY KEY DEFAULT nextval('location_updates_id_seq'),
+    session_id VARCHAR(255) NOT NULL,
+    delivery_id UUID,
+
+    location GEOGRAPHY(POINT, 4326) NOT NULL,
+
+    accuracy DOUBLE PRECISION NOT NULL CHECK (accuracy >= 0),
+    speed DOUBLE PRECISION CHECK (speed IS NULL OR speed >= 0),
+    heading DOUBLE PRECISION CHECK (heading IS NULL OR heading >= 0 AND heading <= 360),
+
+    recorded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+ALTER SEQUENCE location_updates_id_seq OWNED BY location_updates.id;
+
+INSERT INTO location_updates
+    (id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at, organization_id)
+SELECT
+    id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at, organization_id
+FROM location_updates_partitioned;
+
+-- partitions go with their parent
+DROP TABLE location_updates_partitioned CASCADE;
+
+ALTER TABLE location_updates ADD CONSTRAINT fk_location_tracking_session FOREIGN KEY (session_id)
+    REFERENCES tracking_sessions(session_id)
+    ON DELETE CASCADE;
+
+CREATE INDEX idx_location_updates_session_time
+    ON location_updates(session_id, recorded_at DESC);
+CREATE INDEX idx_location_updates_delivery_time
+    ON location_updates(delivery_id, recorded_at DESC);
+CREATE INDEX idx_location_updates_geography
+    ON location_updates USING GIST(location);
+CREATE INDEX idx_location_session_created
+    ON location_updates(session_id, created_at DESC);
+CREATE INDEX idx_location_updates_recorded_at
+    ON location_updates(recorded_at DESC);
+CREATE INDEX idx_location_updates_organization_session
+    ON location_updates(organization_id, session_id, recorded_at DESC);
+
+CREATE TRIGGER trigger_upsert_session_latest_location
+    AFTER INSERT ON location_updates
+    FOR EACH ROW
+    EXECUTE FUNCTION upsert_session_latest_location();
+
+ALTER TABLE location_updates ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_location_updates ON location_updates
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
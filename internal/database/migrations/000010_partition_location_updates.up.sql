@@ -0,0 +1,95 @@
+-- ============================================
+-- Partition location_updates by month
+-- ============================================
+-- The table is rebuilt as a range partitioned table on recorded_at with one
+-- partition per calendar month (UTC), named location_updates_pYYYYMM. The
+-- partition maintenance job creates upcoming months ahead of time and
+-- detaches expired ones. Points outside every partition, e.g. from devices
+-- with a wrong clock, land in location_updates_default.
+
+ALTER TABLE location_updates RENAME TO location_updates_legacy;
+ALTER TABLE location_updates_legacy DROP CONSTRAINT location_updates_pkey;
+
+CREATE TABLE location_updates (
+    id BIGINT NOT NULL DEFAULT nextval('location_updates_id_seq'),
+    session_id VARCHAR(255) NOT NULL,
+    delivery_id UUID,
+
+    location GEOGRAPHY(POINT, 4326) NOT NULL,
+
+    accuracy DOUBLE PRECISION NOT NULL CHECK (accuracy >= 0),
+    speed DOUBLE PRECISION CHECK (speed IS NULL OR speed >= 0),
+    heading DOUBLE PRECISION CHECK (heading IS NULL OR heading >= 0 AND heading <= 360),
+
+    recorded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    -- the partition key has to be part of the primary key
+    PRIMARY KEY (id, recorded_at),
+
+    CONSTRAINT fk_location_tracking_session FOREIGN KEY (session_id)
+        REFERENCES tracking_sessions(session_id)
+        ON DELETE CASCADE
+) PARTITION BY RANGE (recorded_at);
+
+-- the sequence keeps counting from where the old table stopped
+ALTER SEQUENCE location_updates_id_seq OWNED BY location_updates.id;
+
+CREATE TABLE location_updates_default PARTITION OF location_updates DEFAULT;
+
+-- one partition per month holding existing points, through three months ahead
+DO $$
+DECLARE
+    month_start TIMESTAMPTZ;
+    last_month TIMESTAMPTZ := date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' + INTERVAL '3 months';
+BEGIN
+    SELECT COALESCE(
+        date_trunc('month', MIN(recorded_at) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+        date_trunc('month', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+    ) INTO month_start FROM location_updates_legacy;
+
+    WHILE month_start <= last_month LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF location_updates FOR VALUES FROM (%L) TO (%L)',
+            'location_updates_p' || to_char(month_start AT TIME ZONE 'UTC', 'YYYYMM'),
+            month_start,
+            month_start + INTERVAL '1 month'
+        );
+        month_start := month_start + INTERVAL '1 month';
+    END LOOP;
+END;
+$$;
+
+INSERT INTO location_updates
+    (id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at, organization_id)
+SELECT
+    id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at, organization_id
+FROM location_updates_legacy;
+
+DROP TABLE location_updates_legacy;
+
+-- Indices, created on every partition
+CREATE INDEX idx_location_updates_session_time
+    ON location_updates(session_id, recorded_at DESC);
+CREATE INDEX idx_location_updates_delivery_time
+    ON location_updates(delivery_id, recorded_at DESC);
+CREATE INDEX idx_location_updates_geography
+    ON location_updates USING GIST(location);
+CREATE INDEX idx_location_session_created
+    ON location_updates(session_id, created_at DESC);
+CREATE INDEX idx_location_updates_recorded_at
+    ON location_updates(recorded_at DESC);
+CREATE INDEX idx_location_updates_organization_session
+    ON location_updates(organization_id, session_id, recorded_at DESC);
+
+CREATE TRIGGER trigger_upsert_session_latest_location
+    AFTER INSERT ON location_updates
+    FOR EACH ROW
+    EXECUTE FUNCTION upsert_session_latest_location();
+
+ALTER TABLE location_updates ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_location_updates ON location_updates
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session_summaries CASCADE;
+DROP TABLE IF EXISTS retention_policies CASCADE;
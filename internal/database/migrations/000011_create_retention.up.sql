@@ -0,0 +1,44 @@
+-- ============================================
+-- Retention Policies Table
+-- ============================================
+-- How long an organization's raw location points are kept. Organizations
+-- without a policy keep them forever.
+CREATE TABLE retention_policies (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    location_retention_days INTEGER NOT NULL CHECK (location_retention_days > 0),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER trigger_update_retention_policies_updated_at
+    BEFORE UPDATE ON retention_policies
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================
+-- Session Summaries Table
+-- ============================================
+-- What is kept of a session once its raw points are archived and deleted:
+-- totals and a simplified route. archive_key locates the exported points.
+CREATE TABLE session_summaries (
+    session_id VARCHAR(255) PRIMARY KEY REFERENCES tracking_sessions(session_id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    point_count INTEGER NOT NULL,
+    distance_meters DOUBLE PRECISION NOT NULL,
+    first_recorded_at TIMESTAMPTZ NOT NULL,
+    last_recorded_at TIMESTAMPTZ NOT NULL,
+    route GEOGRAPHY(LINESTRING, 4326) NOT NULL,
+
+    archive_key TEXT NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_session_summaries_organization
+    ON session_summaries(organization_id, archived_at DESC);
+
+ALTER TABLE session_summaries ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_session_summaries ON session_summaries
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS privacy_requests CASCADE;
+
+-- erased summaries have nothing to restore, they are dropped
+DELETE FROM session_summaries WHERE route IS NULL OR archive_key IS NULL;
+ALTER TABLE session_summaries DROP COLUMN IF EXISTS erased_at;
+ALTER TABLE session_summaries ALTER COLUMN route SET NOT NULL;
+ALTER TABLE session_summaries ALTER COLUMN archive_key SET NOT NULL;
+
+ALTER TABLE riders DROP COLUMN IF EXISTS erased_at;
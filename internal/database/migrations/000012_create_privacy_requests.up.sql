@@ -0,0 +1,34 @@
+-- ============================================
+-- Rider erasure
+-- ============================================
+-- Erased riders keep their row, stripped of personal details, so deliveries
+-- and sessions still add up for invoicing.
+ALTER TABLE riders ADD COLUMN erased_at TIMESTAMPTZ;
+
+-- Summaries of erased sessions keep their totals but lose the route
+ALTER TABLE session_summaries ALTER COLUMN route DROP NOT NULL;
+ALTER TABLE session_summaries ALTER COLUMN archive_key DROP NOT NULL;
+ALTER TABLE session_summaries ADD COLUMN erased_at TIMESTAMPTZ;
+
+-- ============================================
+-- Privacy Requests Table
+-- ============================================
+-- Audit record of every export and erasure of a rider's data
+CREATE TABLE privacy_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    rider_id UUID NOT NULL,
+
+    -- export, erasure
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('export', 'erasure')),
+    reason TEXT,
+    source_ip VARCHAR(64),
+
+    session_count INTEGER NOT NULL DEFAULT 0,
+    point_count BIGINT NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_privacy_requests_rider
+    ON privacy_requests(organization_id, rider_id, created_at DESC);
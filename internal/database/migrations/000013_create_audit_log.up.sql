@@ -0,0 +1,48 @@
+-- ============================================
+-- Audit Log Table
+-- ============================================
+-- Who changed a session or delivery, when, from where and how. Rows are never
+-- updated or deleted; organization_id has no foreign key so that removing an
+-- organization does not cascade into the log.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id UUID NOT NULL,
+
+    -- api_key, admin, rider_app, system
+    actor_type VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(255),
+    source_ip VARCHAR(64),
+
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+
+    before JSONB,
+    after JSONB,
+    diff JSONB,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_entity
+    ON audit_log(organization_id, entity_type, entity_id, id DESC);
+
+CREATE INDEX idx_audit_log_organization
+    ON audit_log(organization_id, id DESC);
+
+CREATE OR REPLACE FUNCTION prevent_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_audit_log_change();
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_audit_log ON audit_log
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
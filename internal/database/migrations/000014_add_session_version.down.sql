@@ -0,0 +1 @@
+ALTER TABLE tracking_sessions DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- ============================================
+-- Session Versions
+-- ============================================
+-- Bumped on every update. Writers send the version they read and lose if the
+-- session changed in between, instead of overwriting it.
+ALTER TABLE tracking_sessions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session_pauses CASCADE;
+ALTER TABLE tracking_sessions DROP COLUMN IF EXISTS paused_at;
@@ -0,0 +1,29 @@
+-- ============================================
+-- Session Pauses
+-- ============================================
+-- Riders may pause a session, on a break or while waiting at a pickup,
+-- without ending it. Points are rejected while paused and paused time is not
+-- billed. paused_at is set while a pause is open.
+ALTER TABLE tracking_sessions ADD COLUMN paused_at TIMESTAMPTZ;
+
+CREATE TABLE session_pauses (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL REFERENCES tracking_sessions(session_id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    started_at TIMESTAMPTZ NOT NULL,
+    -- NULL while the pause is open
+    ended_at TIMESTAMPTZ,
+
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX idx_session_pauses_session ON session_pauses(session_id, started_at);
+
+-- at most one open pause per session
+CREATE UNIQUE INDEX idx_session_pauses_open ON session_pauses(session_id) WHERE ended_at IS NULL;
+
+ALTER TABLE session_pauses ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_session_pauses ON session_pauses
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
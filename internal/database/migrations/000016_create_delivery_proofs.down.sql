@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS delivery_pins CASCADE;
+DROP TABLE IF EXISTS delivery_proofs CASCADE;
@@ -0,0 +1,58 @@
+-- ============================================
+-- Delivery Proofs Table
+-- ============================================
+-- Photos, signatures and PIN confirmations captured at drop-off. Files live
+-- in the proof store under blob_key; location is the rider's latest position
+-- when the proof was captured.
+CREATE TABLE delivery_proofs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    delivery_id UUID NOT NULL REFERENCES deliveries(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+
+    -- photo, signature, pin
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('photo', 'signature', 'pin')),
+
+    content_type VARCHAR(64),
+    size_bytes BIGINT,
+    sha256 CHAR(64),
+    blob_key TEXT,
+
+    location GEOGRAPHY(POINT, 4326),
+    location_accuracy DOUBLE PRECISION,
+    location_recorded_at TIMESTAMPTZ,
+
+    captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CHECK (kind = 'pin' OR blob_key IS NOT NULL)
+);
+
+CREATE INDEX idx_delivery_proofs_delivery
+    ON delivery_proofs(organization_id, delivery_id, captured_at);
+
+ALTER TABLE delivery_proofs ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_delivery_proofs ON delivery_proofs
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- ============================================
+-- Delivery PINs Table
+-- ============================================
+-- One-time code per delivery for the recipient to confirm the handover.
+-- Issuing a new PIN replaces the previous one.
+CREATE TABLE delivery_pins (
+    delivery_id UUID PRIMARY KEY REFERENCES deliveries(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+
+    pin_hash BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    verified_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE delivery_pins ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_delivery_pins ON delivery_pins
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
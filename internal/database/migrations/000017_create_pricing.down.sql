@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session_fares CASCADE;
+DROP TABLE IF EXISTS surge_zones CASCADE;
+DROP TABLE IF EXISTS rate_cards CASCADE;
@@ -0,0 +1,93 @@
+-- ============================================
+-- Rate Cards Table
+-- ============================================
+-- Fares per organization and vehicle type. Amounts are in minor units of the
+-- currency, e.g. cents.
+CREATE TABLE rate_cards (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    vehicle_type VARCHAR(32) NOT NULL CHECK (vehicle_type IN ('bicycle', 'motorcycle', 'car', 'van')),
+    currency CHAR(3) NOT NULL,
+
+    base_fare BIGINT NOT NULL CHECK (base_fare >= 0),
+    per_km BIGINT NOT NULL CHECK (per_km >= 0),
+    per_minute BIGINT NOT NULL CHECK (per_minute >= 0),
+    minimum_fare BIGINT NOT NULL CHECK (minimum_fare >= 0),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (organization_id, vehicle_type)
+);
+
+CREATE TRIGGER trigger_update_rate_cards_updated_at
+    BEFORE UPDATE ON rate_cards
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE rate_cards ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_rate_cards ON rate_cards
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- ============================================
+-- Surge Zones Table
+-- ============================================
+-- Areas where fares are multiplied, e.g. a stadium on match day. Trips are
+-- matched on where they start; overlapping zones apply the highest multiplier.
+CREATE TABLE surge_zones (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+
+    -- NULL applies to every vehicle type
+    vehicle_type VARCHAR(32) CHECK (vehicle_type IN ('bicycle', 'motorcycle', 'car', 'van')),
+
+    center GEOGRAPHY(POINT, 4326) NOT NULL,
+    radius_meters DOUBLE PRECISION NOT NULL CHECK (radius_meters > 0),
+    multiplier NUMERIC(4, 2) NOT NULL CHECK (multiplier >= 1),
+
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at)
+);
+
+CREATE INDEX idx_surge_zones_center ON surge_zones USING GIST(center);
+CREATE INDEX idx_surge_zones_organization ON surge_zones(organization_id);
+
+ALTER TABLE surge_zones ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_surge_zones ON surge_zones
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- ============================================
+-- Session Fares Table
+-- ============================================
+-- The final fare of a stopped session with its itemized breakdown
+CREATE TABLE session_fares (
+    session_id VARCHAR(255) PRIMARY KEY REFERENCES tracking_sessions(session_id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    delivery_id UUID,
+
+    vehicle_type VARCHAR(32) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    distance_meters DOUBLE PRECISION NOT NULL,
+    duration_seconds DOUBLE PRECISION NOT NULL,
+    paused_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    surge_multiplier NUMERIC(4, 2) NOT NULL DEFAULT 1,
+    surge_zone_id UUID,
+
+    lines JSONB NOT NULL,
+    total BIGINT NOT NULL,
+
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_session_fares_delivery ON session_fares(organization_id, delivery_id);
+
+ALTER TABLE session_fares ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_session_fares ON session_fares
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
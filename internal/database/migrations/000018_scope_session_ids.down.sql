@@ -0,0 +1,64 @@
+-- Fails if two organizations have since used the same session id
+ALTER TABLE location_updates DROP CONSTRAINT fk_location_tracking_session;
+ALTER TABLE session_latest_locations DROP CONSTRAINT fk_session_latest_locations_session;
+ALTER TABLE session_summaries DROP CONSTRAINT fk_session_summaries_session;
+ALTER TABLE session_pauses DROP CONSTRAINT fk_session_pauses_session;
+ALTER TABLE session_fares DROP CONSTRAINT fk_session_fares_session;
+
+ALTER TABLE tracking_sessions DROP CONSTRAINT tracking_sessions_organization_session_key;
+ALTER TABLE tracking_sessions ADD CONSTRAINT tracking_sessions_session_id_key UNIQUE (session_id);
+
+ALTER TABLE location_updates ADD CONSTRAINT fk_location_tracking_session
+    FOREIGN KEY (session_id) REFERENCES tracking_sessions(session_id) ON DELETE CASCADE;
+
+ALTER TABLE session_latest_locations DROP CONSTRAINT session_latest_locations_pkey;
+ALTER TABLE session_latest_locations ADD PRIMARY KEY (session_id);
+ALTER TABLE session_latest_locations ADD CONSTRAINT session_latest_locations_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES tracking_sessions(session_id) ON DELETE CASCADE;
+
+ALTER TABLE session_summaries DROP CONSTRAINT session_summaries_pkey;
+ALTER TABLE session_summaries ADD PRIMARY KEY (session_id);
+ALTER TABLE session_summaries ADD CONSTRAINT session_summaries_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES tracking_sessions(session_id) ON DELETE CASCADE;
+
+ALTER TABLE session_pauses ADD CONSTRAINT session_pauses_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES tracking_sessions(session_id) ON DELETE CASCADE;
+DROP INDEX idx_session_pauses_session;
+DROP INDEX idx_session_pauses_open;
+CREATE INDEX idx_session_pauses_session ON session_pauses(session_id, started_at);
+CREATE UNIQUE INDEX idx_session_pauses_open ON session_pauses(session_id) WHERE ended_at IS NULL;
+
+ALTER TABLE session_fares DROP CONSTRAINT session_fares_pkey;
+ALTER TABLE session_fares ADD PRIMARY KEY (session_id);
+ALTER TABLE session_fares ADD CONSTRAINT session_fares_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES tracking_sessions(session_id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION upsert_session_latest_location()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO session_latest_locations
+        (session_id, organization_id, location_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at)
+    VALUES
+        (NEW.session_id, NEW.organization_id, NEW.id, NEW.delivery_id, NEW.location, NEW.accuracy, NEW.speed, NEW.heading, NEW.recorded_at, NEW.created_at)
+    ON CONFLICT (session_id) DO UPDATE SET
+        location_id = EXCLUDED.location_id,
+        delivery_id = EXCLUDED.delivery_id,
+        location = EXCLUDED.location,
+        accuracy = EXCLUDED.accuracy,
+        speed = EXCLUDED.speed,
+        heading = EXCLUDED.heading,
+        recorded_at = EXCLUDED.recorded_at,
+        created_at = EXCLUDED.created_at,
+        updated_at = NOW()
+    WHERE session_latest_locations.recorded_at <= EXCLUDED.recorded_at;
+
+    RETURN NULL;
+END;
+
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE VIEW active_session_latest_locations AS
+    SELECT l.*
+    FROM session_latest_locations l
+    JOIN tracking_sessions ts ON ts.session_id = l.session_id
+    WHERE ts.is_active = true;
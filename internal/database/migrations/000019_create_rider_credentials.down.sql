@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS access_tickets;
+DROP TABLE IF EXISTS rider_credentials;
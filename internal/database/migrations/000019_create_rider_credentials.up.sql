@@ -0,0 +1,50 @@
+-- ============================================
+-- Rider Credentials Table
+-- ============================================
+-- Secrets issued to a rider's app. Rider facing routes derive the
+-- organization and the rider from the credential, never from the request.
+-- Only a SHA-256 hash is stored, the prefix is used to look it up.
+CREATE TABLE rider_credentials (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    rider_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    token_hash BYTEA NOT NULL,
+
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_rider_credentials_rider FOREIGN KEY (rider_id, organization_id)
+        REFERENCES riders(id, organization_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_rider_credentials_rider
+    ON rider_credentials(organization_id, rider_id, created_at DESC);
+
+ALTER TABLE rider_credentials ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_rider_credentials ON rider_credentials
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- ============================================
+-- Access Tickets Table
+-- ============================================
+-- Short lived, single use stand-ins for a credential on requests that cannot
+-- carry an Authorization header, such as a browser's websocket handshake.
+-- Redeeming a ticket deletes it.
+CREATE TABLE access_tickets (
+    token_hash BYTEA PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    scope VARCHAR(64) NOT NULL,
+
+    actor_type VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(255) NOT NULL DEFAULT '',
+
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_access_tickets_expires ON access_tickets(expires_at);
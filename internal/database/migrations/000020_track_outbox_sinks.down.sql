@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outbox_dead_letters;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS delivered_sinks;
@@ -0,0 +1,28 @@
+-- ============================================
+-- Per Sink Outbox Delivery
+-- ============================================
+-- Sinks an event already reached, a retry only goes to the ones that failed
+ALTER TABLE outbox_events
+    ADD COLUMN delivered_sinks TEXT[] NOT NULL DEFAULT '{}';
+
+-- ============================================
+-- Outbox Dead Letters Table
+-- ============================================
+-- Events a sink still failed on after the last attempt, one row per sink,
+-- kept for inspection. The event itself is finished and pruned as usual.
+CREATE TABLE outbox_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    sink VARCHAR(64) NOT NULL,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT outbox_dead_letters_event_sink_key UNIQUE (event_id, sink)
+);
+
+CREATE INDEX idx_outbox_dead_letters_organization
+    ON outbox_dead_letters(organization_id, failed_at DESC);
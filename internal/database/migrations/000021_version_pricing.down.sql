@@ -0,0 +1,6 @@
+DELETE FROM surge_zones WHERE deleted_at IS NOT NULL;
+ALTER TABLE surge_zones DROP COLUMN IF EXISTS deleted_at;
+
+DROP TRIGGER IF EXISTS trigger_record_rate_card_history ON rate_cards;
+DROP FUNCTION IF EXISTS record_rate_card_history();
+DROP TABLE IF EXISTS rate_card_history;
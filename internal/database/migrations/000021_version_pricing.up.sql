@@ -0,0 +1,76 @@
+-- ============================================
+-- Rate Card History Table
+-- ============================================
+-- Every version of every rate card with the period it was in effect, kept by
+-- a trigger on rate_cards. A session is priced with the card in effect when
+-- it stopped, however late the fare is computed.
+CREATE TABLE rate_card_history (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    vehicle_type VARCHAR(32) NOT NULL,
+    currency CHAR(3) NOT NULL,
+
+    base_fare BIGINT NOT NULL,
+    per_km BIGINT NOT NULL,
+    per_minute BIGINT NOT NULL,
+    minimum_fare BIGINT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+
+    valid_from TIMESTAMPTZ NOT NULL,
+    -- NULL while the version is current
+    valid_to TIMESTAMPTZ
+);
+
+CREATE INDEX idx_rate_card_history_card
+    ON rate_card_history(organization_id, vehicle_type, valid_from DESC);
+
+-- at most one current version per card
+CREATE UNIQUE INDEX idx_rate_card_history_current
+    ON rate_card_history(organization_id, vehicle_type) WHERE valid_to IS NULL;
+
+ALTER TABLE rate_card_history ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_rate_card_history ON rate_card_history
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
+
+-- Closes the current version and, unless the card was deleted, opens a new one
+CREATE OR REPLACE FUNCTION record_rate_card_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE rate_card_history SET valid_to = NOW()
+        WHERE organization_id = OLD.organization_id AND vehicle_type = OLD.vehicle_type AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO rate_card_history
+        (organization_id, vehicle_type, currency, base_fare, per_km, per_minute, minimum_fare, created_at, updated_at, valid_from)
+    VALUES
+        (NEW.organization_id, NEW.vehicle_type, NEW.currency, NEW.base_fare, NEW.per_km, NEW.per_minute, NEW.minimum_fare, NEW.created_at, NEW.updated_at, NOW());
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_record_rate_card_history
+    AFTER INSERT OR UPDATE OR DELETE ON rate_cards
+    FOR EACH ROW
+    EXECUTE FUNCTION record_rate_card_history();
+
+-- Existing cards are taken to have been in effect since their last change
+INSERT INTO rate_card_history
+    (organization_id, vehicle_type, currency, base_fare, per_km, per_minute, minimum_fare, created_at, updated_at, valid_from)
+SELECT organization_id, vehicle_type, currency, base_fare, per_km, per_minute, minimum_fare, created_at, updated_at, updated_at
+FROM rate_cards;
+
+-- ============================================
+-- Surge Zone Soft Delete
+-- ============================================
+-- Deleted zones are kept so trips that started while they were in effect
+-- are still priced with them
+ALTER TABLE surge_zones ADD COLUMN deleted_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_outbox_events_session;
+DROP INDEX IF EXISTS idx_outbox_events_delivery;
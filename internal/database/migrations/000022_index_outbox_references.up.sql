@@ -0,0 +1,10 @@
+-- ============================================
+-- Outbox Event References
+-- ============================================
+-- A resuming watcher reads only the events of its delivery and of the
+-- sessions that carried it
+CREATE INDEX idx_outbox_events_delivery
+    ON outbox_events(organization_id, (payload->'data'->>'deliveryId'), id);
+
+CREATE INDEX idx_outbox_events_session
+    ON outbox_events(organization_id, (payload->'data'->>'sessionId'), id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS tracking_sessions_notify_change ON tracking_sessions;
+DROP FUNCTION IF EXISTS notify_tracking_session_change();
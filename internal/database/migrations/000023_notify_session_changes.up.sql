@@ -0,0 +1,17 @@
+-- ============================================
+-- Session Change Notifications
+-- ============================================
+-- Every instance caches session state between location points. Whoever
+-- changes a session, the others hear of it on this channel and drop their
+-- copy. The payload is <organization_id>/<session_id>.
+CREATE OR REPLACE FUNCTION notify_tracking_session_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('tracking_session_changes', OLD.organization_id::text || '/' || OLD.session_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tracking_sessions_notify_change
+    AFTER UPDATE OR DELETE ON tracking_sessions
+    FOR EACH ROW EXECUTE FUNCTION notify_tracking_session_change();
@@ -0,0 +1,29 @@
+-- ============================================
+-- Dispatch Offers Table
+-- ============================================
+-- A delivery offered to one rider's session while it is being dispatched.
+-- The rider may answer on any instance, the dispatching one reads it here.
+CREATE TABLE dispatch_offers (
+    id VARCHAR(32) PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    delivery_id UUID NOT NULL REFERENCES deliveries(id) ON DELETE CASCADE,
+    session_id VARCHAR(255) NOT NULL,
+
+    -- meters from the rider to the pickup when offered
+    distance DOUBLE PRECISION NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+
+    -- NULL until answered; an offer that ran out is closed as declined
+    accepted BOOLEAN,
+    responded_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_dispatch_offers_delivery
+    ON dispatch_offers(organization_id, delivery_id, created_at DESC);
+
+ALTER TABLE dispatch_offers ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation_dispatch_offers ON dispatch_offers
+    USING (organization_id = NULLIF(current_setting('app.current_organization', true), '')::uuid);
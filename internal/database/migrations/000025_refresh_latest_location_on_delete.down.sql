@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trigger_refresh_session_latest_location ON location_updates;
+DROP FUNCTION IF EXISTS refresh_session_latest_location();
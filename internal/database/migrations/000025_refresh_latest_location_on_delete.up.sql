@@ -0,0 +1,40 @@
+-- ============================================
+-- Latest Location On Delete
+-- ============================================
+-- When the point a session's latest location was taken from is deleted, by
+-- retention or erasure, the next most recent point takes its place. A session
+-- left without points loses its latest location.
+CREATE OR REPLACE FUNCTION refresh_session_latest_location()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- points moved out of the default partition are not gone, see
+    -- partitionRepository.CreateLocationPartition
+    IF current_setting('app.moving_locations', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    DELETE FROM session_latest_locations
+    WHERE organization_id = OLD.organization_id AND session_id = OLD.session_id AND location_id = OLD.id;
+
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO session_latest_locations
+        (session_id, organization_id, location_id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at)
+    SELECT session_id, organization_id, id, delivery_id, location, accuracy, speed, heading, recorded_at, created_at
+    FROM location_updates
+    WHERE organization_id = OLD.organization_id AND session_id = OLD.session_id
+    ORDER BY recorded_at DESC
+    LIMIT 1
+    ON CONFLICT (organization_id, session_id) DO NOTHING;
+
+    RETURN NULL;
+END;
+
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_refresh_session_latest_location
+    AFTER DELETE ON location_updates
+    FOR EACH ROW
+    EXECUTE FUNCTION refresh_session_latest_location();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_deliveries_dispatch_expires;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS dispatch_expires_at;
@@ -0,0 +1,11 @@
+-- ============================================
+-- Dispatch Leases
+-- ============================================
+-- A delivery is claimed for dispatching until dispatch_expires_at. The
+-- dispatching instance renews the claim before every offer; a claim left to
+-- run out, e.g. by a crash, is taken over by any instance.
+ALTER TABLE deliveries ADD COLUMN dispatch_expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_deliveries_dispatch_expires
+    ON deliveries(dispatch_expires_at)
+    WHERE status = 'dispatching';
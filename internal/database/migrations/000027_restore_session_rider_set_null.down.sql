@@ -0,0 +1,3 @@
+ALTER TABLE tracking_sessions DROP CONSTRAINT IF EXISTS fk_tracking_sessions_rider_organization;
+ALTER TABLE tracking_sessions ADD CONSTRAINT fk_tracking_sessions_rider_organization
+    FOREIGN KEY (rider_id, organization_id) REFERENCES riders(id, organization_id);
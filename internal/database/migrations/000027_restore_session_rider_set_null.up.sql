@@ -0,0 +1,10 @@
+-- ============================================
+-- Session rider on delete
+-- ============================================
+-- Deleting a rider keeps their sessions and only clears rider_id, as before
+-- the key became composite in 000004. The column list (PostgreSQL 15+) leaves
+-- organization_id alone, which is part of the key but must stay set.
+ALTER TABLE tracking_sessions DROP CONSTRAINT fk_tracking_sessions_rider_organization;
+ALTER TABLE tracking_sessions ADD CONSTRAINT fk_tracking_sessions_rider_organization
+    FOREIGN KEY (rider_id, organization_id) REFERENCES riders(id, organization_id)
+    ON DELETE SET NULL (rider_id);
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+const (
+	ScopeReadTracking    = "read:tracking"
+	ScopeWriteTracking   = "write:tracking"
+	ScopeReadDeliveries  = "read:deliveries"
+	ScopeWriteDeliveries = "write:deliveries"
+	ScopeReadRiders      = "read:riders"
+	ScopeWriteRiders     = "write:riders"
+	ScopeManageWebhooks  = "manage:webhooks"
+	ScopeReadAudit       = "read:audit"
+)
+
+var KnownScopes = []string{
+	ScopeReadTracking,
+	ScopeWriteTracking,
+	ScopeReadDeliveries,
+	ScopeWriteDeliveries,
+	ScopeReadRiders,
+	ScopeWriteRiders,
+	ScopeManageWebhooks,
+	ScopeReadAudit,
+}
+
+// APIKey grants an organization's systems access to the API. The secret is only
+// known when the key is created or rotated.
+type APIKey struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organizationId"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	KeyHash        []byte     `json:"-"`
+	Scopes         []string   `json:"scopes"`
+	LastUsedAt     *time.Time `json:"lastUsedAt"`
+	RevokedAt      *time.Time `json:"revokedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ActorSystem   = "system"
+	ActorAPIKey   = "api_key"
+	ActorAdmin    = "admin"
+	ActorRiderApp = "rider_app"
+)
+
+const (
+	AuditSessionStart   = "session.start"
+	AuditSessionStop    = "session.stop"
+	AuditSessionPause   = "session.pause"
+	AuditSessionResume  = "session.resume"
+	AuditDeliveryCreate = "delivery.create"
+	AuditDeliveryUpdate = "delivery.update"
+	AuditDeliveryProof  = "delivery.proof"
+)
+
+const (
+	AuditEntitySession  = "session"
+	AuditEntityDelivery = "delivery"
+)
+
+// Actor is who or what made a change: an api key, an operator, a rider's app
+// or the server itself
+type Actor struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"`
+	SourceIP string `json:"sourceIp,omitempty"`
+}
+
+// AuditEntry records one change to an entity. Before is nil for creations.
+// Diff holds the fields that changed as {"field": {"from": x, "to": y}}.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Actor      Actor           `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	ActorID    string
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	// only entries older than this id, for paging backwards
+	BeforeID int64
+	Limit    int
+}
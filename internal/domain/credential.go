@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// RiderCredential is the secret a rider's app signs in with. Requests made
+// with it act for that rider in the rider's organization. Like api keys the
+// secret is only known when the credential is issued.
+type RiderCredential struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organizationId"`
+	RiderID        string `json:"riderId"`
+	// the device the credential was issued to, free-form
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  []byte     `json:"-"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (c *RiderCredential) Revoked() bool {
+	return c.RevokedAt != nil
+}
+
+// AccessTicket stands in for a credential where a client cannot send one in
+// a header, such as a browser opening a websocket. It is redeemed once, for
+// the one scope it was issued for, by the actor that requested it.
+type AccessTicket struct {
+	TokenHash      []byte    `json:"-"`
+	OrganizationID string    `json:"organizationId"`
+	Scope          string    `json:"scope"`
+	Actor          Actor     `json:"actor"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending     DeliveryStatus = "pending"
+	DeliveryDispatching DeliveryStatus = "dispatching"
+	DeliveryAssigned    DeliveryStatus = "assigned"
+	DeliveryInTransit   DeliveryStatus = "in_transit"
+	DeliveryDelivered   DeliveryStatus = "delivered"
+	DeliveryCancelled   DeliveryStatus = "cancelled"
+	// no rider accepted the job, it needs to be dispatched again
+	DeliveryUnassigned DeliveryStatus = "unassigned"
+)
+
+type Delivery struct {
+	ID                string         `json:"id"`
+	Pickup            Coordinate     `json:"pickup"`
+	Dropoff           Coordinate     `json:"dropoff"`
+	Status            DeliveryStatus `json:"status"`
+	AssignedSessionID *string        `json:"assignedSessionId"`
+	PickedUpAt        *time.Time     `json:"pickedUpAt"`
+	ArrivedAtDropoff  *time.Time     `json:"arrivedAtDropoffAt"`
+	DeliveredAt       *time.Time     `json:"deliveredAt"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
+	// while dispatching, when the claim runs out unless it is renewed
+	DispatchExpiresAt *time.Time `json:"-"`
+}
+
+// ExpiredDispatch is a delivery whose dispatch claim ran out before it was
+// assigned or given up on
+type ExpiredDispatch struct {
+	OrganizationID string
+	DeliveryID     string
+}
+
+// DispatchOffer is a job offered to a single rider, who has until ExpiresAt to respond
+type DispatchOffer struct {
+	ID         string     `json:"offerId"`
+	DeliveryID string     `json:"deliveryId"`
+	SessionID  string     `json:"sessionId"`
+	Pickup     Coordinate `json:"pickup"`
+	Dropoff    Coordinate `json:"dropoff"`
+	// distance from the rider's latest position to the pickup in meters
+	Distance  float64   `json:"distance"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// DispatchCandidate is a session of an available, on shift rider near a pickup
+type DispatchCandidate struct {
+	SessionID string
+	Location  Coordinate
+	// deliveries assigned to the session and not yet delivered
+	Load int
+}
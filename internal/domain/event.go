@@ -0,0 +1,69 @@
+package domain
+
+import "time"
+
+const (
+	EventSessionStarted        = "session.started"
+	EventSessionStopped        = "session.stopped"
+	EventSessionPaused         = "session.paused"
+	EventSessionResumed        = "session.resumed"
+	EventRiderArrivedAtPickup  = "rider.arrived_at_pickup"
+	EventRiderArrivedAtDropoff = "rider.arrived_at_dropoff"
+	EventDeliveryAssigned      = "delivery.assigned"
+	EventDeliveryStatusChanged = "delivery.status_changed"
+	EventDeliveryCompleted     = "delivery.completed"
+	EventLocationRecorded      = "location.recorded"
+)
+
+var KnownEventTypes = []string{
+	EventSessionStarted,
+	EventSessionStopped,
+	EventSessionPaused,
+	EventSessionResumed,
+	EventRiderArrivedAtPickup,
+	EventRiderArrivedAtDropoff,
+	EventDeliveryAssigned,
+	EventDeliveryStatusChanged,
+	EventDeliveryCompleted,
+	EventLocationRecorded,
+}
+
+// OptInEvent reports whether an event type is too frequent to be sent to
+// webhook endpoints that subscribe to everything; they must name it explicitly
+func OptInEvent(eventType string) bool {
+	return eventType == EventLocationRecorded
+}
+
+// Event is a domain event raised by a state change, delivered to subscribers
+type Event struct {
+	ID             string      `json:"id"`
+	Type           string      `json:"type"`
+	OrganizationID string      `json:"organizationId"`
+	OccurredAt     time.Time   `json:"occurredAt"`
+	Data           interface{} `json:"data"`
+}
+
+// OutboxEvent is an event waiting in the outbox to be relayed
+type OutboxEvent struct {
+	*Event
+	// failed relay attempts so far
+	Attempts int
+	// sinks that already received the event
+	DeliveredSinks []string
+}
+
+type SessionEventData struct {
+	SessionID  string     `json:"sessionId"`
+	DeliveryID string     `json:"deliveryId,omitempty"`
+	RiderID    *string    `json:"riderId,omitempty"`
+	StartTime  time.Time  `json:"startTime"`
+	EndTime    *time.Time `json:"endTime,omitempty"`
+	PausedAt   *time.Time `json:"pausedAt,omitempty"`
+}
+
+type DeliveryEventData struct {
+	DeliveryID string         `json:"deliveryId"`
+	SessionID  string         `json:"sessionId"`
+	Status     DeliveryStatus `json:"status"`
+	Location   *Coordinate    `json:"location,omitempty"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// FleetStatusIdle filters for active sessions without a dispatched delivery
+const FleetStatusIdle = "idle"
+
+// FleetSession is an active tracking session as a dispatcher sees it
+type FleetSession struct {
+	SessionID string    `json:"sessionId"`
+	RiderID   *string   `json:"riderId,omitempty"`
+	StartTime time.Time `json:"startTime"`
+
+	// the open delivery dispatched to the session, if any
+	DeliveryID     *string         `json:"deliveryId,omitempty"`
+	DeliveryStatus *DeliveryStatus `json:"deliveryStatus,omitempty"`
+
+	Location *LocationUpdate `json:"location,omitempty"`
+}
+
+// StaleSession is an active session that stopped sending points
+type StaleSession struct {
+	OrganizationID string
+	SessionID      string
+	// when the last point was recorded, or the session started if it has none
+	LastSeenAt time.Time
+}
+
+// BoundingBox is a map viewport in WGS84 degrees
+type BoundingBox struct {
+	MinLatitude  float64 `json:"minLatitude"`
+	MinLongitude float64 `json:"minLongitude"`
+	MaxLatitude  float64 `json:"maxLatitude"`
+	MaxLongitude float64 `json:"maxLongitude"`
+}
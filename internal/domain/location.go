@@ -6,25 +6,34 @@ import (
 )
 
 type LocationUpdate struct {
-	ID         int64
-	SessionID  string
-	DeliveryID string
-	Latitude   float64
-	Longitude  float64
-	Accuracy   float64
-	Speed      *float64
-	Heading    *float64
-	RecordedAt time.Time
-	CreatedAt time.Time 
+	ID         int64     `json:"id"`
+	SessionID  string    `json:"sessionId"`
+	DeliveryID string    `json:"deliveryId,omitempty"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Accuracy   float64   `json:"accuracy"`
+	Speed      *float64  `json:"speed"`
+	Heading    *float64  `json:"heading"`
+	RecordedAt time.Time `json:"recordedAt"`
+	CreatedAt  time.Time `json:"createdAt"`
 }
 
 
 type TrackingSession struct {
-	SessionID string
-	DeliveryID string
-	StartTime time.Time
-	EndTime *time.Time
-	IsActive bool
+	SessionID string `json:"sessionId"`
+	DeliveryID string `json:"deliveryId,omitempty"`
+	RiderID *string `json:"riderId,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime *time.Time `json:"endTime,omitempty"`
+	IsActive bool `json:"isActive"`
+	// start of the open pause, nil while tracking
+	PausedAt *time.Time `json:"pausedAt,omitempty"`
+	// incremented on every update, see SessionRepository.Update
+	Version int `json:"version"`
+}
+
+func (s *TrackingSession) Paused() bool {
+	return s.PausedAt != nil
 }
 
 type DomainError struct {
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Organization is a business customer. All tracking, delivery and rider data
+// belongs to exactly one organization.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
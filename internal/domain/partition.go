@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Partition is one monthly partition of location_updates. From and To are
+// zero for the default partition, which catches points outside every month.
+type Partition struct {
+	Name     string     `json:"name"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	Attached bool       `json:"attached"`
+	// planner estimate, -1 until the partition has been analyzed
+	EstimatedRows int64 `json:"estimatedRows"`
+	SizeBytes     int64 `json:"sizeBytes"`
+}
+
+// PartitionStatus reports on the partition maintenance job
+type PartitionStatus struct {
+	LastRunAt  *time.Time   `json:"lastRunAt,omitempty"`
+	LastError  string       `json:"lastError,omitempty"`
+	Created    []string     `json:"created"`
+	Detached   []string     `json:"detached"`
+	Partitions []*Partition `json:"partitions"`
+}
@@ -0,0 +1,69 @@
+package domain
+
+import "time"
+
+// RateCard prices a vehicle type for an organization. Amounts are in minor
+// units of Currency, e.g. cents.
+type RateCard struct {
+	VehicleType VehicleType `json:"vehicleType"`
+	Currency    string      `json:"currency"`
+	BaseFare    int64       `json:"baseFare"`
+	PerKm       int64       `json:"perKm"`
+	PerMinute   int64       `json:"perMinute"`
+	MinimumFare int64       `json:"minimumFare"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// SurgeZone raises fares for trips starting within RadiusMeters of Center
+// while it is in effect. A nil VehicleType applies to every vehicle.
+type SurgeZone struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	VehicleType  *VehicleType `json:"vehicleType,omitempty"`
+	Center       Coordinate   `json:"center"`
+	RadiusMeters float64      `json:"radiusMeters"`
+	Multiplier   float64      `json:"multiplier"`
+	StartsAt     *time.Time   `json:"startsAt,omitempty"`
+	EndsAt       *time.Time   `json:"endsAt,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
+
+type FareKind string
+
+const (
+	// estimated before the trip from the straight line between pickup and drop-off
+	FareQuote FareKind = "quote"
+	// computed from the session's recorded route once it stopped
+	FareFinal FareKind = "final"
+)
+
+// FareLine is one item of a fare's breakdown
+type FareLine struct {
+	Code        string  `json:"code"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	UnitPrice   int64   `json:"unitPrice,omitempty"`
+	Amount      int64   `json:"amount"`
+}
+
+// Fare is a priced trip with its itemized breakdown. Total is the sum of the
+// lines' amounts.
+type Fare struct {
+	Kind        FareKind    `json:"kind"`
+	SessionID   string      `json:"sessionId,omitempty"`
+	DeliveryID  string      `json:"deliveryId,omitempty"`
+	VehicleType VehicleType `json:"vehicleType"`
+	Currency    string      `json:"currency"`
+
+	DistanceMeters  float64 `json:"distanceMeters"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// not billed, final fares only
+	PausedSeconds   float64 `json:"pausedSeconds,omitempty"`
+	SurgeMultiplier float64 `json:"surgeMultiplier"`
+	SurgeZoneID     *string `json:"surgeZoneId,omitempty"`
+
+	Lines      []FareLine `json:"lines"`
+	Total      int64      `json:"total"`
+	ComputedAt time.Time  `json:"computedAt"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+type PrivacyRequestKind string
+
+const (
+	PrivacyExport  PrivacyRequestKind = "export"
+	PrivacyErasure PrivacyRequestKind = "erasure"
+)
+
+// PrivacyRequest is the audit record of an export or erasure of a rider's data
+type PrivacyRequest struct {
+	ID           string             `json:"id"`
+	RiderID      string             `json:"riderId"`
+	Kind         PrivacyRequestKind `json:"kind"`
+	Reason       string             `json:"reason,omitempty"`
+	SourceIP     string             `json:"sourceIp,omitempty"`
+	SessionCount int                `json:"sessionCount"`
+	PointCount   int64              `json:"pointCount"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+// RiderExport is everything stored about a rider's movements
+type RiderExport struct {
+	Rider     *Rider            `json:"rider"`
+	Sessions  []*ExportSession  `json:"sessions"`
+	Locations []*LocationUpdate `json:"-"`
+}
+
+type ExportSession struct {
+	SessionID  string     `json:"sessionId"`
+	DeliveryID string     `json:"deliveryId,omitempty"`
+	StartTime  time.Time  `json:"startTime"`
+	EndTime    *time.Time `json:"endTime,omitempty"`
+	IsActive   bool       `json:"isActive"`
+	// set once the session's points were archived under retention
+	Summary *SessionSummary `json:"summary,omitempty"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+type ProofKind string
+
+const (
+	ProofPhoto     ProofKind = "photo"
+	ProofSignature ProofKind = "signature"
+	// the recipient read out the delivery's one-time PIN
+	ProofPIN ProofKind = "pin"
+)
+
+// DeliveryProof is evidence a delivery was handed over, stamped with where
+// the rider was when it was captured
+type DeliveryProof struct {
+	ID         string    `json:"id"`
+	DeliveryID string    `json:"deliveryId"`
+	SessionID  string    `json:"sessionId"`
+	Kind       ProofKind `json:"kind"`
+
+	// photos and signatures only
+	ContentType string `json:"contentType,omitempty"`
+	SizeBytes   int64  `json:"sizeBytes,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	BlobKey     string `json:"-"`
+
+	// the rider's latest position at capture time, nil if none was recorded
+	Location           *Coordinate `json:"location,omitempty"`
+	LocationAccuracy   *float64    `json:"locationAccuracy,omitempty"`
+	LocationRecordedAt *time.Time  `json:"locationRecordedAt,omitempty"`
+
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// DeliveryPIN is the one-time code the recipient gives the rider at drop-off.
+// Only its hash is stored.
+type DeliveryPIN struct {
+	DeliveryID string
+	PINHash    []byte
+	Attempts   int
+	ExpiresAt  time.Time
+	VerifiedAt *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// RetentionPolicy is how long an organization keeps raw location points
+type RetentionPolicy struct {
+	OrganizationID        string    `json:"organizationId"`
+	LocationRetentionDays int       `json:"locationRetentionDays"`
+	CreatedAt             time.Time `json:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt"`
+}
+
+// SessionSummary is kept for a session after its raw points were archived
+type SessionSummary struct {
+	SessionID       string       `json:"sessionId"`
+	PointCount      int          `json:"pointCount"`
+	DistanceMeters  float64      `json:"distanceMeters"`
+	FirstRecordedAt time.Time    `json:"firstRecordedAt"`
+	LastRecordedAt  time.Time    `json:"lastRecordedAt"`
+	// empty once the session was erased
+	Route      []Coordinate `json:"route"`
+	ArchiveKey string       `json:"archiveKey,omitempty"`
+	ArchivedAt time.Time    `json:"archivedAt"`
+	ErasedAt   *time.Time   `json:"erasedAt,omitempty"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+type VehicleType string
+
+const (
+	VehicleBicycle    VehicleType = "bicycle"
+	VehicleMotorcycle VehicleType = "motorcycle"
+	VehicleCar        VehicleType = "car"
+	VehicleVan        VehicleType = "van"
+)
+
+func (v VehicleType) Valid() bool {
+	switch v {
+	case VehicleBicycle, VehicleMotorcycle, VehicleCar, VehicleVan:
+		return true
+	}
+	return false
+}
+
+type RiderAvailability string
+
+const (
+	RiderOffline   RiderAvailability = "offline"
+	RiderAvailable RiderAvailability = "available"
+	RiderBusy      RiderAvailability = "busy"
+	RiderOnBreak   RiderAvailability = "on_break"
+)
+
+func (a RiderAvailability) Valid() bool {
+	switch a {
+	case RiderOffline, RiderAvailable, RiderBusy, RiderOnBreak:
+		return true
+	}
+	return false
+}
+
+type Rider struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Phone        string            `json:"phone"`
+	Email        string            `json:"email,omitempty"`
+	VehicleType  VehicleType       `json:"vehicleType"`
+	VehiclePlate string            `json:"vehiclePlate,omitempty"`
+	Availability RiderAvailability `json:"availability"`
+	ShiftStart   *time.Time        `json:"shiftStart"`
+	ShiftEnd     *time.Time        `json:"shiftEnd"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	// set once the rider's personal data was erased
+	ErasedAt *time.Time `json:"erasedAt,omitempty"`
+}
+
+// OnShift reports whether the rider has started a shift that has not ended
+func (r *Rider) OnShift() bool {
+	return r.ShiftStart != nil && r.ShiftEnd == nil
+}
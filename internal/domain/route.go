@@ -0,0 +1,26 @@
+package domain
+
+type Coordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type MatchedPoint struct {
+	LocationID int64      `json:"locationId"`
+	Original   Coordinate `json:"original"`
+	Snapped    Coordinate `json:"snapped"`
+	WayID      int64      `json:"wayId"`
+	RoadName   string     `json:"roadName,omitempty"`
+}
+
+// MatchedRoute is a session's GPS trace snapped onto the road network
+type MatchedRoute struct {
+	SessionID string         `json:"sessionId"`
+	Points    []MatchedPoint `json:"points"`
+	Geometry  []Coordinate   `json:"geometry"`
+	// distance along the road network in meters
+	RoadDistance float64 `json:"roadDistance"`
+	// straight-line distance between consecutive raw GPS fixes in meters
+	RawDistance float64 `json:"rawDistance"`
+	Breaks      int     `json:"breaks"`
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// SessionPause is a stretch of a session during which the rider paused tracking
+type SessionPause struct {
+	StartedAt time.Time `json:"startedAt"`
+	// nil while the pause is still open
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+}
+
+// SessionStats sums up a session's route. Paused time is reported separately
+// and left out of the active duration, which is what gets billed.
+type SessionStats struct {
+	SessionID      string  `json:"sessionId"`
+	IsActive       bool    `json:"isActive"`
+	Paused         bool    `json:"paused"`
+	PointCount     int     `json:"pointCount"`
+	DistanceMeters float64 `json:"distanceMeters"`
+	// wall clock time from start to stop, or to now for active sessions
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	PausedSeconds  float64 `json:"pausedSeconds"`
+	ActiveSeconds  float64 `json:"activeSeconds"`
+	// average over active time in meters per second
+	AverageSpeed float64        `json:"averageSpeed"`
+	Pauses       []SessionPause `json:"pauses"`
+}
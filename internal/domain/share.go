@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// ShareLink grants unauthenticated, read-only access to one delivery's tracking
+type ShareLink struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organizationId"`
+	DeliveryID     string     `json:"deliveryId"`
+	TokenHash      []byte     `json:"-"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func (l *ShareLink) Usable(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
+
+// SharedTracking is what a recipient sees through a share link. Location and
+// ETA are only set while the delivery is on its way.
+type SharedTracking struct {
+	DeliveryID string         `json:"deliveryId"`
+	Status     DeliveryStatus `json:"status"`
+	Dropoff    Coordinate     `json:"dropoff"`
+
+	Location           *Coordinate `json:"location,omitempty"`
+	LocationRecordedAt *time.Time  `json:"locationRecordedAt,omitempty"`
+	// meters left to the drop-off, by way of the pickup until it is collected
+	DistanceRemaining *float64   `json:"distanceRemaining,omitempty"`
+	ETA               *time.Time `json:"eta,omitempty"`
+
+	PickedUpAt  *time.Time `json:"pickedUpAt,omitempty"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookEndpoint struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organizationId"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"`
+	EventTypes     []string  `json:"eventTypes"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// Subscribes reports whether the endpoint wants events of the given type
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event queued for one endpoint
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	EndpointID     string                `json:"endpointId"`
+	OrganizationID string                `json:"organizationId"`
+	EventID        string                `json:"eventId"`
+	EventType      string                `json:"eventType"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	LastError      *string               `json:"lastError"`
+	CreatedAt      time.Time             `json:"createdAt"`
+
+	// set when the delivery is claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookAttempt struct {
+	DeliveryID  string    `json:"deliveryId"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  *int      `json:"statusCode"`
+	Error       *string   `json:"error"`
+	Duration    int64     `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
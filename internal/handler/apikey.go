@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// apiKeySecretResponse is only returned when a key is created or rotated
+type apiKeySecretResponse struct {
+	*domain.APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// HandleCreate serves POST /organizations/{organizationID}/api-keys
+func (h *APIKeyHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	key, secret, err := h.apiKeyService.CreateKey(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiKeySecretResponse{APIKey: key, Key: secret})
+}
+
+// HandleList serves GET /organizations/{organizationID}/api-keys
+func (h *APIKeyHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeyService.ListKeys(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if keys == nil {
+		keys = []*domain.APIKey{}
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// HandleRotate serves POST /organizations/{organizationID}/api-keys/{keyID}/rotate
+func (h *APIKeyHandler) HandleRotate(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("keyID")) {
+		writeError(w, http.StatusNotFound, "API_KEY_NOT_FOUND", "api key does not exist")
+		return
+	}
+
+	key, secret, err := h.apiKeyService.RotateKey(r.Context(), r.PathValue("keyID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiKeySecretResponse{APIKey: key, Key: secret})
+}
+
+// HandleRevoke serves DELETE /organizations/{organizationID}/api-keys/{keyID}
+func (h *APIKeyHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("keyID")) {
+		writeError(w, http.StatusNotFound, "API_KEY_NOT_FOUND", "api key does not exist")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(r.Context(), r.PathValue("keyID")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
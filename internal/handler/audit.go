@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type AuditHandler struct {
+	auditLog *service.AuditLog
+}
+
+func NewAuditHandler(auditLog *service.AuditLog) *AuditHandler {
+	return &AuditHandler{auditLog: auditLog}
+}
+
+// HandleList serves GET /audit?entityType=&entityId=&actorId=&action=&from=&to=&before=&limit=
+// from and to are RFC3339 timestamps. Entries come newest first; pass the id of
+// the last one as before to get the next page.
+func (h *AuditHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		EntityType: query.Get("entityType"),
+		EntityID:   query.Get("entityId"),
+		ActorID:    query.Get("actorId"),
+		Action:     query.Get("action"),
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_FROM", "from must be an RFC3339 timestamp")
+			return
+		}
+		filter.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_TO", "to must be an RFC3339 timestamp")
+			return
+		}
+		filter.To = &to
+	}
+	if v := query.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || before <= 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_CURSOR", "before must be an audit entry id")
+			return
+		}
+		filter.BeforeID = before
+	}
+	filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+
+	entries, err := h.auditLog.List(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if entries == nil {
+		entries = []*domain.AuditEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
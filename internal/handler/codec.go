@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/msgpack"
+	"github.com/gorilla/websocket"
+)
+
+// Unversioned subprotocols a rider connection can negotiate with
+// Sec-WebSocket-Protocol, kept for apps predating easebox.v1. Without one the
+// connection speaks JSON text frames.
+const (
+	SubprotocolJSON    = "easebox.json"
+	SubprotocolMsgpack = "easebox.msgpack"
+)
+
+// frameCodec converts between websocket frames and the JSON message types.
+// Every encoding decodes to JSON first, so handlers never see the wire format.
+type frameCodec interface {
+	Decode(messageType int, data []byte) ([]byte, error)
+	Encode(v interface{}) (int, []byte, error)
+}
+
+func codecFor(subprotocol string) frameCodec {
+	if subprotocol == SubprotocolMsgpack || subprotocol == ProtocolV1Msgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(messageType int, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (jsonCodec) Encode(v interface{}) (int, []byte, error) {
+	data, err := json.Marshal(v)
+	return websocket.TextMessage, data, err
+}
+
+// msgpackCodec carries the JSON message shapes as MessagePack maps in binary
+// frames. Text frames are still read as JSON so a client can fall back.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Decode(messageType int, data []byte) ([]byte, error) {
+	if messageType == websocket.TextMessage {
+		return data, nil
+	}
+
+	value, err := msgpack.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// go through JSON so the struct tags stay the single source of field names
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack frame is not JSON shaped: %w", err)
+	}
+	return raw, nil
+}
+
+func (msgpackCodec) Encode(v interface{}) (int, []byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// numbers stay exact, a float64 would round large int64 ids
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return 0, nil, err
+	}
+
+	data, err := msgpack.Marshal(value)
+	return websocket.BinaryMessage, data, err
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type issueCredentialRequest struct {
+	Name string `json:"name"`
+}
+
+// riderCredentialSecretResponse is only returned when a credential is issued
+type riderCredentialSecretResponse struct {
+	*domain.RiderCredential
+	Credential string `json:"credential"`
+}
+
+type ticketResponse struct {
+	Ticket    string    `json:"ticket"`
+	Scope     string    `json:"scope"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type CredentialHandler struct {
+	credentialService *service.RiderCredentialService
+	ticketService     *service.TicketService
+}
+
+func NewCredentialHandler(credentialService *service.RiderCredentialService, ticketService *service.TicketService) *CredentialHandler {
+	return &CredentialHandler{credentialService: credentialService, ticketService: ticketService}
+}
+
+// HandleIssue serves POST /riders/{riderID}/credentials with an optional
+// {"name": "..."} naming the device
+func (h *CredentialHandler) HandleIssue(w http.ResponseWriter, r *http.Request) {
+	riderID := r.PathValue("riderID")
+	if !uuidPattern.MatchString(riderID) {
+		writeError(w, http.StatusNotFound, "RIDER_NOT_FOUND", "rider does not exist")
+		return
+	}
+
+	var req issueCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	credential, secret, err := h.credentialService.IssueCredential(r.Context(), riderID, req.Name)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, riderCredentialSecretResponse{RiderCredential: credential, Credential: secret})
+}
+
+// HandleList serves GET /riders/{riderID}/credentials
+func (h *CredentialHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	riderID := r.PathValue("riderID")
+	if !uuidPattern.MatchString(riderID) {
+		writeError(w, http.StatusNotFound, "RIDER_NOT_FOUND", "rider does not exist")
+		return
+	}
+
+	credentials, err := h.credentialService.ListCredentials(r.Context(), riderID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if credentials == nil {
+		credentials = []*domain.RiderCredential{}
+	}
+
+	writeJSON(w, http.StatusOK, credentials)
+}
+
+// HandleRevoke serves DELETE /riders/{riderID}/credentials/{credentialID}
+func (h *CredentialHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	riderID, credentialID := r.PathValue("riderID"), r.PathValue("credentialID")
+	if !uuidPattern.MatchString(riderID) || !uuidPattern.MatchString(credentialID) {
+		writeError(w, http.StatusNotFound, "RIDER_CREDENTIAL_NOT_FOUND", "rider credential does not exist")
+		return
+	}
+
+	if err := h.credentialService.RevokeCredential(r.Context(), riderID, credentialID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTicket returns a handler issuing single use tickets for scope to the
+// authenticated caller, for clients that cannot send their credential in a
+// header on the route that needs it
+func (h *CredentialHandler) HandleTicket(scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ticket, err := h.ticketService.Issue(r.Context(), scope)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, ticketResponse{Ticket: token, Scope: ticket.Scope, ExpiresAt: ticket.ExpiresAt})
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type createDeliveryRequest struct {
+	Pickup  *domain.Coordinate `json:"pickup"`
+	Dropoff *domain.Coordinate `json:"dropoff"`
+}
+
+type DeliveryHandler struct {
+	dispatchService *service.DispatchService
+}
+
+func NewDeliveryHandler(dispatchService *service.DispatchService) *DeliveryHandler {
+	return &DeliveryHandler{dispatchService: dispatchService}
+}
+
+// HandleCreate serves POST /deliveries
+func (h *DeliveryHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createDeliveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	if req.Pickup == nil || req.Dropoff == nil {
+		writeError(w, http.StatusBadRequest, "MISSING_LOCATION", "pickup and dropoff are required")
+		return
+	}
+
+	delivery, err := h.dispatchService.CreateDelivery(r.Context(), *req.Pickup, *req.Dropoff)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, delivery)
+}
+
+// HandleGet serves GET /deliveries/{deliveryID}
+func (h *DeliveryHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.dispatchService.GetDelivery(r.Context(), r.PathValue("deliveryID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+// HandleDispatch serves POST /deliveries/{deliveryID}/dispatch, retrying an unassigned delivery
+func (h *DeliveryHandler) HandleDispatch(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("deliveryID")) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	delivery, err := h.dispatchService.DispatchAsync(r.Context(), r.PathValue("deliveryID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, delivery)
+}
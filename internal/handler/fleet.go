@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/gorilla/websocket"
+)
+
+type FleetHandler struct {
+	fleetService *service.FleetService
+	events       service.EventSubscriber
+	pagePath     string
+	upgrader     websocket.Upgrader
+}
+
+// NewFleetHandler creates a handler serving the dispatcher dashboard from pagePath
+func NewFleetHandler(fleetService *service.FleetService, events service.EventSubscriber, pagePath string) *FleetHandler {
+	return &FleetHandler{
+		fleetService: fleetService,
+		events:       events,
+		pagePath:     pagePath,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// HandlePage serves GET /dashboard. The page asks for an api key and calls
+// the fleet endpoints with it.
+func (h *FleetHandler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, h.pagePath)
+}
+
+// HandleListSessions serves GET /fleet/sessions?status=assigned,in_transit
+func (h *FleetHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	var statuses []string
+	if raw := r.URL.Query().Get("status"); raw != "" {
+		statuses = strings.Split(raw, ",")
+	}
+
+	sessions, err := h.fleetService.ListActiveSessions(r.Context(), statuses)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if sessions == nil {
+		sessions = []*domain.FleetSession{}
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// HandlePositions serves GET /fleet/positions, the latest location of active
+// sessions. It takes one of ?sessionId=a,b, ?bbox=minLng,minLat,maxLng,maxLat
+// or ?lat=&lng=&radius= (meters).
+func (h *FleetHandler) HandlePositions(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	var query service.PositionQuery
+
+	if raw := params.Get("sessionId"); raw != "" {
+		query.SessionIDs = strings.Split(raw, ",")
+	}
+
+	if raw := params.Get("bbox"); raw != "" {
+		values, ok := parseFloats(raw, 4)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "INVALID_BOUNDS", "bbox must be minLng,minLat,maxLng,maxLat")
+			return
+		}
+		query.Bounds = &domain.BoundingBox{
+			MinLongitude: values[0],
+			MinLatitude:  values[1],
+			MaxLongitude: values[2],
+			MaxLatitude:  values[3],
+		}
+	}
+
+	if params.Has("lat") || params.Has("lng") || params.Has("radius") {
+		values, ok := parseFloats(params.Get("lat")+","+params.Get("lng")+","+params.Get("radius"), 3)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "INVALID_RADIUS", "lat, lng and radius must all be numbers")
+			return
+		}
+		query.Center = &domain.Coordinate{Latitude: values[0], Longitude: values[1]}
+		query.Radius = values[2]
+	}
+
+	positions, err := h.fleetService.LatestPositions(r.Context(), query)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if positions == nil {
+		positions = []*domain.LocationUpdate{}
+	}
+
+	writeJSON(w, http.StatusOK, positions)
+}
+
+// parseFloats parses exactly n comma separated numbers
+func parseFloats(raw string, n int) ([]float64, bool) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+
+	values := make([]float64, n)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// HandleLive serves GET /fleet/live, a websocket pushing the organization's
+// tracking events as {"type": "event", "payload": <event>}
+func (h *FleetHandler) HandleLive(w http.ResponseWriter, r *http.Request) {
+	organizationID, err := tenant.Require(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade dashboard connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// a dashboard cut off for falling behind reconnects and reloads the fleet
+	subscription := h.events.Subscribe(organizationID, fleetFilter)
+	defer subscription.Close()
+
+	client := newClient(conn)
+
+	// the dashboard only listens, reading detects when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			if err := client.send(OutgoingMessage{Type: "event", Payload: event}); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -1 +1,111 @@
-package handler
\ No newline at end of file
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// status codes for domain errors that should not be reported as 400 Bad Request
+var domainErrorStatus = map[string]int{
+	"MAP_MATCHING_DISABLED":      http.StatusServiceUnavailable,
+	"DELIVERY_NOT_FOUND":         http.StatusNotFound,
+	"DELIVERY_NOT_DISPATCHABLE":  http.StatusConflict,
+	"DELIVERY_NOT_ASSIGNED":      http.StatusForbidden,
+	"DISPATCH_IN_PROGRESS":       http.StatusConflict,
+	"RIDER_NOT_FOUND":            http.StatusNotFound,
+	"RIDER_OFF_SHIFT":            http.StatusConflict,
+	"SHIFT_ALREADY_STARTED":      http.StatusConflict,
+	"SHIFT_NOT_STARTED":          http.StatusConflict,
+	"ORGANIZATION_NOT_FOUND":     http.StatusUnauthorized,
+	"INVALID_API_KEY":            http.StatusUnauthorized,
+	"INVALID_RIDER_CREDENTIAL":   http.StatusUnauthorized,
+	"RIDER_CREDENTIAL_NOT_FOUND": http.StatusNotFound,
+	"INVALID_TICKET":             http.StatusUnauthorized,
+	"RIDER_MISMATCH":             http.StatusForbidden,
+	"API_KEY_NOT_FOUND":          http.StatusNotFound,
+	"API_KEY_REVOKED":            http.StatusConflict,
+	"WEBHOOK_ENDPOINT_NOT_FOUND": http.StatusNotFound,
+	"WEBHOOK_DELIVERY_NOT_FOUND": http.StatusNotFound,
+	"SHARE_LINK_NOT_FOUND":       http.StatusNotFound,
+	"SESSION_NOT_FOUND":          http.StatusNotFound,
+	"SESSION_STILL_ACTIVE":       http.StatusConflict,
+	"INGEST_BUSY":                http.StatusServiceUnavailable,
+	"INGEST_CLOSED":              http.StatusServiceUnavailable,
+	"RETENTION_POLICY_NOT_FOUND": http.StatusNotFound,
+	"SESSION_SUMMARY_NOT_FOUND":  http.StatusNotFound,
+	"RIDER_ALREADY_ERASED":       http.StatusConflict,
+	"RIDER_HAS_ACTIVE_SESSION":   http.StatusConflict,
+	"SESSION_CONFLICT":           http.StatusConflict,
+	"SESSION_PAUSED":             http.StatusConflict,
+	"SESSION_ALREADY_PAUSED":     http.StatusConflict,
+	"SESSION_NOT_PAUSED":         http.StatusConflict,
+	"PROOF_NOT_FOUND":            http.StatusNotFound,
+	"PROOF_HAS_NO_CONTENT":       http.StatusNotFound,
+	"PROOF_TOO_LARGE":            http.StatusRequestEntityTooLarge,
+	"UNSUPPORTED_PROOF_TYPE":     http.StatusUnsupportedMediaType,
+	"PROOF_SESSION_MISMATCH":     http.StatusForbidden,
+	"DELIVERY_NOT_IN_TRANSIT":    http.StatusConflict,
+	"PIN_NOT_ISSUED":             http.StatusNotFound,
+	"PIN_ALREADY_USED":           http.StatusConflict,
+	"PIN_EXPIRED":                http.StatusGone,
+	"PIN_LOCKED":                 http.StatusLocked,
+	"PINS_DISABLED":              http.StatusServiceUnavailable,
+	"RATE_CARD_NOT_FOUND":        http.StatusNotFound,
+	"SURGE_ZONE_NOT_FOUND":       http.StatusNotFound,
+	"FARE_NOT_FOUND":             http.StatusNotFound,
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Code: code, Message: message})
+}
+
+// writeServiceError maps errors returned by the service layer to a response.
+// Domain errors are client errors, anything else is logged and hidden.
+func writeServiceError(w http.ResponseWriter, err error) {
+	var domainErr *domain.DomainError
+	if errors.As(err, &domainErr) {
+		status, ok := domainErrorStatus[domainErr.Code]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, domainErr.Code, domainErr.Message)
+		return
+	}
+
+	if errors.Is(err, tenant.ErrMissingTenant) {
+		writeError(w, http.StatusUnauthorized, "MISSING_ORGANIZATION", "an organization id is required")
+		return
+	}
+
+	log.Printf("Service error: %v", err)
+	writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+// clientIP is the address the request came from, for audit records
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
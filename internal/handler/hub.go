@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/gorilla/websocket"
+)
+
+const writeTimeout = 10 * time.Second
+
+type OutgoingMessage struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// client wraps a websocket connection so that it can be written to from
+// multiple goroutines (gorilla allows only one concurrent writer)
+type client struct {
+	conn    *websocket.Conn
+	codec   frameCodec
+	writeMu sync.Mutex
+}
+
+// newClient wraps conn, encoding messages in the subprotocol it negotiated
+func newClient(conn *websocket.Conn) *client {
+	return &client{conn: conn, codec: codecFor(conn.Subprotocol())}
+}
+
+func (c *client) send(msg interface{}) error {
+	messageType, data, err := c.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// sessionKey identifies a session within its organization, so a client cannot
+// receive another organization's messages by claiming its session id
+type sessionKey struct {
+	organizationID string
+	sessionID      string
+}
+
+// Hub keeps track of which connection each tracking session is using so the
+// server can push messages to riders. Messages go through the channel, every
+// instance's hub hears them and the one holding the session sends it.
+type Hub struct {
+	channel service.RiderChannel
+
+	mu       sync.RWMutex
+	sessions map[sessionKey]*client
+}
+
+func NewHub(channel service.RiderChannel) *Hub {
+	return &Hub{channel: channel, sessions: make(map[sessionKey]*client)}
+}
+
+// Run sends the messages for sessions connected to this instance until ctx
+// is cancelled
+func (h *Hub) Run(ctx context.Context) error {
+	return h.channel.Listen(ctx, h.deliver)
+}
+
+func (h *Hub) deliver(organizationID, sessionID, msgType string, payload json.RawMessage) {
+	h.mu.RLock()
+	c, ok := h.sessions[sessionKey{organizationID, sessionID}]
+	h.mu.RUnlock()
+
+	// connected to another instance, or not at all
+	if !ok {
+		return
+	}
+
+	if err := c.send(OutgoingMessage{Type: msgType, Payload: payload}); err != nil {
+		log.Printf("Failed to send %s to session %s: %v", msgType, sessionID, err)
+	}
+}
+
+func (h *Hub) register(ctx context.Context, sessionID string, c *client) {
+	organizationID, _ := tenant.FromContext(ctx)
+
+	h.mu.Lock()
+	h.sessions[sessionKey{organizationID, sessionID}] = c
+	h.mu.Unlock()
+}
+
+// unregister removes every session bound to the client
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, registered := range h.sessions {
+		if registered == c {
+			delete(h.sessions, key)
+		}
+	}
+}
+
+// SendToSession implements service.RiderNotifier
+func (h *Hub) SendToSession(ctx context.Context, sessionID string, msgType string, payload interface{}) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return h.channel.Send(ctx, organizationID, sessionID, msgType, payload)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/gorilla/websocket"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// TenantMiddleware scopes operator requests to the organization named in the
+// path. It does not authenticate, so it must sit behind the admin middleware;
+// every other route takes its organization from the caller's credential.
+type TenantMiddleware struct {
+	organizationService *service.OrganizationService
+}
+
+func NewTenantMiddleware(organizationService *service.OrganizationService) *TenantMiddleware {
+	return &TenantMiddleware{organizationService: organizationService}
+}
+
+func (m *TenantMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		organizationID := r.PathValue("organizationID")
+		if organizationID == "" {
+			writeError(w, http.StatusUnauthorized, "MISSING_ORGANIZATION", "an organization id is required")
+			return
+		}
+
+		if !uuidPattern.MatchString(organizationID) {
+			writeError(w, http.StatusUnauthorized, "ORGANIZATION_NOT_FOUND", "organization does not exist")
+			return
+		}
+
+		if _, err := m.organizationService.GetOrganization(r.Context(), organizationID); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(tenant.WithOrganization(r.Context(), organizationID)))
+	})
+}
+
+// APIKeyMiddleware authenticates server-to-server requests made with an
+// organization's API key and scopes them to that organization. Browsers cannot
+// set headers on a websocket handshake or an EventSource, those redeem a ticket
+// issued to the key instead.
+type APIKeyMiddleware struct {
+	apiKeyService *service.APIKeyService
+	ticketService *service.TicketService
+}
+
+func NewAPIKeyMiddleware(apiKeyService *service.APIKeyService, ticketService *service.TicketService) *APIKeyMiddleware {
+	return &APIKeyMiddleware{apiKeyService: apiKeyService, ticketService: ticketService}
+}
+
+// Require only lets requests through whose key carries the given scope, or
+// that redeem a ticket issued for it
+func (m *APIKeyMiddleware) Require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawKey, ok := bearerToken(r)
+		if !ok {
+			if ticket, ok := ticketFromRequest(r); ok {
+				redeemTicket(w, r, m.ticketService, ticket, scope, next)
+				return
+			}
+			writeError(w, http.StatusUnauthorized, "MISSING_API_KEY", "an api key is required")
+			return
+		}
+
+		key, err := m.apiKeyService.Authenticate(r.Context(), rawKey)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		if !key.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "api key requires the "+scope+" scope")
+			return
+		}
+
+		ctx := tenant.WithOrganization(r.Context(), key.OrganizationID)
+		ctx = actor.With(ctx, domain.Actor{Type: domain.ActorAPIKey, ID: key.ID, SourceIP: clientIP(r)})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RiderMiddleware authenticates rider facing routes. A rider's app signs in
+// with its rider credential and acts for that rider; integrations may use an
+// api key holding the route's scope. Browsers cannot set headers on the
+// websocket handshake and redeem a ticket instead. The organization always
+// comes from the credential, never from the request.
+type RiderMiddleware struct {
+	credentialService *service.RiderCredentialService
+	ticketService     *service.TicketService
+	apiKeys           *APIKeyMiddleware
+}
+
+func NewRiderMiddleware(credentialService *service.RiderCredentialService, ticketService *service.TicketService, apiKeys *APIKeyMiddleware) *RiderMiddleware {
+	return &RiderMiddleware{credentialService: credentialService, ticketService: ticketService, apiKeys: apiKeys}
+}
+
+func (m *RiderMiddleware) Require(scope string, next http.Handler) http.Handler {
+	withKey := m.apiKeys.Require(scope, next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			if ticket, ok := ticketFromRequest(r); ok {
+				redeemTicket(w, r, m.ticketService, ticket, scope, next)
+				return
+			}
+			writeError(w, http.StatusUnauthorized, "MISSING_CREDENTIAL", "a rider credential or api key is required")
+			return
+		}
+
+		if !service.IsRiderCredential(token) {
+			withKey.ServeHTTP(w, r)
+			return
+		}
+
+		credential, err := m.credentialService.Authenticate(r.Context(), token)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		ctx := tenant.WithOrganization(r.Context(), credential.OrganizationID)
+		ctx = actor.With(ctx, domain.Actor{Type: domain.ActorRiderApp, ID: credential.RiderID, SourceIP: clientIP(r)})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ticketFromRequest reads a ticket from the query string of a websocket
+// handshake or an event stream, the only place a browser can put it
+func ticketFromRequest(r *http.Request) (string, bool) {
+	if !websocket.IsWebSocketUpgrade(r) && !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return "", false
+	}
+
+	ticket := r.URL.Query().Get("ticket")
+	return ticket, ticket != ""
+}
+
+// redeemTicket serves the request as the actor the ticket was issued to
+func redeemTicket(w http.ResponseWriter, r *http.Request, ticketService *service.TicketService, token, scope string, next http.Handler) {
+	ticket, err := ticketService.Redeem(r.Context(), token, scope)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	issuedTo := ticket.Actor
+	issuedTo.SourceIP = clientIP(r)
+
+	ctx := tenant.WithOrganization(r.Context(), ticket.OrganizationID)
+	ctx = actor.With(ctx, issuedTo)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// AdminMiddleware protects operator endpoints with a static token. They are
+// disabled when no token is configured.
+type AdminMiddleware struct {
+	token string
+}
+
+func NewAdminMiddleware(token string) *AdminMiddleware {
+	return &AdminMiddleware{token: token}
+}
+
+func (m *AdminMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.token == "" {
+			writeError(w, http.StatusServiceUnavailable, "ADMIN_DISABLED", "admin endpoints are disabled")
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "INVALID_ADMIN_TOKEN", "admin token is invalid")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(actor.With(r.Context(), domain.Actor{Type: domain.ActorAdmin, SourceIP: clientIP(r)})))
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
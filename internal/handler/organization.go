@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type createOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationHandler struct {
+	organizationService *service.OrganizationService
+}
+
+func NewOrganizationHandler(organizationService *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{organizationService: organizationService}
+}
+
+// HandleCreate serves POST /organizations
+func (h *OrganizationHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	organization, err := h.organizationService.CreateOrganization(r.Context(), req.Name)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, organization)
+}
+
+// HandleGet serves GET /organizations/{organizationID}
+func (h *OrganizationHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	organizationID := r.PathValue("organizationID")
+	if !uuidPattern.MatchString(organizationID) {
+		writeError(w, http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "organization does not exist")
+		return
+	}
+
+	organization, err := h.organizationService.GetOrganization(r.Context(), organizationID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, organization)
+}
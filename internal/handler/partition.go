@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+// PartitionHandler exposes the location partition maintenance job to operators
+type PartitionHandler struct {
+	maintainer *service.PartitionMaintainer
+}
+
+func NewPartitionHandler(maintainer *service.PartitionMaintainer) *PartitionHandler {
+	return &PartitionHandler{maintainer: maintainer}
+}
+
+// HandleStatus serves GET /admin/partitions
+func (h *PartitionHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.maintainer.Status(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// HandleMaintain serves POST /admin/partitions/maintain, running the job now
+// and returning the status it left
+func (h *PartitionHandler) HandleMaintain(w http.ResponseWriter, r *http.Request) {
+	if err := h.maintainer.Maintain(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "PARTITION_MAINTENANCE_FAILED", err.Error())
+		return
+	}
+
+	h.HandleStatus(w, r)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type quoteRequest struct {
+	Pickup      *domain.Coordinate `json:"pickup"`
+	Dropoff     *domain.Coordinate `json:"dropoff"`
+	VehicleType domain.VehicleType `json:"vehicleType"`
+}
+
+type PricingHandler struct {
+	pricingService *service.PricingService
+}
+
+func NewPricingHandler(pricingService *service.PricingService) *PricingHandler {
+	return &PricingHandler{pricingService: pricingService}
+}
+
+// HandleQuote serves POST /fares/quote with {"pickup": {...}, "dropoff": {...},
+// "vehicleType": "..."}. vehicleType may be left out for the default vehicle.
+func (h *PricingHandler) HandleQuote(w http.ResponseWriter, r *http.Request) {
+	var req quoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	if req.Pickup == nil || req.Dropoff == nil {
+		writeError(w, http.StatusBadRequest, "MISSING_LOCATION", "pickup and dropoff are required")
+		return
+	}
+
+	fare, err := h.pricingService.Quote(r.Context(), *req.Pickup, *req.Dropoff, req.VehicleType)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fare)
+}
+
+// HandleSessionFare serves GET /sessions/{sessionID}/fare, the final fare of a
+// stopped session
+func (h *PricingHandler) HandleSessionFare(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_SESSION_ID", "session id is required")
+		return
+	}
+
+	fare, err := h.pricingService.GetSessionFare(r.Context(), sessionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fare)
+}
+
+// HandleListRateCards serves GET /organizations/{organizationID}/rate-cards
+func (h *PricingHandler) HandleListRateCards(w http.ResponseWriter, r *http.Request) {
+	cards, err := h.pricingService.ListRateCards(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if cards == nil {
+		cards = []*domain.RateCard{}
+	}
+
+	writeJSON(w, http.StatusOK, cards)
+}
+
+// HandleSetRateCard serves PUT /organizations/{organizationID}/rate-cards/{vehicleType}
+// with {"currency": "NGN", "baseFare": 0, "perKm": 0, "perMinute": 0, "minimumFare": 0}
+// in minor units
+func (h *PricingHandler) HandleSetRateCard(w http.ResponseWriter, r *http.Request) {
+	var card domain.RateCard
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+	card.VehicleType = domain.VehicleType(r.PathValue("vehicleType"))
+
+	if err := h.pricingService.SaveRateCard(r.Context(), &card); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, card)
+}
+
+// HandleDeleteRateCard serves DELETE /organizations/{organizationID}/rate-cards/{vehicleType}
+func (h *PricingHandler) HandleDeleteRateCard(w http.ResponseWriter, r *http.Request) {
+	if err := h.pricingService.DeleteRateCard(r.Context(), domain.VehicleType(r.PathValue("vehicleType"))); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListSurgeZones serves GET /organizations/{organizationID}/surge-zones
+func (h *PricingHandler) HandleListSurgeZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.pricingService.ListSurgeZones(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if zones == nil {
+		zones = []*domain.SurgeZone{}
+	}
+
+	writeJSON(w, http.StatusOK, zones)
+}
+
+// HandleCreateSurgeZone serves POST /organizations/{organizationID}/surge-zones
+func (h *PricingHandler) HandleCreateSurgeZone(w http.ResponseWriter, r *http.Request) {
+	var zone domain.SurgeZone
+	if err := json.NewDecoder(r.Body).Decode(&zone); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	if err := h.pricingService.CreateSurgeZone(r.Context(), &zone); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, zone)
+}
+
+// HandleDeleteSurgeZone serves DELETE /organizations/{organizationID}/surge-zones/{zoneID}
+func (h *PricingHandler) HandleDeleteSurgeZone(w http.ResponseWriter, r *http.Request) {
+	zoneID := r.PathValue("zoneID")
+	if !uuidPattern.MatchString(zoneID) {
+		writeError(w, http.StatusNotFound, "SURGE_ZONE_NOT_FOUND", "surge zone does not exist")
+		return
+	}
+
+	if err := h.pricingService.DeleteSurgeZone(r.Context(), zoneID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type eraseRiderRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{privacyService: privacyService}
+}
+
+// HandleExport serves GET /organizations/{organizationID}/riders/{riderID}/export,
+// a zip of everything stored about the rider's movements
+func (h *PrivacyHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	riderID := r.PathValue("riderID")
+
+	export, err := h.privacyService.ExportRider(r.Context(), riderID, clientIP(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="rider-`+riderID+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := service.WriteExportArchive(w, export); err != nil {
+		log.Printf("Failed to write export of rider %s: %v", riderID, err)
+	}
+}
+
+// HandleErase serves POST /organizations/{organizationID}/riders/{riderID}/erasure
+// with an optional {"reason": "..."} body
+func (h *PrivacyHandler) HandleErase(w http.ResponseWriter, r *http.Request) {
+	var req eraseRiderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	request, err := h.privacyService.EraseRider(r.Context(), r.PathValue("riderID"), req.Reason, clientIP(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, request)
+}
+
+// HandleListRequests serves GET /organizations/{organizationID}/riders/{riderID}/privacy-requests
+func (h *PrivacyHandler) HandleListRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.privacyService.ListRequests(r.Context(), r.PathValue("riderID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if requests == nil {
+		requests = []*domain.PrivacyRequest{}
+	}
+
+	writeJSON(w, http.StatusOK, requests)
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+// multipart headers and fields sent alongside the file
+const proofFormOverhead = 64 << 10
+
+type verifyPINRequest struct {
+	SessionID string `json:"sessionId"`
+	PIN       string `json:"pin"`
+}
+
+// issuePINResponse is the only time the PIN is returned
+type issuePINResponse struct {
+	DeliveryID string    `json:"deliveryId"`
+	PIN        string    `json:"pin"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type ProofHandler struct {
+	proofService   *service.ProofService
+	maxUploadBytes int64
+}
+
+func NewProofHandler(proofService *service.ProofService, maxUploadBytes int64) *ProofHandler {
+	return &ProofHandler{proofService: proofService, maxUploadBytes: maxUploadBytes}
+}
+
+// HandleUpload serves POST /deliveries/{deliveryID}/proofs, a multipart form
+// with kind (photo or signature), sessionId and the image as file
+func (h *ProofHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadBytes+proofFormOverhead)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "PROOF_TOO_LARGE", "proof file is larger than "+strconv.FormatInt(h.maxUploadBytes, 10)+" bytes")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be a multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	sessionID := r.FormValue("sessionId")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_SESSION_ID", "session id is required")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "MISSING_FILE", "file is required")
+		return
+	}
+	defer file.Close()
+
+	// one byte over the limit is enough for the service to reject it
+	data, err := io.ReadAll(io.LimitReader(file, h.maxUploadBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "file could not be read")
+		return
+	}
+
+	proof, err := h.proofService.CaptureProof(r.Context(), deliveryID, sessionID, domain.ProofKind(r.FormValue("kind")), data)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, proof)
+}
+
+// HandleVerifyPIN serves POST /deliveries/{deliveryID}/proofs/pin with
+// {"sessionId": "...", "pin": "..."}
+func (h *ProofHandler) HandleVerifyPIN(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	var req verifyPINRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	if req.SessionID == "" || req.PIN == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_FIELDS", "sessionId and pin are required")
+		return
+	}
+
+	proof, err := h.proofService.VerifyPIN(r.Context(), deliveryID, req.SessionID, req.PIN)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, proof)
+}
+
+// HandleIssuePIN serves POST /deliveries/{deliveryID}/pin. The PIN is meant
+// for the recipient and replaces any issued before.
+func (h *ProofHandler) HandleIssuePIN(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	pin, record, err := h.proofService.IssuePIN(r.Context(), deliveryID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, issuePINResponse{DeliveryID: deliveryID, PIN: pin, ExpiresAt: record.ExpiresAt})
+}
+
+// HandleList serves GET /deliveries/{deliveryID}/proofs
+func (h *ProofHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	proofs, err := h.proofService.ListProofs(r.Context(), deliveryID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if proofs == nil {
+		proofs = []*domain.DeliveryProof{}
+	}
+
+	writeJSON(w, http.StatusOK, proofs)
+}
+
+// HandleContent serves GET /deliveries/{deliveryID}/proofs/{proofID}/content,
+// the photo or signature itself
+func (h *ProofHandler) HandleContent(w http.ResponseWriter, r *http.Request) {
+	deliveryID, proofID := r.PathValue("deliveryID"), r.PathValue("proofID")
+	if !uuidPattern.MatchString(deliveryID) || !uuidPattern.MatchString(proofID) {
+		writeError(w, http.StatusNotFound, "PROOF_NOT_FOUND", "proof of delivery does not exist")
+		return
+	}
+
+	proof, data, err := h.proofService.GetProofContent(r.Context(), deliveryID, proofID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", proof.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "private, max-age=0")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Failed to write proof %s: %v", proof.ID, err)
+	}
+}
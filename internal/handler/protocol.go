@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+)
+
+// Versioned rider protocol. Its messages are described by the JSON Schema
+// published at /schema/easebox.v1.json. Connections that negotiate it are
+// validated strictly and told about every rejected message with an error
+// frame; connections without it keep the lenient legacy behaviour.
+const (
+	ProtocolV1        = "easebox.v1"
+	ProtocolV1Msgpack = "easebox.v1+msgpack"
+	protocolVersion   = 1
+	protocolSchemaURL = "/schema/easebox.v1.json"
+)
+
+// Message types sent by riders
+const (
+	MessageHello          = "hello"
+	MessageStart          = "start"
+	MessageLocationUpdate = "location_update"
+	MessageStop           = "stop"
+	MessagePause          = "pause"
+	MessageResume         = "resume"
+	MessageOfferAccept    = "offer_accept"
+	MessageOfferDecline   = "offer_decline"
+)
+
+// Message types sent by the server, besides hello
+const (
+	MessageError        = "error"
+	MessageOffer        = "offer"
+	MessageOfferExpired = "offer_expired"
+	MessageAssignment   = "assignment"
+)
+
+// How far a location's timestamp may be from the server's clock. Apps buffer
+// points while offline, but not for longer than a day.
+const (
+	maxLocationAge  = 24 * time.Hour
+	maxLocationSkew = 5 * time.Minute
+)
+
+var clientMessageTypes = []string{MessageHello, MessageStart, MessageLocationUpdate, MessageStop, MessagePause, MessageResume, MessageOfferAccept, MessageOfferDecline}
+
+var serverMessageTypes = []string{MessageHello, MessageError, MessageOffer, MessageOfferExpired, MessageAssignment}
+
+// strictProtocol reports whether a negotiated subprotocol is a versioned one
+func strictProtocol(subprotocol string) bool {
+	return subprotocol == ProtocolV1 || subprotocol == ProtocolV1Msgpack
+}
+
+type helloCapabilities struct {
+	Encodings      []string `json:"encodings"`
+	ClientMessages []string `json:"clientMessages"`
+	ServerMessages []string `json:"serverMessages"`
+	// location updates may omit state, the one sent with start is reused
+	OptionalState bool        `json:"optionalState"`
+	Limits        helloLimits `json:"limits"`
+}
+
+// helloLimits lets clients pace themselves instead of being throttled
+type helloLimits struct {
+	MaxFrameBytes      int64   `json:"maxFrameBytes"`
+	MessagesPerSecond  float64 `json:"messagesPerSecond"`
+	LocationsPerSecond float64 `json:"locationsPerSecond"`
+}
+
+// helloPayload is sent as soon as a versioned connection opens, and again in
+// answer to a client hello
+type helloPayload struct {
+	Protocol     string            `json:"protocol"`
+	Version      int               `json:"version"`
+	Schema       string            `json:"schema"`
+	ServerTime   int64             `json:"serverTime"`
+	Capabilities helloCapabilities `json:"capabilities"`
+}
+
+func newHello(subprotocol string, cfg *config.WebSocketConfig) helloPayload {
+	return helloPayload{
+		Protocol:   subprotocol,
+		Version:    protocolVersion,
+		Schema:     protocolSchemaURL,
+		ServerTime: time.Now().UnixMilli(),
+		Capabilities: helloCapabilities{
+			Encodings:      []string{ProtocolV1, ProtocolV1Msgpack},
+			ClientMessages: clientMessageTypes,
+			ServerMessages: serverMessageTypes,
+			OptionalState:  true,
+			Limits: helloLimits{
+				MaxFrameBytes:      cfg.MaxFrameBytes,
+				MessagesPerSecond:  cfg.ConnRate,
+				LocationsPerSecond: cfg.SessionRate,
+			},
+		},
+	}
+}
+
+// protocolError rejects a single message, the connection stays open
+type protocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// type and session of the rejected message, when they could be read
+	Ref       string `json:"ref,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	// milliseconds to wait before sending again, set when throttled
+	RetryAfter int64 `json:"retryAfter,omitempty"`
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func invalidMessage(format string, args ...interface{}) *protocolError {
+	return &protocolError{Code: "INVALID_MESSAGE", Message: fmt.Sprintf(format, args...)}
+}
+
+func rateLimited(code, message string, wait time.Duration) *protocolError {
+	return &protocolError{Code: code, Message: message, RetryAfter: wait.Milliseconds() + 1}
+}
+
+// decodeMessage parses a frame already converted to JSON. Strict decoding
+// rejects fields the schema does not define.
+func decodeMessage(raw []byte, strict bool) (*WebSocketMessage, error) {
+	var msg WebSocketMessage
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, invalidMessage("message does not match the schema: %v", err)
+	}
+
+	return &msg, nil
+}
+
+// validateMessage checks a message against the schema. The legacy protocol
+// tolerates a missing timestamp, v1 requires one.
+func validateMessage(msg *WebSocketMessage, strict bool) error {
+	if msg.Type == MessageHello {
+		return nil
+	}
+
+	if msg.SessionID == "" {
+		return invalidMessage("sessionId is required")
+	}
+	if len(msg.SessionID) > 255 {
+		return invalidMessage("sessionId is longer than 255 characters")
+	}
+
+	switch msg.Type {
+	case MessageStart:
+		if msg.State == nil || msg.State.StartTime == nil {
+			return invalidMessage("start requires state with a startTime")
+		}
+		if msg.State.SessionID != "" && msg.State.SessionID != msg.SessionID {
+			return invalidMessage("state.sessionId does not match sessionId")
+		}
+		if msg.State.RiderID != "" && !uuidPattern.MatchString(msg.State.RiderID) {
+			return &protocolError{Code: "INVALID_RIDER_ID", Message: "state.riderId must be a UUID"}
+		}
+		if msg.State.DeliveryID != "" && !uuidPattern.MatchString(msg.State.DeliveryID) {
+			return &protocolError{Code: "INVALID_DELIVERY_ID", Message: "state.deliveryId must be a UUID"}
+		}
+
+	case MessageLocationUpdate:
+		if msg.State == nil {
+			return invalidMessage("location_update requires state, or a start sent earlier on this connection")
+		}
+		if msg.Data == nil {
+			return invalidMessage("location_update requires data")
+		}
+		if msg.Data.Timestamp <= 0 {
+			if strict {
+				return invalidMessage("data.timestamp is required")
+			}
+			msg.Data.Timestamp = time.Now().UnixMilli()
+		}
+		if recordedAt := time.UnixMilli(msg.Data.Timestamp); time.Since(recordedAt) > maxLocationAge || time.Until(recordedAt) > maxLocationSkew {
+			return invalidMessage("data.timestamp must be within the last day and not in the future")
+		}
+		if msg.Data.Speed != nil && *msg.Data.Speed < 0 {
+			return invalidMessage("data.speed cannot be negative")
+		}
+		if msg.Data.Heading != nil && (*msg.Data.Heading < 0 || *msg.Data.Heading >= 360) {
+			return invalidMessage("data.heading must be between 0 and 360")
+		}
+
+	case MessageStop, MessagePause, MessageResume:
+
+	case MessageOfferAccept, MessageOfferDecline:
+		if msg.OfferID == "" {
+			return invalidMessage("%s requires offerId", msg.Type)
+		}
+
+	default:
+		return &protocolError{Code: "UNKNOWN_MESSAGE_TYPE", Message: fmt.Sprintf("unknown message type %q", msg.Type)}
+	}
+
+	return nil
+}
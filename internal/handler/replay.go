@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/gorilla/websocket"
+)
+
+// replayControl is sent by the client to steer a replay:
+//
+//	{"type": "pause"}
+//	{"type": "resume"}
+//	{"type": "seek", "position": 120} or {"type": "seek", "time": <unix ms>}
+//	{"type": "speed", "speed": 4}
+type replayControl struct {
+	Type     string  `json:"type"`
+	Position *int    `json:"position"`
+	Time     *int64  `json:"time"`
+	Speed    float64 `json:"speed"`
+}
+
+type replayPoint struct {
+	Position int                    `json:"position"`
+	Location *domain.LocationUpdate `json:"location"`
+}
+
+type ReplayHandler struct {
+	locationService *service.LocationService
+	upgrader        websocket.Upgrader
+}
+
+func NewReplayHandler(locationService *service.LocationService) *ReplayHandler {
+	return &ReplayHandler{
+		locationService: locationService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// HandleReplay serves GET /sessions/{sessionID}/replay?speed=1, a websocket
+// streaming the session's points as "point" messages spaced as they were
+// recorded, divided by speed. Every control message is answered with a
+// "state" message and the stream ends with "end".
+func (h *ReplayHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	speed := 1.0
+	if raw := r.URL.Query().Get("speed"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REPLAY_SPEED", "speed must be a number")
+			return
+		}
+		speed = parsed
+	}
+
+	// fail before upgrading so the client gets a proper status code
+	replay, err := h.locationService.LoadReplay(r.Context(), r.PathValue("sessionID"), speed)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade replay connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := newClient(conn)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	controls := make(chan replayControl)
+	go func() {
+		defer close(controls)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var control replayControl
+			if err := json.Unmarshal(message, &control); err != nil {
+				client.send(OutgoingMessage{Type: "error", Payload: errorResponse{Code: "INVALID_MESSAGE", Message: "control message must be valid JSON"}})
+				continue
+			}
+			select {
+			case controls <- control:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := client.send(OutgoingMessage{Type: "state", Payload: replay.State()}); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	// when the next point is due, zero while none is. It is absolute so pings
+	// and rejected controls do not push the point back.
+	var deadline time.Time
+
+	for {
+		if deadline.IsZero() && !replay.Paused() && !replay.Done() {
+			deadline = time.Now().Add(replay.Delay())
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if !deadline.IsZero() {
+			timer = time.NewTimer(time.Until(deadline))
+			due = timer.C
+		}
+
+		err = nil
+		select {
+		case <-due:
+			deadline = time.Time{}
+			position := replay.State().Position
+			err = client.send(OutgoingMessage{Type: "point", Payload: replayPoint{Position: position, Location: replay.Advance()}})
+			if err == nil && replay.Done() {
+				err = client.send(OutgoingMessage{Type: "end", Payload: replay.State()})
+			}
+		case control, ok := <-controls:
+			if !ok {
+				return
+			}
+			var applied bool
+			applied, err = h.applyControl(client, replay, control)
+			// the replay changed, the next point is due by its new pace
+			if applied {
+				deadline = time.Time{}
+			}
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeTimeout))
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// applyControl changes the replay and reports the new state, or an error
+// message if the control is invalid. It returns whether the replay changed;
+// only write failures are returned as errors.
+func (h *ReplayHandler) applyControl(client *client, replay *service.Replay, control replayControl) (bool, error) {
+	var err error
+	switch control.Type {
+	case "pause":
+		replay.Pause()
+	case "resume":
+		replay.Resume()
+	case "speed":
+		err = replay.SetSpeed(control.Speed)
+	case "seek":
+		switch {
+		case control.Position != nil:
+			err = replay.SeekIndex(*control.Position)
+		case control.Time != nil:
+			err = replay.SeekTime(time.UnixMilli(*control.Time))
+		default:
+			err = &domain.DomainError{Code: "INVALID_SEEK", Message: "seek needs a position or a time"}
+		}
+	default:
+		err = &domain.DomainError{Code: "UNKNOWN_CONTROL", Message: "unknown control " + control.Type}
+	}
+
+	var domainErr *domain.DomainError
+	if errors.As(err, &domainErr) {
+		return false, client.send(OutgoingMessage{Type: "error", Payload: errorResponse{Code: domainErr.Code, Message: domainErr.Message}})
+	}
+
+	return true, client.send(OutgoingMessage{Type: "state", Payload: replay.State()})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type setRetentionRequest struct {
+	LocationRetentionDays int `json:"locationRetentionDays"`
+}
+
+type RetentionHandler struct {
+	retentionService *service.RetentionService
+}
+
+func NewRetentionHandler(retentionService *service.RetentionService) *RetentionHandler {
+	return &RetentionHandler{retentionService: retentionService}
+}
+
+// HandleGetPolicy serves GET /organizations/{organizationID}/retention
+func (h *RetentionHandler) HandleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.retentionService.GetPolicy(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policy)
+}
+
+// HandleSetPolicy serves PUT /organizations/{organizationID}/retention
+func (h *RetentionHandler) HandleSetPolicy(w http.ResponseWriter, r *http.Request) {
+	var req setRetentionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	policy, err := h.retentionService.SetPolicy(r.Context(), req.LocationRetentionDays)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policy)
+}
+
+// HandleDeletePolicy serves DELETE /organizations/{organizationID}/retention
+func (h *RetentionHandler) HandleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.retentionService.DeletePolicy(r.Context()); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSessionSummary serves GET /sessions/{sessionID}/summary, what is kept
+// of a session after its points were archived
+func (h *RetentionHandler) HandleSessionSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.retentionService.GetSessionSummary(r.Context(), r.PathValue("sessionID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type availabilityRequest struct {
+	Availability domain.RiderAvailability `json:"availability"`
+}
+
+type RiderHandler struct {
+	riderService *service.RiderService
+}
+
+func NewRiderHandler(riderService *service.RiderService) *RiderHandler {
+	return &RiderHandler{riderService: riderService}
+}
+
+// HandleCreate serves POST /riders
+func (h *RiderHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var rider domain.Rider
+	if err := json.NewDecoder(r.Body).Decode(&rider); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	if err := h.riderService.CreateRider(r.Context(), &rider); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rider)
+}
+
+// HandleGet serves GET /riders/{riderID}
+func (h *RiderHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	rider, err := h.riderService.GetRider(r.Context(), r.PathValue("riderID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rider)
+}
+
+// HandleSetAvailability serves PUT /riders/{riderID}/availability
+func (h *RiderHandler) HandleSetAvailability(w http.ResponseWriter, r *http.Request) {
+	if !actingForRider(w, r) {
+		return
+	}
+
+	var req availabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	rider, err := h.riderService.SetAvailability(r.Context(), r.PathValue("riderID"), req.Availability)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rider)
+}
+
+// HandleStartShift serves POST /riders/{riderID}/shift/start
+func (h *RiderHandler) HandleStartShift(w http.ResponseWriter, r *http.Request) {
+	if !actingForRider(w, r) {
+		return
+	}
+
+	rider, err := h.riderService.StartShift(r.Context(), r.PathValue("riderID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rider)
+}
+
+// HandleEndShift serves POST /riders/{riderID}/shift/end
+func (h *RiderHandler) HandleEndShift(w http.ResponseWriter, r *http.Request) {
+	if !actingForRider(w, r) {
+		return
+	}
+
+	rider, err := h.riderService.EndShift(r.Context(), r.PathValue("riderID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rider)
+}
+
+// HandleLocations serves GET /riders/{riderID}/locations?from=&to=
+// from and to are RFC3339 timestamps, defaulting to the start of today and now.
+func (h *RiderHandler) HandleLocations(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := now
+
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_FROM", "from must be an RFC3339 timestamp")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_TO", "to must be an RFC3339 timestamp")
+			return
+		}
+	}
+
+	locations, err := h.riderService.GetRiderLocations(r.Context(), r.PathValue("riderID"), from, to)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if locations == nil {
+		locations = []*domain.LocationUpdate{}
+	}
+
+	writeJSON(w, http.StatusOK, locations)
+}
+
+// actingForRider only lets a rider's app change its own rider. Api keys may
+// change any rider of their organization.
+func actingForRider(w http.ResponseWriter, r *http.Request) bool {
+	riderID := r.PathValue("riderID")
+	if !uuidPattern.MatchString(riderID) {
+		writeError(w, http.StatusNotFound, "RIDER_NOT_FOUND", "rider does not exist")
+		return false
+	}
+
+	if a := actor.FromContext(r.Context()); a.Type == domain.ActorRiderApp && a.ID != riderID {
+		writeError(w, http.StatusForbidden, "RIDER_MISMATCH", "cannot act for another rider")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type RouteHandler struct {
+	matchingService *service.MatchingService
+	locationService *service.LocationService
+}
+
+func NewRouteHandler(matchingService *service.MatchingService, locationService *service.LocationService) *RouteHandler {
+	return &RouteHandler{matchingService: matchingService, locationService: locationService}
+}
+
+// HandleRoute serves GET /sessions/{sessionID}/route, the raw points recorded for a session
+func (h *RouteHandler) HandleRoute(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_SESSION_ID", "session id is required")
+		return
+	}
+
+	route, err := h.locationService.GetSessionRoute(r.Context(), sessionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if route == nil {
+		route = []*domain.LocationUpdate{}
+	}
+
+	writeJSON(w, http.StatusOK, route)
+}
+
+// HandleMatchedRoute serves GET /sessions/{sessionID}/matched-route
+func (h *RouteHandler) HandleMatchedRoute(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_SESSION_ID", "session id is required")
+		return
+	}
+
+	route, err := h.matchingService.MatchSession(r.Context(), sessionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, route)
+}
+
+// HandleStats serves GET /sessions/{sessionID}/stats, distance and durations
+// of a session with paused time left out of the active duration
+func (h *RouteHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "MISSING_SESSION_ID", "session id is required")
+		return
+	}
+
+	stats, err := h.locationService.GetSessionStats(r.Context(), sessionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
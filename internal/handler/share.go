@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type createShareLinkRequest struct {
+	// optional, capped at the configured link lifetime
+	TTLMinutes int `json:"ttlMinutes"`
+}
+
+// shareLinkTokenResponse is only returned when a link is created
+type shareLinkTokenResponse struct {
+	*domain.ShareLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+type ShareHandler struct {
+	shareService *service.ShareService
+	pagePath     string
+}
+
+// NewShareHandler creates a handler serving the recipient page from pagePath
+func NewShareHandler(shareService *service.ShareService, pagePath string) *ShareHandler {
+	return &ShareHandler{shareService: shareService, pagePath: pagePath}
+}
+
+// HandleCreate serves POST /deliveries/{deliveryID}/share-links
+func (h *ShareHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("deliveryID")) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	var req createShareLinkRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+			return
+		}
+	}
+
+	if req.TTLMinutes < 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_TTL", "ttlMinutes cannot be negative")
+		return
+	}
+
+	link, token, err := h.shareService.CreateLink(r.Context(), r.PathValue("deliveryID"), time.Duration(req.TTLMinutes)*time.Minute)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, shareLinkTokenResponse{ShareLink: link, Token: token, URL: h.shareService.URL(token)})
+}
+
+// HandleList serves GET /deliveries/{deliveryID}/share-links
+func (h *ShareHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("deliveryID")) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return
+	}
+
+	links, err := h.shareService.ListLinks(r.Context(), r.PathValue("deliveryID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if links == nil {
+		links = []*domain.ShareLink{}
+	}
+
+	writeJSON(w, http.StatusOK, links)
+}
+
+// HandleRevoke serves DELETE /deliveries/{deliveryID}/share-links/{linkID}
+func (h *ShareHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if !uuidPattern.MatchString(r.PathValue("deliveryID")) || !uuidPattern.MatchString(r.PathValue("linkID")) {
+		writeError(w, http.StatusNotFound, "SHARE_LINK_NOT_FOUND", "share link does not exist")
+		return
+	}
+
+	if err := h.shareService.RevokeLink(r.Context(), r.PathValue("deliveryID"), r.PathValue("linkID")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePage serves GET /t/{token}, the recipient's tracking page. The page
+// reads the token from its own path.
+func (h *ShareHandler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	// keep the token out of Referer headers sent to third parties
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeFile(w, r, h.pagePath)
+}
+
+// HandleTracking serves GET /shared/{token}, polled by the recipient page
+func (h *ShareHandler) HandleTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	tracking, err := h.shareService.GetTracking(r.Context(), r.PathValue("token"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tracking)
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// how often an idle SSE stream sends a comment to keep proxies from closing it
+	sseHeartbeat = 15 * time.Second
+	// reconnect delay suggested to EventSource clients
+	sseRetry = 3 * time.Second
+)
+
+// fleetEvents are the events the dispatcher dashboard follows
+var fleetEvents = map[string]bool{
+	domain.EventSessionStarted:        true,
+	domain.EventSessionStopped:        true,
+	domain.EventLocationRecorded:      true,
+	domain.EventRiderArrivedAtPickup:  true,
+	domain.EventRiderArrivedAtDropoff: true,
+	domain.EventDeliveryCompleted:     true,
+}
+
+// resetMessage tells a resuming watcher that events were missed and the
+// delivery that follows replaces what it has
+var resetMessage = map[string]string{
+	"code":    "EVENTS_MISSED",
+	"message": "events since the resume point are no longer available",
+}
+
+func fleetFilter(event *domain.Event) bool {
+	return fleetEvents[event.Type]
+}
+
+// WatchHandler streams a single delivery's events to integrations, over a
+// websocket or, where those are blocked, Server-Sent Events. Both transports
+// send the same messages from the same subscription.
+type WatchHandler struct {
+	watchService *service.WatchService
+	upgrader     websocket.Upgrader
+}
+
+func NewWatchHandler(watchService *service.WatchService) *WatchHandler {
+	return &WatchHandler{
+		watchService: watchService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// HandleWebSocket serves GET /deliveries/{deliveryID}/watch. The delivery is
+// sent first as a "delivery" message, then every event as an "event" message.
+// A lastEventId query parameter resumes after that event; when the events since
+// cannot be replayed a "reset" message comes before the delivery.
+func (h *WatchHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	lastEventID := r.URL.Query().Get("lastEventId")
+
+	watch, ok := h.watch(w, r, lastEventID)
+	if !ok {
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		watch.Close()
+		log.Printf("Failed to upgrade watch connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := newClient(conn)
+
+	// watchers only listen, reading detects when they go away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	h.stream(r, watch, lastEventID, watchTransport{
+		reset: func() error {
+			return client.send(OutgoingMessage{Type: "reset", Payload: resetMessage})
+		},
+		delivery: func(delivery *domain.Delivery) error {
+			return client.send(OutgoingMessage{Type: "delivery", Payload: delivery})
+		},
+		event: func(event *domain.Event) error {
+			return client.send(OutgoingMessage{Type: "event", Payload: event})
+		},
+		keepAlive: func() error {
+			return conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeTimeout))
+		},
+		keepAliveEvery: 30 * time.Second,
+		flush:          func() {},
+		done:           closed,
+	})
+}
+
+// HandleEvents serves GET /deliveries/{deliveryID}/events as text/event-stream.
+// Each event is sent with its id and type, so an EventSource reconnecting with
+// Last-Event-ID picks up where it left off, or is sent a "reset" event when the
+// events since are gone. A browser authenticates with a ticket from POST
+// /deliveries/tickets; tickets are single use, so it reconnects with a new
+// EventSource carrying a fresh ticket and the lastEventId query parameter.
+func (h *WatchHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "STREAMING_UNSUPPORTED", "streaming is not supported")
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	watch, ok := h.watch(w, r, lastEventID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+
+	h.stream(r, watch, lastEventID, watchTransport{
+		reset: func() error {
+			return writeSSE(w, "", "reset", resetMessage)
+		},
+		// the snapshot has no id so it does not move the client's resume point
+		delivery: func(delivery *domain.Delivery) error {
+			return writeSSE(w, "", "delivery", delivery)
+		},
+		event: func(event *domain.Event) error {
+			return writeSSE(w, event.ID, event.Type, event)
+		},
+		keepAlive: func() error {
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			return err
+		},
+		keepAliveEvery: sseHeartbeat,
+		flush:          flusher.Flush,
+		done:           r.Context().Done(),
+	})
+}
+
+// watchTransport writes the messages of a watch over one transport
+type watchTransport struct {
+	reset     func() error
+	delivery  func(delivery *domain.Delivery) error
+	event     func(event *domain.Event) error
+	keepAlive func() error
+	// how often an idle stream sends keepAlive
+	keepAliveEvery time.Duration
+	flush          func()
+	// closed when the client goes away
+	done <-chan struct{}
+}
+
+// stream sends a watch until the client goes away. A watch cut off for falling
+// behind is reopened after the last event sent, so the client is sent what it
+// missed from the stored events, or a reset when those are gone too.
+func (h *WatchHandler) stream(r *http.Request, watch *service.DeliveryWatch, lastEventID string, t watchTransport) {
+	defer func() { watch.Close() }()
+
+	// after a reset the snapshot is all the client has
+	if watch.Reset || !uuidPattern.MatchString(lastEventID) {
+		lastEventID = ""
+	}
+
+	ticker := time.NewTicker(t.keepAliveEvery)
+	defer ticker.Stop()
+
+	snapshot := true
+	for {
+		if watch.Reset {
+			if err := t.reset(); err != nil {
+				return
+			}
+		}
+		if snapshot || watch.Reset {
+			if err := t.delivery(watch.Delivery); err != nil {
+				return
+			}
+		}
+		for _, event := range watch.Backlog {
+			if err := t.event(event); err != nil {
+				return
+			}
+			lastEventID = event.ID
+		}
+		t.flush()
+		snapshot = false
+
+		if !h.follow(watch, &lastEventID, t, ticker.C) {
+			return
+		}
+
+		resumed, err := h.watchService.WatchDelivery(r.Context(), watch.Delivery.ID, lastEventID)
+		if err != nil {
+			log.Printf("Failed to resume watch of delivery %s: %v", watch.Delivery.ID, err)
+			return
+		}
+		// nothing was sent since the snapshot, it is the client's resume point
+		if lastEventID == "" {
+			resumed.Reset = true
+		}
+		watch.Close()
+		watch = resumed
+	}
+}
+
+// follow sends live events until the client goes away, or the subscription is
+// cut off and the watch must be reopened, which it reports
+func (h *WatchHandler) follow(watch *service.DeliveryWatch, lastEventID *string, t watchTransport, keepAlive <-chan time.Time) bool {
+	for {
+		select {
+		case event, ok := <-watch.Events:
+			if !ok {
+				return watch.Lost()
+			}
+			if watch.Replayed(event) {
+				continue
+			}
+			if err := t.event(event); err != nil {
+				return false
+			}
+			*lastEventID = event.ID
+		case <-keepAlive:
+			if err := t.keepAlive(); err != nil {
+				return false
+			}
+		case <-t.done:
+			return false
+		}
+		t.flush()
+	}
+}
+
+func (h *WatchHandler) watch(w http.ResponseWriter, r *http.Request, lastEventID string) (*service.DeliveryWatch, bool) {
+	if !uuidPattern.MatchString(r.PathValue("deliveryID")) {
+		writeError(w, http.StatusNotFound, "DELIVERY_NOT_FOUND", "delivery does not exist")
+		return nil, false
+	}
+
+	// event ids are uuids, anything else cannot be resumed from and the
+	// client starts over as if its events were gone
+	unknown := lastEventID != "" && !uuidPattern.MatchString(lastEventID)
+	if unknown {
+		lastEventID = ""
+	}
+
+	watch, err := h.watchService.WatchDelivery(r.Context(), r.PathValue("deliveryID"), lastEventID)
+	if err != nil {
+		writeServiceError(w, err)
+		return nil, false
+	}
+	if unknown {
+		watch.Reset = true
+	}
+
+	return watch, true
+}
+
+// writeSSE writes one event in text/event-stream framing. JSON never contains
+// a raw newline, so the data fits on a single line.
+func writeSSE(w http.ResponseWriter, id, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	return err
+}
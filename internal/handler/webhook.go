@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/service"
+)
+
+type createWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// webhookSecretResponse is only returned when an endpoint is created
+type webhookSecretResponse struct {
+	*domain.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// HandleCreate serves POST /webhooks
+func (h *WebhookHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "request body must be valid JSON")
+		return
+	}
+
+	endpoint, err := h.webhookService.CreateEndpoint(r.Context(), req.URL, req.EventTypes)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, webhookSecretResponse{WebhookEndpoint: endpoint, Secret: endpoint.Secret})
+}
+
+// HandleList serves GET /webhooks
+func (h *WebhookHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.webhookService.ListEndpoints(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if endpoints == nil {
+		endpoints = []*domain.WebhookEndpoint{}
+	}
+
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+// HandleDelete serves DELETE /webhooks/{endpointID}
+func (h *WebhookHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("endpointID")
+	if !uuidPattern.MatchString(endpointID) {
+		writeError(w, http.StatusNotFound, "WEBHOOK_ENDPOINT_NOT_FOUND", "webhook endpoint does not exist")
+		return
+	}
+
+	if err := h.webhookService.DeleteEndpoint(r.Context(), endpointID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListDeliveries serves GET /webhooks/{endpointID}/deliveries?limit=
+func (h *WebhookHandler) HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.PathValue("endpointID")
+	if !uuidPattern.MatchString(endpointID) {
+		writeError(w, http.StatusNotFound, "WEBHOOK_ENDPOINT_NOT_FOUND", "webhook endpoint does not exist")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), endpointID, limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []*domain.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// HandleListAttempts serves GET /webhooks/deliveries/{deliveryID}/attempts
+func (h *WebhookHandler) HandleListAttempts(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery does not exist")
+		return
+	}
+
+	attempts, err := h.webhookService.ListAttempts(r.Context(), deliveryID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if attempts == nil {
+		attempts = []*domain.WebhookAttempt{}
+	}
+
+	writeJSON(w, http.StatusOK, attempts)
+}
+
+// HandleRetry serves POST /webhooks/deliveries/{deliveryID}/retry, replaying a dead letter
+func (h *WebhookHandler) HandleRetry(w http.ResponseWriter, r *http.Request) {
+	deliveryID := r.PathValue("deliveryID")
+	if !uuidPattern.MatchString(deliveryID) {
+		writeError(w, http.StatusNotFound, "WEBHOOK_DELIVERY_NOT_FOUND", "webhook delivery does not exist")
+		return
+	}
+
+	if err := h.webhookService.RetryDelivery(r.Context(), deliveryID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,13 +1,16 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/SarkiMudboy/easebox-api/internal/config"
 	"github.com/SarkiMudboy/easebox-api/internal/domain"
 	"github.com/SarkiMudboy/easebox-api/internal/service"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/SarkiMudboy/easebox-api/pkg/ratelimit"
 	"github.com/gorilla/websocket"
 )
 
@@ -16,14 +19,16 @@ type LocationData struct {
 	Longitude float64 `json:"longitude"`
 	Accuracy  float64 `json:"accuracy"`
 	Timestamp int64   `json:"timestamp"`
-	Speed     float64   `json:"speed"`
-	Heading   float64   `json:"heading"`
+	// null when the device does not report them
+	Speed     *float64  `json:"speed"`
+	Heading   *float64  `json:"heading"`
 }
 
 type TrackingState struct {
 	IsTracking     bool   `json:"isTracking"`
 	SessionID      string `json:"sessionId"`
 	DeliveryID 	   string `json:"deliveryId"`
+	RiderID        string `json:"riderId,omitempty"`
 	StartTime      *int64 `json:"startTime"`
 	LastUpdateTime *int64 `json:"lastUpdateTime"`
 }
@@ -33,20 +38,34 @@ type WebSocketMessage struct {
 	SessionID string        `json:"sessionId"`
 	Data      *LocationData `json:"data"`
 	State     *TrackingState `json:"state"`
+	OfferID   string         `json:"offerId,omitempty"`
+	// free-form client name and version, sent with hello
+	Client    string         `json:"client,omitempty"`
 }
 
 type WebSocketHandler struct {
 	locationService *service.LocationService
+	dispatchService *service.DispatchService
+	hub *Hub
 	upgrader websocket.Upgrader
+	cfg *config.WebSocketConfig
+	// shared by every connection, a session cannot dodge it by reconnecting
+	sessionLimiter *ratelimit.Limiter
 }
 
-func NewWebSocketHandler(locationService *service.LocationService) *WebSocketHandler {
+func NewWebSocketHandler(locationService *service.LocationService, dispatchService *service.DispatchService, hub *Hub, cfg *config.WebSocketConfig) *WebSocketHandler {
 	return &WebSocketHandler{
 		locationService: locationService,
+		dispatchService: dispatchService,
+		hub: hub,
+		cfg: cfg,
+		sessionLimiter: ratelimit.NewLimiter(cfg.SessionRate, cfg.SessionBurst),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize: 1024,
 			WriteBufferSize: 1024,
 			CheckOrigin: func(r *http.Request) bool { return true },
+			// preferred first when a client offers several
+			Subprotocols: []string{ProtocolV1Msgpack, ProtocolV1, SubprotocolMsgpack, SubprotocolJSON},
 		},
 	}
 }
@@ -59,10 +78,29 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	log.Printf("New Websocket connection established: %v", r.RemoteAddr)
+	log.Printf("New Websocket connection established: %v (protocol %q)", r.RemoteAddr, conn.Subprotocol())
 
 	defer conn.Close()
 
+	// larger frames fail the read below and close the connection
+	conn.SetReadLimit(h.cfg.MaxFrameBytes)
+
+	client := newClient(conn)
+	defer h.hub.unregister(client)
+
+	connLimit := ratelimit.NewBucket(h.cfg.ConnRate, h.cfg.ConnBurst)
+	throttled := 0
+	organizationID, _ := tenant.FromContext(r.Context())
+
+	// last state sent per session, so location updates may leave it out
+	states := make(map[string]*TrackingState)
+
+	strict := strictProtocol(conn.Subprotocol())
+	if strict {
+		if err := client.send(OutgoingMessage{Type: MessageHello, Payload: newHello(conn.Subprotocol(), h.cfg)}); err != nil {
+			return
+		}
+	}
 
 	conn.SetPongHandler(func (string) error {
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -91,38 +129,75 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 	}()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		messageType, message, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("Read error: %v", err)
 			close(done)
 			break
 		}
 
-		var msg WebSocketMessage
-		if err = json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Error parsing message content: %v", err)
+		// throttled messages are dropped before they reach the database
+		if ok, wait := connLimit.Allow(time.Now()); !ok {
+			throttled++
+			if throttled >= h.cfg.MaxThrottled {
+				log.Printf("Closing %v: %d messages throttled in a row", r.RemoteAddr, throttled)
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded"), time.Now().Add(writeTimeout))
+				break
+			}
+			h.reject(client, strict, nil, rateLimited("RATE_LIMITED", "connection is sending messages too fast", wait))
 			continue
 		}
+		throttled = 0
 
-		if !h.validateWebSocketMessage(&msg) {
-			log.Printf("Invalid message")
+		raw, err := client.codec.Decode(messageType, message)
+		if err != nil {
+			h.reject(client, strict, nil, invalidMessage("frame could not be decoded: %v", err))
 			continue
 		}
 
-		ctx := r.Context()
+		msg, err := decodeMessage(raw, strict)
+		if err != nil {
+			h.reject(client, strict, nil, err)
+			continue
+		}
 
+		if msg.State != nil {
+			states[msg.SessionID] = msg.State
+		} else {
+			msg.State = states[msg.SessionID]
+		}
 
-		switch msg.Type {
-		case "start":
-			err = h.locationService.StartTracking(ctx, msg.State.SessionID, msg.State.DeliveryID)
-			log.Printf("[START] -> Session ID: %s, Started at : %v", msg.SessionID, time.UnixMilli(*msg.State.StartTime))
-		case "location_update":
-			loc := h.MessageToLocation(&msg)
+		if err := validateMessage(msg, strict); err != nil {
+			h.reject(client, strict, msg, err)
+			if msg.Type == MessageStart {
+				delete(states, msg.SessionID)
+			}
+			continue
+		}
 
-			if loc == nil {
-				log.Printf("missing location_update data")
+		if msg.Type == MessageLocationUpdate {
+			if ok, wait := h.sessionLimiter.Allow(organizationID+"/"+msg.SessionID, time.Now()); !ok {
+				h.reject(client, strict, msg, rateLimited("SESSION_RATE_LIMITED", "session is sending locations too fast", wait))
 				continue
 			}
+		}
+
+		if msg.Type == MessageHello {
+			log.Printf("[HELLO] client %q", msg.Client)
+			if strict {
+				client.send(OutgoingMessage{Type: MessageHello, Payload: newHello(conn.Subprotocol(), h.cfg)})
+			}
+			continue
+		}
+
+		ctx := r.Context()
+
+		switch msg.Type {
+		case MessageStart:
+			err = h.locationService.StartTracking(ctx, msg.SessionID, msg.State.DeliveryID, msg.State.RiderID)
+			log.Printf("[START] -> Session ID: %s, Started at : %v", msg.SessionID, time.UnixMilli(*msg.State.StartTime))
+		case MessageLocationUpdate:
+			loc := h.MessageToLocation(msg)
 
 			err = h.locationService.RecordLocation(ctx, loc)
 
@@ -134,11 +209,11 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 				time.UnixMilli(msg.Data.Timestamp),
 			)
 
-		case "stop":
+		case MessageStop:
 			err = h.locationService.StopTracking(ctx, msg.SessionID)
 
 			var duration time.Duration
-			if msg.State.StartTime != nil && msg.State.LastUpdateTime != nil {
+			if msg.State != nil && msg.State.StartTime != nil && msg.State.LastUpdateTime != nil {
 				startTime := time.UnixMilli(*msg.State.StartTime)
 				endTime := time.UnixMilli(*msg.State.LastUpdateTime)
 				duration = endTime.Sub(startTime)
@@ -146,11 +221,34 @@ func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Reque
 				log.Printf("[STOP] Session ID: %v, Duration: %v", msg.SessionID, duration)
 
 			}
+		case MessagePause:
+			err = h.locationService.PauseTracking(ctx, msg.SessionID)
+			log.Printf("[PAUSE] Session ID: %s", msg.SessionID)
+		case MessageResume:
+			err = h.locationService.ResumeTracking(ctx, msg.SessionID)
+			log.Printf("[RESUME] Session ID: %s", msg.SessionID)
+		case MessageOfferAccept, MessageOfferDecline:
+			accepted := msg.Type == MessageOfferAccept
+			err = h.dispatchService.RespondToOffer(ctx, msg.SessionID, msg.OfferID, accepted)
+			log.Printf("[OFFER] Session ID: %s, Offer ID: %s, Accepted: %v", msg.SessionID, msg.OfferID, accepted)
 		}
 
 		if err != nil {
 			log.Println(msg.Data, msg.State)
 			log.Printf("Service error: %v", err)
+			h.reject(client, strict, msg, err)
+			// a session that failed to start must not lend its state to later messages
+			if msg.Type == MessageStart {
+				delete(states, msg.SessionID)
+			}
+			continue
+		}
+
+		// bind the session to this connection so the server can push offers to
+		// the rider, once the service accepted it as the caller's session
+		switch msg.Type {
+		case MessageStart, MessageLocationUpdate, MessagePause, MessageResume:
+			h.hub.register(ctx, msg.SessionID, client)
 		}
 
 	}
@@ -168,31 +266,35 @@ func (h *WebSocketHandler) MessageToLocation (message *WebSocketMessage) *domain
 		Latitude: message.Data.Latitude,
 		Longitude: message.Data.Longitude,
 		Accuracy: message.Data.Accuracy,
-		Speed: &message.Data.Speed,
-		Heading: &message.Data.Heading,
+		Speed: message.Data.Speed,
+		Heading: message.Data.Heading,
 		RecordedAt: time.UnixMilli(message.Data.Timestamp),
 	}
 
 }
 
-func (h * WebSocketHandler) validateWebSocketMessage (msg *WebSocketMessage) bool {
+// reject reports a message that could not be handled. Versioned clients get
+// an error frame, legacy clients are only logged as before.
+func (h *WebSocketHandler) reject(client *client, strict bool, msg *WebSocketMessage, err error) {
+	var frame *protocolError
+	var domainErr *domain.DomainError
+
+	switch {
+	case errors.As(err, &frame):
+	case errors.As(err, &domainErr):
+		frame = &protocolError{Code: domainErr.Code, Message: domainErr.Message}
+	default:
+		frame = &protocolError{Code: "INTERNAL_ERROR", Message: "message could not be processed"}
+	}
 
-	if msg.SessionID == "" {
-		return false
+	if msg != nil {
+		frame.Ref = msg.Type
+		frame.SessionID = msg.SessionID
 	}
 
-	if msg.Type == "location_update" {
-		if msg.State == nil || msg.State.SessionID == "" {
-			return false
-		}
+	log.Printf("Rejected message: %v", err)
 
-		if msg.Data.Timestamp <= 0 {
-			msg.Data.Timestamp = time.Now().Unix()
-		}
+	if strict {
+		client.send(OutgoingMessage{Type: MessageError, Payload: frame})
 	}
-
-	return true
-	
 }
-
-
@@ -0,0 +1,256 @@
+package mapmatch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+// size of a spatial index cell in degrees (~250m at the equator)
+const cellSize = 0.0025
+
+type Node struct {
+	ID       int64
+	Position geo.Point
+}
+
+// Edge is a directed road segment between two nodes. Two-way roads are stored
+// as a pair of edges, one per direction.
+type Edge struct {
+	ID     int
+	WayID  int64
+	From   int
+	To     int
+	Length float64
+	Name   string
+}
+
+type cell struct {
+	x, y int
+}
+
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+
+	nodeIndex map[int64]int
+	outgoing  [][]int
+	grid      map[cell][]int
+}
+
+func NewGraph() *Graph {
+	return &Graph{
+		nodeIndex: make(map[int64]int),
+		grid:      make(map[cell][]int),
+	}
+}
+
+func (g *Graph) AddNode(id int64, lat, lon float64) {
+	if _, exists := g.nodeIndex[id]; exists {
+		return
+	}
+	g.nodeIndex[id] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, Node{ID: id, Position: geo.Point{Latitude: lat, Longitude: lon}})
+	g.outgoing = append(g.outgoing, nil)
+}
+
+// AddWay adds the segments of an OSM way to the graph. Every node referenced by
+// the way must have been added first.
+func (g *Graph) AddWay(wayID int64, nodeIDs []int64, oneway bool, name string) error {
+	for i := 1; i < len(nodeIDs); i++ {
+		from, ok := g.nodeIndex[nodeIDs[i-1]]
+		if !ok {
+			return fmt.Errorf("way %d references unknown node %d", wayID, nodeIDs[i-1])
+		}
+		to, ok := g.nodeIndex[nodeIDs[i]]
+		if !ok {
+			return fmt.Errorf("way %d references unknown node %d", wayID, nodeIDs[i])
+		}
+
+		g.addEdge(wayID, from, to, name)
+		if !oneway {
+			g.addEdge(wayID, to, from, name)
+		}
+	}
+	return nil
+}
+
+func (g *Graph) addEdge(wayID int64, from, to int, name string) {
+	edge := Edge{
+		ID:     len(g.Edges),
+		WayID:  wayID,
+		From:   from,
+		To:     to,
+		Length: geo.Haversine(g.Nodes[from].Position, g.Nodes[to].Position),
+		Name:   name,
+	}
+	g.Edges = append(g.Edges, edge)
+	g.outgoing[from] = append(g.outgoing[from], edge.ID)
+
+	a, b := g.Nodes[from].Position, g.Nodes[to].Position
+	minX, maxX := cellCoord(math.Min(a.Longitude, b.Longitude)), cellCoord(math.Max(a.Longitude, b.Longitude))
+	minY, maxY := cellCoord(math.Min(a.Latitude, b.Latitude)), cellCoord(math.Max(a.Latitude, b.Latitude))
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			c := cell{x, y}
+			g.grid[c] = append(g.grid[c], edge.ID)
+		}
+	}
+}
+
+func cellCoord(deg float64) int {
+	return int(math.Floor(deg / cellSize))
+}
+
+// EdgePoint returns the position at the given distance (meters) along an edge
+func (g *Graph) EdgePoint(edgeID int, offset float64) geo.Point {
+	edge := g.Edges[edgeID]
+	if edge.Length == 0 {
+		return g.Nodes[edge.From].Position
+	}
+	return geo.Interpolate(g.Nodes[edge.From].Position, g.Nodes[edge.To].Position, math.Min(1, offset/edge.Length))
+}
+
+// nearbyEdges returns the ids of edges whose grid cells intersect the box around p
+func (g *Graph) nearbyEdges(p geo.Point, radius float64) []int {
+	min, max := geo.BoundingBox(p, radius)
+
+	seen := make(map[int]struct{})
+	var edges []int
+	for x := cellCoord(min.Longitude); x <= cellCoord(max.Longitude); x++ {
+		for y := cellCoord(min.Latitude); y <= cellCoord(max.Latitude); y++ {
+			for _, id := range g.grid[cell{x, y}] {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				edges = append(edges, id)
+			}
+		}
+	}
+	return edges
+}
+
+// LoadGraph reads a road graph from a pre-processed OSM extract. Files ending in
+// .gz are decompressed transparently. The format is line based:
+//
+//	# comment
+//	n <node id> <lat> <lon>
+//	w <way id> <oneway 0|1> <node id> <node id> ... [| name]
+//
+// Extracts in PBF format can be converted with any OSM tool that filters the
+// highway ways and writes their nodes in this layout (e.g. osmium + a small script).
+func LoadGraph(path string) (*Graph, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open road graph: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decompress road graph: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	return ReadGraph(reader)
+}
+
+func ReadGraph(r io.Reader) (*Graph, error) {
+	g := NewGraph()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := g.parseLine(line); err != nil {
+			return nil, fmt.Errorf("road graph line %d: %w", lineNo, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read road graph: %w", err)
+	}
+
+	if len(g.Edges) == 0 {
+		return nil, fmt.Errorf("road graph contains no ways")
+	}
+
+	return g, nil
+}
+
+func (g *Graph) parseLine(line string) error {
+	var name string
+	if i := strings.Index(line, "|"); i >= 0 {
+		name = strings.TrimSpace(line[i+1:])
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("record type is missing")
+	}
+
+	switch fields[0] {
+	case "n":
+		if len(fields) != 4 {
+			return fmt.Errorf("node requires id, lat and lon")
+		}
+		id, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid node id: %w", err)
+		}
+		lat, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid latitude: %w", err)
+		}
+		lon, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return fmt.Errorf("invalid longitude: %w", err)
+		}
+		g.AddNode(id, lat, lon)
+
+	case "w":
+		if len(fields) < 5 {
+			return fmt.Errorf("way requires id, oneway flag and at least two nodes")
+		}
+		id, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid way id: %w", err)
+		}
+		oneway := fields[2] == "1"
+
+		nodes := make([]int64, 0, len(fields)-3)
+		for _, f := range fields[3:] {
+			nodeID, err := strconv.ParseInt(f, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid node reference: %w", err)
+			}
+			nodes = append(nodes, nodeID)
+		}
+		return g.AddWay(id, nodes, oneway, name)
+
+	default:
+		return fmt.Errorf("unknown record type %q", fields[0])
+	}
+
+	return nil
+}
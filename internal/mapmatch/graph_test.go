@@ -0,0 +1,65 @@
+package mapmatch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadGraph(t *testing.T) {
+	input := `
+# two roads sharing node 2
+n 1 0 0
+n 2 0 0.001
+n 3 0 0.002
+n 4 0.001 0.001
+w 100 0 1 2 3 | Main Street
+w 200 1 2 4
+`
+
+	g, err := ReadGraph(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadGraph: %v", err)
+	}
+
+	if len(g.Nodes) != 4 {
+		t.Errorf("nodes = %d, want 4", len(g.Nodes))
+	}
+	// the two way road has an edge per direction and segment, the one way road one
+	if len(g.Edges) != 5 {
+		t.Fatalf("edges = %d, want 5", len(g.Edges))
+	}
+
+	if g.Edges[0].Name != "Main Street" || g.Edges[0].WayID != 100 {
+		t.Errorf("first edge = %+v, want way 100 named Main Street", g.Edges[0])
+	}
+	if g.Edges[4].WayID != 200 || g.Edges[4].Name != "" {
+		t.Errorf("last edge = %+v, want unnamed way 200", g.Edges[4])
+	}
+	// about 111 meters per thousandth of a degree at the equator
+	if length := g.Edges[0].Length; length < 110 || length > 112 {
+		t.Errorf("edge length = %.1f, want about 111", length)
+	}
+}
+
+func TestReadGraphRejectsMalformed(t *testing.T) {
+	nodes := "n 1 0 0\nn 2 0 0.001\n"
+
+	tests := map[string]string{
+		"name only":          nodes + "| Main Street",
+		"blank before name":  nodes + "   | Main Street",
+		"unknown record":     nodes + "x 1 2",
+		"short node":         "n 1 0",
+		"bad node id":        "n a 0 0",
+		"bad latitude":       "n 1 north 0",
+		"short way":          nodes + "w 100 0 1",
+		"bad node reference": nodes + "w 100 0 1 b",
+		"unknown node":       nodes + "w 100 0 1 99",
+		"no ways":            nodes,
+	}
+
+	for name, input := range tests {
+		if _, err := ReadGraph(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
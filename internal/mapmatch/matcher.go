@@ -0,0 +1,306 @@
+package mapmatch
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+var ErrNoMatch = errors.New("no GPS point could be matched to the road network")
+
+type Config struct {
+	// radius (meters) around a GPS fix in which road candidates are searched
+	SearchRadius float64
+	// standard deviation (meters) of GPS noise, used for emission probabilities
+	Sigma float64
+	// tolerance (meters) between route and great-circle distance, used for transitions
+	Beta float64
+	// max candidates considered per GPS fix
+	MaxCandidates int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SearchRadius:  50,
+		Sigma:         10,
+		Beta:          5,
+		MaxCandidates: 8,
+	}
+}
+
+// Matcher snaps GPS traces onto a road graph using a hidden markov model solved
+// with the Viterbi algorithm (Newson & Krumm, 2009)
+type Matcher struct {
+	graph *Graph
+	cfg   Config
+}
+
+func NewMatcher(graph *Graph, cfg Config) *Matcher {
+	defaults := DefaultConfig()
+	if cfg.SearchRadius <= 0 {
+		cfg.SearchRadius = defaults.SearchRadius
+	}
+	if cfg.Sigma <= 0 {
+		cfg.Sigma = defaults.Sigma
+	}
+	if cfg.Beta <= 0 {
+		cfg.Beta = defaults.Beta
+	}
+	if cfg.MaxCandidates <= 0 {
+		cfg.MaxCandidates = defaults.MaxCandidates
+	}
+
+	return &Matcher{graph: graph, cfg: cfg}
+}
+
+type candidate struct {
+	edgeID   int
+	offset   float64
+	position geo.Point
+	distance float64
+}
+
+type MatchedPoint struct {
+	// index of the GPS fix in the input trace
+	Index    int
+	Original geo.Point
+	Snapped  geo.Point
+	WayID    int64
+	RoadName string
+}
+
+type Result struct {
+	Points   []MatchedPoint
+	Geometry []geo.Point
+	// distance travelled along the road network in meters
+	Distance float64
+	// number of times the trace could not be connected on the road network
+	// and matching had to restart
+	Breaks int
+}
+
+type step struct {
+	index      int
+	point      geo.Point
+	candidates []candidate
+	scores     []float64
+	back       []int
+}
+
+func (m *Matcher) Match(trace []geo.Point) (*Result, error) {
+	steps := m.buildSteps(trace)
+	if len(steps) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	result := &Result{}
+
+	segmentStart := 0
+	for i := range steps {
+		if i == segmentStart {
+			m.initStep(&steps[i])
+			continue
+		}
+
+		if !m.transition(&steps[i-1], &steps[i]) {
+			m.appendSegment(result, steps[segmentStart:i])
+			result.Breaks++
+			segmentStart = i
+			m.initStep(&steps[i])
+		}
+	}
+	m.appendSegment(result, steps[segmentStart:])
+
+	return result, nil
+}
+
+// buildSteps finds the road candidates of every GPS fix, dropping fixes with no
+// candidates and fixes too close to the previous one to carry information.
+func (m *Matcher) buildSteps(trace []geo.Point) []step {
+	var steps []step
+	for i, p := range trace {
+		if len(steps) > 0 && geo.Haversine(steps[len(steps)-1].point, p) < 2*m.cfg.Sigma {
+			continue
+		}
+
+		candidates := m.candidates(p)
+		if len(candidates) == 0 {
+			continue
+		}
+		steps = append(steps, step{index: i, point: p, candidates: candidates})
+	}
+	return steps
+}
+
+func (m *Matcher) candidates(p geo.Point) []candidate {
+	var candidates []candidate
+	for _, edgeID := range m.graph.nearbyEdges(p, m.cfg.SearchRadius) {
+		edge := m.graph.Edges[edgeID]
+		from, to := m.graph.Nodes[edge.From].Position, m.graph.Nodes[edge.To].Position
+
+		projected, t := geo.ProjectToSegment(p, from, to)
+		distance := geo.Haversine(p, projected)
+		if distance > m.cfg.SearchRadius {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			edgeID:   edgeID,
+			offset:   t * edge.Length,
+			position: projected,
+			distance: distance,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	if len(candidates) > m.cfg.MaxCandidates {
+		candidates = candidates[:m.cfg.MaxCandidates]
+	}
+	return candidates
+}
+
+func (m *Matcher) emission(c candidate) float64 {
+	z := c.distance / m.cfg.Sigma
+	return -0.5 * z * z
+}
+
+func (m *Matcher) initStep(s *step) {
+	s.scores = make([]float64, len(s.candidates))
+	s.back = make([]int, len(s.candidates))
+	for j, c := range s.candidates {
+		s.scores[j] = m.emission(c)
+		s.back[j] = -1
+	}
+}
+
+// transition fills in the viterbi scores of cur from prev. It reports false when
+// none of cur's candidates is reachable from prev.
+func (m *Matcher) transition(prev, cur *step) bool {
+	greatCircle := geo.Haversine(prev.point, cur.point)
+	maxDist := math.Max(greatCircle*4, greatCircle+2*m.cfg.SearchRadius)
+
+	cur.scores = make([]float64, len(cur.candidates))
+	cur.back = make([]int, len(cur.candidates))
+	for j := range cur.scores {
+		cur.scores[j] = math.Inf(-1)
+		cur.back[j] = -1
+	}
+
+	searches := make(map[int]*shortestPaths)
+	reachable := false
+
+	for i, a := range prev.candidates {
+		if math.IsInf(prev.scores[i], -1) {
+			continue
+		}
+
+		source := m.graph.Edges[a.edgeID].To
+		sp, ok := searches[source]
+		if !ok {
+			sp = m.graph.searchFrom(source, maxDist)
+			searches[source] = sp
+		}
+
+		for j, b := range cur.candidates {
+			route, ok := m.routeDistance(a, b, sp)
+			if !ok {
+				continue
+			}
+
+			score := prev.scores[i] - math.Abs(route-greatCircle)/m.cfg.Beta + m.emission(b)
+			if score > cur.scores[j] {
+				cur.scores[j] = score
+				cur.back[j] = i
+				reachable = true
+			}
+		}
+	}
+
+	return reachable
+}
+
+// routeDistance is the distance along the road network from candidate a to b
+func (m *Matcher) routeDistance(a, b candidate, sp *shortestPaths) (float64, bool) {
+	if a.edgeID == b.edgeID {
+		if b.offset >= a.offset {
+			return b.offset - a.offset, true
+		}
+		// tolerate small backwards jitter along the same edge
+		if a.offset-b.offset <= m.cfg.Sigma {
+			return 0, true
+		}
+	}
+
+	edgeA, edgeB := m.graph.Edges[a.edgeID], m.graph.Edges[b.edgeID]
+	between, ok := sp.dist[edgeB.From]
+	if !ok {
+		return 0, false
+	}
+
+	return (edgeA.Length - a.offset) + between + b.offset, true
+}
+
+// appendSegment backtracks the best path through a run of connected steps and
+// adds its points, geometry and distance to the result
+func (m *Matcher) appendSegment(result *Result, steps []step) {
+	if len(steps) == 0 {
+		return
+	}
+
+	last := steps[len(steps)-1]
+	best := 0
+	for j := range last.scores {
+		if last.scores[j] > last.scores[best] {
+			best = j
+		}
+	}
+
+	chosen := make([]candidate, len(steps))
+	for i := len(steps) - 1; i >= 0; i-- {
+		chosen[i] = steps[i].candidates[best]
+		best = steps[i].back[best]
+	}
+
+	for i, c := range chosen {
+		edge := m.graph.Edges[c.edgeID]
+		result.Points = append(result.Points, MatchedPoint{
+			Index:    steps[i].index,
+			Original: steps[i].point,
+			Snapped:  c.position,
+			WayID:    edge.WayID,
+			RoadName: edge.Name,
+		})
+
+		if i == 0 {
+			result.Geometry = append(result.Geometry, c.position)
+			continue
+		}
+
+		geometry, distance := m.connect(chosen[i-1], c, steps[i-1].point, steps[i].point)
+		result.Geometry = append(result.Geometry, geometry...)
+		result.Distance += distance
+	}
+}
+
+// connect returns the road geometry (excluding a's position) and distance from a to b
+func (m *Matcher) connect(a, b candidate, from, to geo.Point) ([]geo.Point, float64) {
+	if a.edgeID == b.edgeID && b.offset >= a.offset-m.cfg.Sigma {
+		return []geo.Point{b.position}, math.Max(0, b.offset-a.offset)
+	}
+
+	greatCircle := geo.Haversine(from, to)
+	maxDist := math.Max(greatCircle*4, greatCircle+2*m.cfg.SearchRadius)
+
+	edgeA, edgeB := m.graph.Edges[a.edgeID], m.graph.Edges[b.edgeID]
+	sp := m.graph.searchFrom(edgeA.To, maxDist)
+
+	geometry := []geo.Point{m.graph.Nodes[edgeA.To].Position}
+	for _, edgeID := range sp.edgesTo(m.graph, edgeB.From) {
+		geometry = append(geometry, m.graph.Nodes[m.graph.Edges[edgeID].To].Position)
+	}
+	geometry = append(geometry, b.position)
+
+	return geometry, (edgeA.Length - a.offset) + sp.dist[edgeB.From] + b.offset
+}
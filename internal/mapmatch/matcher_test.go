@@ -0,0 +1,91 @@
+package mapmatch
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+// testGraph is a main road along the equator and a parallel road 44m north
+// of it that the main road does not connect to
+func testGraph(t *testing.T) *Graph {
+	t.Helper()
+
+	g, err := ReadGraph(strings.NewReader(`
+n 1 0 0
+n 2 0 0.001
+n 3 0 0.002
+n 4 0 0.003
+w 100 0 1 2 3 4 | Main Street
+n 10 0.0004 0
+n 11 0.0004 0.003
+w 200 0 10 11 | Side Street
+`))
+	if err != nil {
+		t.Fatalf("ReadGraph: %v", err)
+	}
+	return g
+}
+
+func TestMatchFollowsConnectedRoad(t *testing.T) {
+	matcher := NewMatcher(testGraph(t), Config{SearchRadius: 30, Sigma: 5, Beta: 5, MaxCandidates: 8})
+
+	trace := []geo.Point{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 0.0006},
+		// off the road, nearer the side street, which cannot be reached from here
+		{Latitude: 0.00025, Longitude: 0.0012},
+		// far from any road, no candidate
+		{Latitude: 0.002, Longitude: 0.0015},
+		{Latitude: 0, Longitude: 0.0018},
+		{Latitude: 0, Longitude: 0.0024},
+	}
+
+	result, err := matcher.Match(trace)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+
+	var indexes []int
+	for _, point := range result.Points {
+		indexes = append(indexes, point.Index)
+		if point.WayID != 100 || point.RoadName != "Main Street" {
+			t.Errorf("point %d matched to way %d %q, want Main Street", point.Index, point.WayID, point.RoadName)
+		}
+		if math.Abs(point.Snapped.Latitude) > 1e-9 {
+			t.Errorf("point %d snapped to %v, off the main road", point.Index, point.Snapped)
+		}
+	}
+
+	want := []int{0, 1, 2, 4, 5}
+	if len(indexes) != len(want) {
+		t.Fatalf("matched indexes = %v, want %v", indexes, want)
+	}
+	for i := range want {
+		if indexes[i] != want[i] {
+			t.Fatalf("matched indexes = %v, want %v", indexes, want)
+		}
+	}
+
+	if result.Breaks != 0 {
+		t.Errorf("breaks = %d, want 0", result.Breaks)
+	}
+
+	// the snapped points span 0.0024 degrees of longitude along the road
+	wantDistance := geo.Haversine(geo.Point{}, geo.Point{Longitude: 0.0024})
+	if math.Abs(result.Distance-wantDistance) > 1 {
+		t.Errorf("distance = %.1f, want %.1f", result.Distance, wantDistance)
+	}
+}
+
+func TestMatchWithoutCandidates(t *testing.T) {
+	matcher := NewMatcher(testGraph(t), DefaultConfig())
+
+	_, err := matcher.Match([]geo.Point{{Latitude: 1, Longitude: 1}, {Latitude: 1, Longitude: 1.001}})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("err = %v, want ErrNoMatch", err)
+	}
+}
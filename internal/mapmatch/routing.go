@@ -0,0 +1,77 @@
+package mapmatch
+
+import "container/heap"
+
+type queueItem struct {
+	node int
+	dist float64
+}
+
+type priorityQueue []queueItem
+
+func (q priorityQueue) Len() int            { return len(q) }
+func (q priorityQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q priorityQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortestPaths holds the result of a bounded Dijkstra search from one node
+type shortestPaths struct {
+	dist    map[int]float64
+	viaEdge map[int]int
+}
+
+// searchFrom runs Dijkstra from source, giving up on nodes further than maxDist
+func (g *Graph) searchFrom(source int, maxDist float64) *shortestPaths {
+	sp := &shortestPaths{
+		dist:    map[int]float64{source: 0},
+		viaEdge: make(map[int]int),
+	}
+
+	pq := &priorityQueue{{node: source, dist: 0}}
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(queueItem)
+		if item.dist > sp.dist[item.node] {
+			continue
+		}
+
+		for _, edgeID := range g.outgoing[item.node] {
+			edge := g.Edges[edgeID]
+			next := item.dist + edge.Length
+			if next > maxDist {
+				continue
+			}
+			if known, ok := sp.dist[edge.To]; ok && known <= next {
+				continue
+			}
+			sp.dist[edge.To] = next
+			sp.viaEdge[edge.To] = edgeID
+			heap.Push(pq, queueItem{node: edge.To, dist: next})
+		}
+	}
+
+	return sp
+}
+
+// edgesTo walks the search tree back from target and returns the edges on the path
+func (sp *shortestPaths) edgesTo(g *Graph, target int) []int {
+	var edges []int
+	for {
+		edgeID, ok := sp.viaEdge[target]
+		if !ok {
+			break
+		}
+		edges = append(edges, edgeID)
+		target = g.Edges[edgeID].From
+	}
+
+	for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+	return edges
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
+)
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) repository.APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, organization_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	key := &domain.APIKey{}
+	err := row.Scan(
+		&key.ID, &key.OrganizationID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes),
+		&key.LastUsedAt, &key.RevokedAt, &key.CreatedAt, &key.UpdatedAt,
+	)
+	return key, err
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_keys
+			(organization_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, organization_id, created_at, updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, organizationID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes)).Scan(
+		&key.ID, &key.OrganizationID, &key.CreatedAt, &key.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) ListByOrganization(ctx context.Context) (keys []*domain.APIKey, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve api keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate api keys: %w", err)
+	}
+
+	return
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyID, organizationID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve api key %v: %w", keyID, err)
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE prefix = $1
+	`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve api key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepository) UpdateSecret(ctx context.Context, key *domain.APIKey) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE api_keys
+			SET prefix = $2, key_hash = $3, last_used_at = NULL
+		WHERE id = $1 AND organization_id = $4 AND revoked_at IS NULL
+		RETURNING updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, key.ID, key.Prefix, key.KeyHash, organizationID).Scan(&key.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to rotate api key %v: %w", key.ID, err)
+	}
+
+	key.LastUsedAt = nil
+	return nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, keyID string, revokedAt time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE api_keys
+			SET revoked_at = $2
+		WHERE id = $1 AND organization_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, keyID, revokedAt, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke api key %v: %w", keyID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, keyID string, usedAt time.Time) error {
+	query := `
+		UPDATE api_keys
+			SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, keyID, usedAt); err != nil {
+		return fmt.Errorf("Failed to update api key %v last use: %w", keyID, err)
+	}
+
+	return nil
+}
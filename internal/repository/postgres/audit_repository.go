@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) repository.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log
+			(organization_id, actor_type, actor_id, source_ip, action, entity_type, entity_id, before, after, diff)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		organizationID,
+		entry.Actor.Type,
+		entry.Actor.ID,
+		entry.Actor.SourceIP,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+		nullJSON(entry.Diff),
+	).Scan(&entry.ID, &entry.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to append %v to audit log: %w", entry.Action, err)
+	}
+
+	return nil
+}
+
+func (r *auditRepository) List(ctx context.Context, filter domain.AuditFilter) (entries []*domain.AuditEntry, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"organization_id = $1"}
+	args := []interface{}{organizationID}
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EntityType != "" {
+		where("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		where("entity_id = $%d", filter.EntityID)
+	}
+	if filter.ActorID != "" {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+
+	args = append(args, filter.Limit)
+
+	query := `
+		SELECT id, actor_type, actor_id, source_ip, action, entity_type, entity_id, before, after, diff, created_at
+		FROM audit_log
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &domain.AuditEntry{}
+		var actorID, sourceIP sql.NullString
+		var before, after, diff []byte
+
+		err := rows.Scan(
+			&entry.ID, &entry.Actor.Type, &actorID, &sourceIP,
+			&entry.Action, &entry.EntityType, &entry.EntityID,
+			&before, &after, &diff, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan audit entry: %w", err)
+		}
+
+		entry.Actor.ID = actorID.String
+		entry.Actor.SourceIP = sourceIP.String
+		entry.Before = before
+		entry.After = after
+		entry.Diff = diff
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate audit log: %w", err)
+	}
+
+	return
+}
+
+// nullJSON stores absent JSON as NULL rather than an empty string, which is not valid JSONB
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type riderCredentialRepository struct {
+	db *sql.DB
+}
+
+func NewRiderCredentialRepository(db *sql.DB) repository.RiderCredentialRepository {
+	return &riderCredentialRepository{db: db}
+}
+
+const riderCredentialColumns = `id, organization_id, rider_id, name, prefix, token_hash, last_used_at, revoked_at, created_at`
+
+func scanRiderCredential(row rowScanner) (*domain.RiderCredential, error) {
+	credential := &domain.RiderCredential{}
+	err := row.Scan(
+		&credential.ID, &credential.OrganizationID, &credential.RiderID, &credential.Name, &credential.Prefix,
+		&credential.TokenHash, &credential.LastUsedAt, &credential.RevokedAt, &credential.CreatedAt,
+	)
+	return credential, err
+}
+
+func (r *riderCredentialRepository) Create(ctx context.Context, credential *domain.RiderCredential) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the rider must belong to the same organization
+	query := `
+		INSERT INTO rider_credentials
+			(organization_id, rider_id, name, prefix, token_hash)
+		SELECT organization_id, id, $3, $4, $5
+		FROM riders
+		WHERE id = $1 AND organization_id = $2
+		RETURNING id, organization_id, created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, credential.RiderID, organizationID, credential.Name, credential.Prefix, credential.TokenHash).Scan(
+		&credential.ID, &credential.OrganizationID, &credential.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to create rider credential: %w", err)
+	}
+
+	return nil
+}
+
+func (r *riderCredentialRepository) ListByRiderID(ctx context.Context, riderID string) (credentials []*domain.RiderCredential, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + riderCredentialColumns + `
+		FROM rider_credentials
+		WHERE rider_id = $1 AND organization_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, riderID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve credentials of rider %v: %w", riderID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		credential, err := scanRiderCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan rider credential: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate rider credentials: %w", err)
+	}
+
+	return credentials, nil
+}
+
+func (r *riderCredentialRepository) GetByPrefix(ctx context.Context, prefix string) (*domain.RiderCredential, error) {
+	query := `SELECT ` + riderCredentialColumns + `
+		FROM rider_credentials
+		WHERE prefix = $1
+	`
+
+	credential, err := scanRiderCredential(conn(ctx, r.db).QueryRowContext(ctx, query, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve rider credential: %w", err)
+	}
+
+	return credential, nil
+}
+
+func (r *riderCredentialRepository) Revoke(ctx context.Context, riderID, credentialID string, revokedAt time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE rider_credentials
+			SET revoked_at = $3
+		WHERE id = $1 AND rider_id = $2 AND organization_id = $4 AND revoked_at IS NULL
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, credentialID, riderID, revokedAt, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke rider credential %v: %w", credentialID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *riderCredentialRepository) TouchLastUsed(ctx context.Context, credentialID string, usedAt time.Time) error {
+	query := `
+		UPDATE rider_credentials
+			SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, credentialID, usedAt); err != nil {
+		return fmt.Errorf("Failed to update rider credential %v last use: %w", credentialID, err)
+	}
+
+	return nil
+}
+
+type ticketRepository struct {
+	db *sql.DB
+}
+
+func NewTicketRepository(db *sql.DB) repository.TicketRepository {
+	return &ticketRepository{db: db}
+}
+
+func (r *ticketRepository) Create(ctx context.Context, ticket *domain.AccessTicket) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// expired tickets are cleared as new ones are issued
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM access_tickets WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("Failed to clear expired tickets: %w", err)
+	}
+
+	query := `
+		INSERT INTO access_tickets
+			(token_hash, organization_id, scope, actor_type, actor_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, ticket.TokenHash, organizationID, ticket.Scope, ticket.Actor.Type, ticket.Actor.ID, ticket.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("Failed to create access ticket: %w", err)
+	}
+
+	ticket.OrganizationID = organizationID
+	return nil
+}
+
+func (r *ticketRepository) Consume(ctx context.Context, tokenHash []byte, now time.Time) (*domain.AccessTicket, error) {
+	query := `
+		DELETE FROM access_tickets
+		WHERE token_hash = $1 AND expires_at > $2
+		RETURNING token_hash, organization_id, scope, actor_type, actor_id, expires_at
+	`
+
+	ticket := &domain.AccessTicket{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, tokenHash, now).Scan(
+		&ticket.TokenHash, &ticket.OrganizationID, &ticket.Scope, &ticket.Actor.Type, &ticket.Actor.ID, &ticket.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to redeem access ticket: %w", err)
+	}
+
+	return ticket, nil
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type deliveryRepository struct {
+	db *sql.DB
+}
+
+func NewDeliveryRepository(db *sql.DB) repository.DeliveryRepository {
+	return &deliveryRepository{db: db}
+}
+
+func (r *deliveryRepository) Create(ctx context.Context, delivery *domain.Delivery) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO deliveries
+			(pickup, dropoff, status, assigned_session_id, organization_id)
+		VALUES
+			(ST_SetSRID(ST_MakePoint($1, $2), 4326), ST_SetSRID(ST_MakePoint($3, $4), 4326), $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		delivery.Pickup.Longitude,
+		delivery.Pickup.Latitude,
+		delivery.Dropoff.Longitude,
+		delivery.Dropoff.Latitude,
+		delivery.Status,
+		delivery.AssignedSessionID,
+		organizationID,
+	).Scan(&delivery.ID, &delivery.CreatedAt, &delivery.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) GetByID(ctx context.Context, deliveryID string) (*domain.Delivery, error) {
+	return r.get(ctx, deliveryID, "")
+}
+
+func (r *deliveryRepository) GetForUpdate(ctx context.Context, deliveryID string) (*domain.Delivery, error) {
+	return r.get(ctx, deliveryID, "FOR UPDATE")
+}
+
+func (r *deliveryRepository) get(ctx context.Context, deliveryID, lock string) (*domain.Delivery, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &domain.Delivery{}
+
+	query := `
+		SELECT
+			id,
+			ST_Y(pickup::geometry), ST_X(pickup::geometry),
+			ST_Y(dropoff::geometry), ST_X(dropoff::geometry),
+			status, assigned_session_id, picked_up_at, arrived_at_dropoff_at, delivered_at, created_at, updated_at,
+			dispatch_expires_at
+		FROM deliveries
+		WHERE id = $1 AND organization_id = $2
+		` + lock
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, deliveryID, organizationID).Scan(
+		&delivery.ID,
+		&delivery.Pickup.Latitude, &delivery.Pickup.Longitude,
+		&delivery.Dropoff.Latitude, &delivery.Dropoff.Longitude,
+		&delivery.Status, &delivery.AssignedSessionID, &delivery.PickedUpAt, &delivery.ArrivedAtDropoff, &delivery.DeliveredAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt, &delivery.DispatchExpiresAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve delivery %v: %w", deliveryID, err)
+	}
+
+	return delivery, nil
+}
+
+func (r *deliveryRepository) Update(ctx context.Context, delivery *domain.Delivery) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE deliveries
+			SET status = $2, assigned_session_id = $3, picked_up_at = $4, arrived_at_dropoff_at = $5, delivered_at = $6,
+				dispatch_expires_at = $8
+		WHERE id = $1 AND organization_id = $7
+		RETURNING updated_at;
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		delivery.ID,
+		delivery.Status,
+		delivery.AssignedSessionID,
+		delivery.PickedUpAt,
+		delivery.ArrivedAtDropoff,
+		delivery.DeliveredAt,
+		organizationID,
+		delivery.DispatchExpiresAt,
+	).Scan(&delivery.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to update delivery %v: %w", delivery.ID, err)
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) RenewDispatch(ctx context.Context, deliveryID string, claimedUntil, until time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE deliveries
+			SET dispatch_expires_at = $4
+		WHERE id = $1 AND organization_id = $2
+			AND status = $5 AND dispatch_expires_at = $3
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, deliveryID, organizationID, claimedUntil, until, domain.DeliveryDispatching)
+	if err != nil {
+		return fmt.Errorf("Failed to renew dispatch of delivery %v: %w", deliveryID, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Failed to renew dispatch of delivery %v: %w", deliveryID, err)
+	}
+	if rows == 0 {
+		return repository.ErrConflict
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) ListExpiredDispatches(ctx context.Context, limit int) (expired []*domain.ExpiredDispatch, err error) {
+	// claims made before leases existed have no expiry, they are as good as expired
+	query := `
+		SELECT organization_id, id
+		FROM deliveries
+		WHERE status = $1 AND (dispatch_expires_at IS NULL OR dispatch_expires_at < NOW())
+		ORDER BY dispatch_expires_at NULLS FIRST
+		LIMIT $2
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, domain.DeliveryDispatching, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve expired dispatches: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		dispatch := &domain.ExpiredDispatch{}
+		if err := rows.Scan(&dispatch.OrganizationID, &dispatch.DeliveryID); err != nil {
+			return nil, fmt.Errorf("Failed to Scan expired dispatch: %w", err)
+		}
+		expired = append(expired, dispatch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve expired dispatches: %w", err)
+	}
+
+	return expired, nil
+}
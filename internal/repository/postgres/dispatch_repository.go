@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type dispatchRepository struct {
+	db *sql.DB
+}
+
+func NewDispatchRepository(db *sql.DB) repository.DispatchRepository {
+	return &dispatchRepository{db: db}
+}
+
+func (r *dispatchRepository) ListCandidates(ctx context.Context, point domain.Coordinate, radiusMeters float64) (candidates []*domain.DispatchCandidate, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			l.session_id, ST_Y(l.location::geometry), ST_X(l.location::geometry),
+			COALESCE(d.load, 0)
+		FROM session_latest_locations l
+		JOIN tracking_sessions s ON s.organization_id = l.organization_id AND s.session_id = l.session_id
+		JOIN riders rd ON rd.organization_id = s.organization_id AND rd.id = s.rider_id
+		LEFT JOIN (
+			SELECT assigned_session_id, COUNT(*) AS load
+			FROM deliveries
+			WHERE organization_id = $4 AND status IN ($5, $6)
+			GROUP BY assigned_session_id
+		) d ON d.assigned_session_id = l.session_id
+		WHERE l.organization_id = $4
+			AND s.is_active = true AND s.paused_at IS NULL
+			AND rd.availability = $7 AND rd.shift_start IS NOT NULL AND rd.shift_end IS NULL
+			AND ST_DWithin(l.location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		ORDER BY ST_Distance(l.location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query,
+		point.Latitude, point.Longitude, radiusMeters, organizationID,
+		domain.DeliveryAssigned, domain.DeliveryInTransit, domain.RiderAvailable,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve dispatch candidates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		candidate := &domain.DispatchCandidate{}
+		if err := rows.Scan(&candidate.SessionID, &candidate.Location.Latitude, &candidate.Location.Longitude, &candidate.Load); err != nil {
+			return nil, fmt.Errorf("Failed to Scan dispatch candidate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve dispatch candidates: %w", err)
+	}
+
+	return candidates, nil
+}
+
+func (r *dispatchRepository) CreateOffer(ctx context.Context, offer *domain.DispatchOffer) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO dispatch_offers
+			(id, organization_id, delivery_id, session_id, distance, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, offer.ID, organizationID, offer.DeliveryID, offer.SessionID, offer.Distance, offer.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("Failed to create dispatch offer for delivery %v: %w", offer.DeliveryID, err)
+	}
+
+	return nil
+}
+
+func (r *dispatchRepository) RespondToOffer(ctx context.Context, offerID, sessionID string, accepted bool) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE dispatch_offers
+			SET accepted = $4, responded_at = NOW()
+		WHERE id = $1 AND session_id = $2 AND organization_id = $3
+			AND responded_at IS NULL AND expires_at > NOW()
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, offerID, sessionID, organizationID, accepted)
+	if err != nil {
+		return fmt.Errorf("Failed to respond to dispatch offer %v: %w", offerID, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Failed to respond to dispatch offer %v: %w", offerID, err)
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *dispatchRepository) GetOfferResponse(ctx context.Context, offerID string) (accepted *bool, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, `SELECT accepted FROM dispatch_offers WHERE id = $1 AND organization_id = $2`, offerID, organizationID).Scan(&accepted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve dispatch offer %v: %w", offerID, err)
+	}
+
+	return accepted, nil
+}
+
+func (r *dispatchRepository) CloseOffer(ctx context.Context, offerID string) (accepted bool, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// the outer SELECT sees the row as it was before the update, with the
+	// rider's answer if there was one
+	query := `
+		WITH closed AS (
+			UPDATE dispatch_offers
+				SET accepted = false, responded_at = NOW()
+			WHERE id = $1 AND organization_id = $2 AND responded_at IS NULL
+		)
+		SELECT COALESCE(accepted, false)
+		FROM dispatch_offers
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, offerID, organizationID).Scan(&accepted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, repository.ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to close dispatch offer %v: %w", offerID, err)
+	}
+
+	return accepted, nil
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/lib/pq"
+)
+
+// foreignKeyViolation is the SQLSTATE of an insert naming a missing row
+const foreignKeyViolation = "23503"
+
+// invalidData marks data exceptions and integrity constraint violations with
+// repository.ErrInvalidData, the database refuses the values themselves
+func invalidData(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Class() {
+	case "22", "23":
+		return fmt.Errorf("%w: %w", repository.ErrInvalidData, err)
+	}
+	return err
+}
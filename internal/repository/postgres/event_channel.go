@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+)
+
+// relayedEventsChannel carries every relayed event to all instances
+const relayedEventsChannel = "relayed_events"
+
+// eventNotification is the payload sent on relayedEventsChannel. An event
+// too large for a notification is left out, only its organization is named.
+type eventNotification struct {
+	OrganizationID string        `json:"organizationId"`
+	Event          *domain.Event `json:"event,omitempty"`
+}
+
+// EventChannel fans relayed events out to every instance over NOTIFY
+type EventChannel struct {
+	db  *sql.DB
+	dsn string
+}
+
+func NewEventChannel(db *sql.DB, dsn string) *EventChannel {
+	return &EventChannel{db: db, dsn: dsn}
+}
+
+// Broadcast sends the event to every listening instance, this one included
+func (c *EventChannel) Broadcast(ctx context.Context, event *domain.Event) error {
+	payload, err := json.Marshal(eventNotification{OrganizationID: event.OrganizationID, Event: event})
+	if err != nil {
+		return fmt.Errorf("Failed to encode event %v: %w", event.ID, err)
+	}
+	if len(payload) > notifyPayloadLimit {
+		payload, _ = json.Marshal(eventNotification{OrganizationID: event.OrganizationID})
+	}
+
+	if _, err := c.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, relayedEventsChannel, string(payload)); err != nil {
+		return fmt.Errorf("Failed to broadcast event %v: %w", event.ID, err)
+	}
+
+	return nil
+}
+
+// Listen calls received for every broadcast event until ctx is cancelled.
+// missed is called with the organization of an event too large to be sent,
+// and with an empty organization when the connection dropped and events of
+// any organization may have been lost.
+func (c *EventChannel) Listen(ctx context.Context, received func(event *domain.Event), missed func(organizationID string)) error {
+	return listen(ctx, c.dsn, relayedEventsChannel, func(payload string) {
+		var notification struct {
+			OrganizationID string          `json:"organizationId"`
+			Event          json.RawMessage `json:"event"`
+		}
+		if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+			log.Printf("[EVENT] malformed notification: %v", err)
+			return
+		}
+
+		if notification.Event == nil {
+			missed(notification.OrganizationID)
+			return
+		}
+
+		event, err := decodeOutboxEvent(notification.Event)
+		if err != nil {
+			log.Printf("[EVENT] malformed notification: %v", err)
+			return
+		}
+		received(event)
+	}, func() {
+		missed("")
+	})
+}
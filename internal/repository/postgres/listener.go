@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// notifyPayloadLimit keeps notifications under the 8000 bytes postgres accepts
+const notifyPayloadLimit = 7900
+
+// listen calls received with the payload of every notification on channel
+// until ctx is cancelled. The connection is re-established when it drops;
+// notifications sent meanwhile are lost, so missed is called once it is back.
+func listen(ctx context.Context, dsn, channel string, received func(payload string), missed func()) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[LISTEN] %s: %v", channel, err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return fmt.Errorf("Failed to listen on %v: %w", channel, err)
+	}
+
+	// a quiet connection is checked now and then so a dead one is noticed
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case notification := <-listener.Notify:
+			// nil after a reconnect
+			if notification == nil {
+				missed()
+				continue
+			}
+			received(notification.Extra)
+		case <-ping.C:
+			go listener.Ping()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -3,10 +3,15 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/SarkiMudboy/easebox-api/internal/domain"
 	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
 )
 
 type locationRepository struct {
@@ -18,16 +23,52 @@ func NewLocationRepository(db *sql.DB) repository.LocationRepository {
 }
 
 
+// scanLocations reads rows selected as
+// id, session_id, delivery_id, latitude, longitude, accuracy, speed, heading, recorded_at, created_at
+func scanLocations(rows *sql.Rows) (locations []*domain.LocationUpdate, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		location := &domain.LocationUpdate{}
+		var deliveryID sql.NullString
+
+		err = rows.Scan(
+			&location.ID, &location.SessionID, &deliveryID, &location.Latitude, &location.Longitude, &location.Accuracy, &location.Speed, &location.Heading, &location.RecordedAt, &location.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan location: %w", err)
+		}
+		location.DeliveryID = deliveryID.String
+		locations = append(locations, location)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate locations: %w", err)
+	}
+
+	return
+}
+
 func (r *locationRepository) Create(ctx context.Context, location *domain.LocationUpdate) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// selecting from tracking_sessions guarantees the point is only written to a
+	// session owned by the caller's organization
 	query := `
 		INSERT INTO location_updates 
-			(session_id, delivery_id, location, accuracy, speed, heading, recorded_at) 
-		VALUES 
-			($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326), $5, $6, $7, $8) 
+			(organization_id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at) 
+		SELECT
+			ts.organization_id, ts.session_id, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326), $5, $6, $7, $8
+		FROM tracking_sessions ts
+		WHERE ts.session_id = $1 AND ts.organization_id = $9
 		RETURNING id, created_at
 	`
 
-	err := r.db.QueryRowContext(
+	err = conn(ctx, r.db).QueryRowContext(
 		ctx, 
 		query,
 		location.SessionID,
@@ -38,10 +79,14 @@ func (r *locationRepository) Create(ctx context.Context, location *domain.Locati
 		location.Speed, 
 		location.Heading, 
 		location.RecordedAt,
+		organizationID,
 		).Scan(
 			&location.ID, &location.CreatedAt,
 		)
-	
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("Failed to create location update entry: %w", err)
 	}
@@ -49,99 +94,292 @@ func (r *locationRepository) Create(ctx context.Context, location *domain.Locati
 	return nil
 }
 
-func (r *locationRepository) GetBySessionID(ctx context.Context, sessionID string) (locations []*domain.LocationUpdate, err error) {
-	query := `
-		SELECT 
-			(id, delivery_id, ST_Y(location::geometry) as latitude, ST_X(location::geometry) as longitude, accuracy, speed, heading, recorded_at, created_at) 
-		FROM location_updates 
-			WHERE session_id = $1
-		ORDER BY recorded_at ASC
-	`
-	rows, err := r.db.QueryContext(ctx, query, sessionID)
+// locationBatchColumns is the number of parameters bound per row by CreateBatch
+const locationBatchColumns = 9
+
+func (r *locationRepository) CreateBatch(ctx context.Context, locations []*domain.LocationUpdate) error {
+	organizationID, err := tenant.Require(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve location update: %w", err)
+		return err
 	}
 
-	for rows.Next() {
-		location := &domain.LocationUpdate{}
+	if len(locations) == 0 {
+		return nil
+	}
 
-		err = rows.Scan(
-			&location.ID, location.DeliveryID, &location.Latitude, &location.Accuracy, &location.Speed, &location.Heading, &location.RecordedAt, &location.CreatedAt,
+	// ids are taken up front so inserted rows can be matched back to the input
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT nextval(pg_get_serial_sequence('location_updates', 'id')) FROM generate_series(1, $1)`, len(locations))
+	if err != nil {
+		return fmt.Errorf("Failed to allocate location ids: %w", err)
+	}
+
+	byID := make(map[int64]*domain.LocationUpdate, len(locations))
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(&locations[i].ID); err != nil {
+			rows.Close()
+			return fmt.Errorf("Failed to Scan location id: %w", err)
+		}
+		byID[locations[i].ID] = locations[i]
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Failed to allocate location ids: %w", err)
+	}
+
+	values := make([]string, 0, len(locations))
+	args := make([]interface{}, 0, len(locations)*locationBatchColumns+1)
+	args = append(args, organizationID)
+
+	for _, location := range locations {
+		n := len(args)
+		values = append(values, fmt.Sprintf(
+			"($%d::bigint, $%d::text, $%d::text, $%d::float8, $%d::float8, $%d::float8, $%d::float8, $%d::float8, $%d::timestamptz)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9,
+		))
+		args = append(args,
+			location.ID, location.SessionID, location.DeliveryID,
+			location.Longitude, location.Latitude, location.Accuracy,
+			location.Speed, location.Heading, location.RecordedAt,
 		)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("Failed to Scan location: %w", err)
+	// delivery ids come from the client, one malformed id must not fail the batch
+	query := `
+		INSERT INTO location_updates
+			(id, organization_id, session_id, delivery_id, location, accuracy, speed, heading, recorded_at)
+		SELECT
+			v.id, ts.organization_id, ts.session_id,
+			CASE WHEN v.delivery_id ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' THEN v.delivery_id::uuid END,
+			ST_SetSRID(ST_MakePoint(v.lon, v.lat), 4326), v.accuracy, v.speed, v.heading, v.recorded_at
+		FROM (VALUES ` + strings.Join(values, ", ") + `) AS v(id, session_id, delivery_id, lon, lat, accuracy, speed, heading, recorded_at)
+		JOIN tracking_sessions ts ON ts.session_id = v.session_id AND ts.organization_id = $1
+		RETURNING id, created_at
+	`
+
+	inserted, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("Failed to create location batch: %w", invalidData(err))
+	}
+	defer inserted.Close()
+
+	for inserted.Next() {
+		var id int64
+		var createdAt time.Time
+		if err := inserted.Scan(&id, &createdAt); err != nil {
+			return fmt.Errorf("Failed to Scan inserted location: %w", err)
+		}
+		if location, ok := byID[id]; ok {
+			location.CreatedAt = createdAt
 		}
-		locations = append(locations, location)
 	}
 
-	defer rows.Close()
+	if err := inserted.Err(); err != nil {
+		return fmt.Errorf("Failed to create location batch: %w", invalidData(err))
+	}
 
-	return
+	return nil
 }
 
-func (r *locationRepository) GetByDeliveryID(ctx context.Context, deliveryID string) (locations []*domain.LocationUpdate, err error) {
+func (r *locationRepository) GetBySessionID(ctx context.Context, sessionID string) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT 
-			id, session_id, ST_Y(location::geometry) as latitude, ST_X(location::geometry) as longitude, accuracy, speed, heading, recorded_at, created_at
+			id, session_id, delivery_id, ST_Y(location::geometry) as latitude, ST_X(location::geometry) as longitude, accuracy, speed, heading, recorded_at, created_at
 		FROM location_updates 
-			WHERE delivery_id = $1
+			WHERE session_id = $1 AND organization_id = $2
 		ORDER BY recorded_at ASC
 	`
-	rows, err := r.db.QueryContext(ctx, query, deliveryID)
-	
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, sessionID, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve location update: %w", err)
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		location := &domain.LocationUpdate{}
+	return scanLocations(rows)
+}
 
-		err = rows.Scan(
-			&location.ID, &location.SessionID, &location.Latitude, &location.Longitude, &location.Accuracy, &location.Speed, &location.Heading, &location.RecordedAt, &location.CreatedAt,
-		)
+func (r *locationRepository) GetByDeliveryID(ctx context.Context, deliveryID string) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("Failed to Scan location: %w", err)
-		}
-		locations = append(locations, location)
+	query := `
+		SELECT 
+			id, session_id, delivery_id, ST_Y(location::geometry) as latitude, ST_X(location::geometry) as longitude, accuracy, speed, heading, recorded_at, created_at
+		FROM location_updates 
+			WHERE delivery_id = $1 AND organization_id = $2
+		ORDER BY recorded_at ASC
+	`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, deliveryID, organizationID)
+	
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve location update: %w", err)
 	}
 
-	return
+	return scanLocations(rows)
 }
 
 func (r *locationRepository) GetLatestBySessionID(ctx context.Context, sessionID string) (*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	location := &domain.LocationUpdate{}
+	var deliveryID sql.NullString
 
 	query := `
-		SELECT DISTINCT ON (session_id)
-			id,
-			session_id,
-			ST_Y(location::geometry) AS latitude,
-			ST_X(location::geometry) AS longitude,
-			accuracy,
-			speed,
-			heading,
-			recorded_at,
-			created_at
-		FROM location_updates
-		WHERE session_id = $1
-		ORDER BY session_id, recorded_at DESC
-		LIMIT 1;
+		SELECT ` + latestLocationColumns + `
+		FROM session_latest_locations
+		WHERE session_id = $1 AND organization_id = $2;
 	`
 
-	err := r.db.QueryRowContext(ctx, query, sessionID).Scan(
-		&location.ID, &location.SessionID, &location.Latitude, &location.Longitude, &location.Accuracy, &location.Speed, &location.Heading, &location.RecordedAt, &location.CreatedAt,
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, sessionID, organizationID).Scan(
+		&location.ID, &location.SessionID, &deliveryID, &location.Latitude, &location.Longitude, &location.Accuracy, &location.Speed, &location.Heading, &location.RecordedAt, &location.CreatedAt,
 	)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve location: %v", err)
 	}
 
+	location.DeliveryID = deliveryID.String
+
 	return location, nil
 }
 
-// func GetWithinRadius(ctx context.Context, lat, long, radiusMeters float64) ([]*domain.LocationUpdate, error) {}
\ No newline at end of file
+// latestLocationColumns selects a session_latest_locations row in the column order scanLocations expects
+const latestLocationColumns = `location_id, session_id, delivery_id, ST_Y(location::geometry) AS latitude, ST_X(location::geometry) AS longitude, accuracy, speed, heading, recorded_at, created_at`
+
+func (r *locationRepository) GetLatestBySessionIDs(ctx context.Context, sessionIDs []string) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + latestLocationColumns + `
+		FROM session_latest_locations
+		WHERE session_id = ANY($1) AND organization_id = $2
+		ORDER BY session_id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(sessionIDs), organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve latest locations: %w", err)
+	}
+
+	return scanLocations(rows)
+}
+
+func (r *locationRepository) GetLatestActive(ctx context.Context) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + latestLocationColumns + `
+		FROM active_session_latest_locations
+		WHERE organization_id = $1
+		ORDER BY session_id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve latest locations: %w", err)
+	}
+
+	return scanLocations(rows)
+}
+
+func (r *locationRepository) GetLatestWithinBounds(ctx context.Context, bounds domain.BoundingBox) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// && against the envelope uses the GIST index
+	query := `
+		SELECT ` + latestLocationColumns + `
+		FROM active_session_latest_locations
+		WHERE organization_id = $5
+			AND location && ST_MakeEnvelope($1, $2, $3, $4, 4326)::geography
+		ORDER BY session_id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, bounds.MinLongitude, bounds.MinLatitude, bounds.MaxLongitude, bounds.MaxLatitude, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve locations within bounds: %w", err)
+	}
+
+	return scanLocations(rows)
+}
+
+func (r *locationRepository) GetWithinRadius(ctx context.Context, lat, long, radiusMeters float64) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + latestLocationColumns + `
+		FROM active_session_latest_locations
+		WHERE organization_id = $4
+			AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		ORDER BY ST_Distance(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, lat, long, radiusMeters, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve locations within radius: %w", err)
+	}
+
+	return scanLocations(rows)
+}
+
+func (r *locationRepository) GetByRiderID(ctx context.Context, riderID string, from, to time.Time) ([]*domain.LocationUpdate, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			lu.id, lu.session_id, lu.delivery_id, ST_Y(lu.location::geometry) AS latitude, ST_X(lu.location::geometry) AS longitude, lu.accuracy, lu.speed, lu.heading, lu.recorded_at, lu.created_at
+		FROM location_updates lu
+		JOIN tracking_sessions ts ON ts.organization_id = lu.organization_id AND ts.session_id = lu.session_id
+		WHERE ts.rider_id = $1 AND lu.recorded_at >= $2 AND lu.recorded_at < $3
+			AND ts.organization_id = $4 AND lu.organization_id = $4
+		ORDER BY lu.recorded_at ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, riderID, from, to, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve locations for rider %v: %w", riderID, err)
+	}
+
+	return scanLocations(rows)
+}
+
+func (r *locationRepository) DeleteBySessionID(ctx context.Context, sessionID string) (int64, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `DELETE FROM location_updates WHERE session_id = $1 AND organization_id = $2`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, sessionID, organizationID)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to delete locations of session %v: %w", sessionID, err)
+	}
+
+	return result.RowsAffected()
+}
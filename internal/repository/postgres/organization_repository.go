@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+type organizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) repository.OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) Create(ctx context.Context, organization *domain.Organization) error {
+	query := `
+		INSERT INTO organizations (name)
+		VALUES ($1)
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, organization.Name).Scan(&organization.ID, &organization.CreatedAt, &organization.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("Failed to create organization: %w", err)
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, organizationID string) (*domain.Organization, error) {
+	organization := &domain.Organization{}
+
+	query := `
+		SELECT id, name, created_at, updated_at
+		FROM organizations
+		WHERE id = $1;
+	`
+
+	err := r.db.QueryRowContext(ctx, query, organizationID).Scan(&organization.ID, &organization.Name, &organization.CreatedAt, &organization.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve organization %v: %w", organizationID, err)
+	}
+
+	return organization, nil
+}
@@ -0,0 +1,282 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
+)
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) repository.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Add(ctx context.Context, event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("Failed to encode event %v: %w", event.Type, err)
+	}
+
+	query := `
+		INSERT INTO outbox_events
+			(event_id, organization_id, event_type, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, event.ID, event.OrganizationID, event.Type, payload, event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("Failed to write %v event to outbox: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) AddBatch(ctx context.Context, events []*domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(events))
+	args := make([]interface{}, 0, len(events)*5)
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("Failed to encode event %v: %w", event.Type, err)
+		}
+
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, event.ID, event.OrganizationID, event.Type, payload, event.OccurredAt)
+	}
+
+	query := `
+		INSERT INTO outbox_events
+			(event_id, organization_id, event_type, payload, occurred_at)
+		VALUES ` + strings.Join(values, ", ")
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("Failed to write %d events to outbox: %w", len(events), err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) ClaimUnpublished(ctx context.Context, limit int, lease time.Duration) (events []*domain.OutboxEvent, err error) {
+	query := `
+		UPDATE outbox_events o
+			SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE o.id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING o.id, o.attempts, o.delivered_sinks, o.payload
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	type claimed struct {
+		id    int64
+		event *domain.OutboxEvent
+	}
+	var batch []claimed
+
+	for rows.Next() {
+		var id int64
+		var attempts int
+		var delivered []string
+		var payload []byte
+		if err := rows.Scan(&id, &attempts, pq.Array(&delivered), &payload); err != nil {
+			return nil, fmt.Errorf("Failed to Scan outbox event: %w", err)
+		}
+
+		event, err := decodeOutboxEvent(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode outbox event %d: %w", id, err)
+		}
+
+		batch = append(batch, claimed{id: id, event: &domain.OutboxEvent{Event: event, Attempts: attempts, DeliveredSinks: delivered}})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate outbox events: %w", err)
+	}
+
+	// RETURNING does not guarantee order
+	sort.Slice(batch, func(i, j int) bool { return batch[i].id < batch[j].id })
+
+	for _, c := range batch {
+		events = append(events, c.event)
+	}
+
+	return
+}
+
+func (r *outboxRepository) ListPublishedAfter(ctx context.Context, afterEventID, deliveryID string, limit int) (events []*domain.Event, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterID int64
+	err = conn(ctx, r.db).QueryRowContext(ctx, `SELECT id FROM outbox_events WHERE event_id = $1 AND organization_id = $2`, afterEventID, organizationID).Scan(&afterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve outbox event %v: %w", afterEventID, err)
+	}
+
+	// the delivery's own events, and those of every session named in them
+	query := `
+		SELECT payload
+		FROM outbox_events
+		WHERE organization_id = $1 AND id > $2 AND published_at IS NOT NULL
+			AND (
+				payload->'data'->>'deliveryId' = $3
+				OR payload->'data'->>'sessionId' IN (
+					SELECT d.payload->'data'->>'sessionId'
+					FROM outbox_events d
+					WHERE d.organization_id = $1 AND d.payload->'data'->>'deliveryId' = $3
+						AND d.payload->'data'->>'sessionId' <> ''
+					UNION
+					SELECT assigned_session_id FROM deliveries WHERE id = $3::uuid AND organization_id = $1
+				)
+			)
+		ORDER BY id
+		LIMIT $4
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, organizationID, afterID, deliveryID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("Failed to Scan outbox event: %w", err)
+		}
+
+		event, err := decodeOutboxEvent(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode outbox event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate outbox events: %w", err)
+	}
+
+	return
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, eventID string) error {
+	query := `
+		UPDATE outbox_events
+			SET published_at = NOW(), attempts = attempts + 1, last_error = NULL
+		WHERE event_id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, eventID); err != nil {
+		return fmt.Errorf("Failed to mark outbox event %v published: %w", eventID, err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) ScheduleRetry(ctx context.Context, eventID string, deliveredSinks []string, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE outbox_events
+			SET attempts = attempts + 1, delivered_sinks = $2, next_attempt_at = $3, last_error = $4
+		WHERE event_id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, eventID, pq.Array(deliveredSinks), nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("Failed to schedule outbox event %v: %w", eventID, err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) DeadLetter(ctx context.Context, eventID string, failures map[string]string) error {
+	sinks := make([]string, 0, len(failures))
+	errs := make([]string, 0, len(failures))
+	for sink, message := range failures {
+		sinks = append(sinks, sink)
+		errs = append(errs, message)
+	}
+
+	// one statement, the event is never finished without its dead letters
+	query := `
+		WITH dead AS (
+			UPDATE outbox_events
+				SET published_at = NOW(), attempts = attempts + 1, last_error = $4
+			WHERE event_id = $1
+			RETURNING event_id, organization_id, event_type, payload, attempts
+		)
+		INSERT INTO outbox_dead_letters
+			(event_id, sink, organization_id, event_type, payload, attempts, last_error)
+		SELECT d.event_id, f.sink, d.organization_id, d.event_type, d.payload, d.attempts, f.error
+		FROM dead d, unnest($2::text[], $3::text[]) AS f(sink, error)
+		ON CONFLICT (event_id, sink) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, eventID, pq.Array(sinks), pq.Array(errs), fmt.Sprint(failures))
+	if err != nil {
+		return fmt.Errorf("Failed to dead letter outbox event %v: %w", eventID, err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM outbox_events
+		WHERE published_at IS NOT NULL AND published_at < $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to prune outbox: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// decodeOutboxEvent restores a stored event. Data stays raw JSON, consumers
+// forward it as is.
+func decodeOutboxEvent(payload []byte) (*domain.Event, error) {
+	var event struct {
+		domain.Event
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	event.Event.Data = event.Data
+	return &event.Event, nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/lib/pq"
+)
+
+const (
+	locationPartitionPrefix  = "location_updates_p"
+	locationDefaultPartition = "location_updates_default"
+	// suffix of a monthly partition name, see migration 000010
+	locationPartitionLayout = "200601"
+)
+
+type partitionRepository struct {
+	db *sql.DB
+}
+
+func NewPartitionRepository(db *sql.DB) repository.PartitionRepository {
+	return &partitionRepository{db: db}
+}
+
+func (r *partitionRepository) ListLocationPartitions(ctx context.Context) (partitions []*domain.Partition, err error) {
+	// detached partitions are plain tables, so they are found by name
+	query := `
+		SELECT c.relname, c.relispartition, c.reltuples::bigint, pg_total_relation_size(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind = 'r'
+			AND (c.relname ~ '^location_updates_p[0-9]{6}$' OR c.relname = $1)
+		ORDER BY c.relname
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, locationDefaultPartition)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list location partitions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		partition := &domain.Partition{}
+		if err := rows.Scan(&partition.Name, &partition.Attached, &partition.EstimatedRows, &partition.SizeBytes); err != nil {
+			return nil, fmt.Errorf("Failed to Scan location partition: %w", err)
+		}
+
+		if month, err := time.Parse(locationPartitionLayout, strings.TrimPrefix(partition.Name, locationPartitionPrefix)); err == nil {
+			to := month.AddDate(0, 1, 0)
+			partition.From = &month
+			partition.To = &to
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list location partitions: %w", err)
+	}
+
+	return partitions, nil
+}
+
+func (r *partitionRepository) CreateLocationPartition(ctx context.Context, month time.Time) (string, error) {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	name := locationPartitionPrefix + from.Format(locationPartitionLayout)
+	// DDL takes no parameters, the bounds are formatted from time values
+	bounds := fmt.Sprintf(`FOR VALUES FROM ('%s') TO ('%s')`, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	var stranded bool
+	query := `SELECT EXISTS (SELECT 1 FROM ` + locationDefaultPartition + ` WHERE recorded_at >= $1 AND recorded_at < $2)`
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, from, to).Scan(&stranded); err != nil {
+		return "", fmt.Errorf("Failed to check default location partition: %w", err)
+	}
+
+	if !stranded {
+		create := `CREATE TABLE IF NOT EXISTS ` + pq.QuoteIdentifier(name) + ` PARTITION OF location_updates ` + bounds
+		if _, err := conn(ctx, r.db).ExecContext(ctx, create); err != nil {
+			return "", fmt.Errorf("Failed to create location partition %s: %w", name, err)
+		}
+		return name, nil
+	}
+
+	// points of the month in the default partition would make creating the
+	// partition fail, they are moved into it before it is attached
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		what  string
+		query string
+		args  []interface{}
+	}{
+		// the points only change partition, latest locations must not be refreshed
+		{"mark the move to", `SET LOCAL app.moving_locations = 'on'`, nil},
+		{"create", `CREATE TABLE ` + pq.QuoteIdentifier(name) + ` (LIKE location_updates INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, nil},
+		{"move points into", `
+			WITH moved AS (
+				DELETE FROM ` + locationDefaultPartition + `
+				WHERE recorded_at >= $1 AND recorded_at < $2
+				RETURNING *
+			)
+			INSERT INTO ` + pq.QuoteIdentifier(name) + ` SELECT * FROM moved`,
+			[]interface{}{from, to}},
+		{"attach", `ALTER TABLE location_updates ATTACH PARTITION ` + pq.QuoteIdentifier(name) + ` ` + bounds, nil},
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement.query, statement.args...); err != nil {
+			return "", fmt.Errorf("Failed to %s location partition %s: %w", statement.what, name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("Failed to create location partition %s: %w", name, err)
+	}
+
+	return name, nil
+}
+
+func (r *partitionRepository) DetachLocationPartition(ctx context.Context, name string) error {
+	if !strings.HasPrefix(name, locationPartitionPrefix) {
+		return fmt.Errorf("Failed to detach %s: not a monthly location partition", name)
+	}
+
+	var attached bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT relispartition FROM pg_class WHERE oid = to_regclass($1)`, name).Scan(&attached)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !attached) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to look up location partition %s: %w", name, err)
+	}
+
+	query := `ALTER TABLE location_updates DETACH PARTITION ` + pq.QuoteIdentifier(name)
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("Failed to detach location partition %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type pricingRepository struct {
+	db *sql.DB
+}
+
+func NewPricingRepository(db *sql.DB) repository.PricingRepository {
+	return &pricingRepository{db: db}
+}
+
+const rateCardColumns = `vehicle_type, currency, base_fare, per_km, per_minute, minimum_fare, created_at, updated_at`
+
+const surgeZoneColumns = `
+	id, name, vehicle_type, ST_Y(center::geometry), ST_X(center::geometry),
+	radius_meters, multiplier, starts_at, ends_at, created_at
+`
+
+func scanRateCard(row rowScanner) (*domain.RateCard, error) {
+	card := &domain.RateCard{}
+	err := row.Scan(
+		&card.VehicleType, &card.Currency, &card.BaseFare, &card.PerKm, &card.PerMinute,
+		&card.MinimumFare, &card.CreatedAt, &card.UpdatedAt,
+	)
+	return card, err
+}
+
+func scanSurgeZone(row rowScanner) (*domain.SurgeZone, error) {
+	zone := &domain.SurgeZone{}
+	var vehicleType sql.NullString
+
+	err := row.Scan(
+		&zone.ID, &zone.Name, &vehicleType, &zone.Center.Latitude, &zone.Center.Longitude,
+		&zone.RadiusMeters, &zone.Multiplier, &zone.StartsAt, &zone.EndsAt, &zone.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if vehicleType.Valid {
+		v := domain.VehicleType(vehicleType.String)
+		zone.VehicleType = &v
+	}
+
+	return zone, nil
+}
+
+func (r *pricingRepository) GetRateCard(ctx context.Context, vehicleType domain.VehicleType) (*domain.RateCard, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + rateCardColumns + ` FROM rate_cards WHERE organization_id = $1 AND vehicle_type = $2`
+
+	card, err := scanRateCard(conn(ctx, r.db).QueryRowContext(ctx, query, organizationID, vehicleType))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve %v rate card: %w", vehicleType, err)
+	}
+
+	return card, nil
+}
+
+func (r *pricingRepository) GetRateCardAt(ctx context.Context, vehicleType domain.VehicleType, at time.Time) (*domain.RateCard, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + rateCardColumns + `
+		FROM rate_card_history
+		WHERE organization_id = $1 AND vehicle_type = $2
+			AND valid_from <= $3 AND (valid_to IS NULL OR valid_to > $3)
+		ORDER BY valid_from DESC
+		LIMIT 1
+	`
+
+	card, err := scanRateCard(conn(ctx, r.db).QueryRowContext(ctx, query, organizationID, vehicleType, at))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve %v rate card at %v: %w", vehicleType, at, err)
+	}
+
+	return card, nil
+}
+
+func (r *pricingRepository) ListRateCards(ctx context.Context) (cards []*domain.RateCard, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + rateCardColumns + ` FROM rate_cards WHERE organization_id = $1 ORDER BY vehicle_type`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list rate cards: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		card, err := scanRateCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan rate card: %w", err)
+		}
+		cards = append(cards, card)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list rate cards: %w", err)
+	}
+
+	return cards, nil
+}
+
+func (r *pricingRepository) SaveRateCard(ctx context.Context, card *domain.RateCard) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO rate_cards
+			(organization_id, vehicle_type, currency, base_fare, per_km, per_minute, minimum_fare)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (organization_id, vehicle_type) DO UPDATE
+			SET currency = EXCLUDED.currency, base_fare = EXCLUDED.base_fare, per_km = EXCLUDED.per_km,
+				per_minute = EXCLUDED.per_minute, minimum_fare = EXCLUDED.minimum_fare
+		RETURNING created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		organizationID,
+		card.VehicleType,
+		card.Currency,
+		card.BaseFare,
+		card.PerKm,
+		card.PerMinute,
+		card.MinimumFare,
+	).Scan(&card.CreatedAt, &card.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to save %v rate card: %w", card.VehicleType, err)
+	}
+
+	return nil
+}
+
+func (r *pricingRepository) DeleteRateCard(ctx context.Context, vehicleType domain.VehicleType) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM rate_cards WHERE organization_id = $1 AND vehicle_type = $2`, organizationID, vehicleType)
+	if err != nil {
+		return fmt.Errorf("Failed to delete %v rate card: %w", vehicleType, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *pricingRepository) CreateSurgeZone(ctx context.Context, zone *domain.SurgeZone) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO surge_zones
+			(organization_id, name, vehicle_type, center, radius_meters, multiplier, starts_at, ends_at)
+		VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326), $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		organizationID,
+		zone.Name,
+		zone.VehicleType,
+		zone.Center.Longitude,
+		zone.Center.Latitude,
+		zone.RadiusMeters,
+		zone.Multiplier,
+		zone.StartsAt,
+		zone.EndsAt,
+	).Scan(&zone.ID, &zone.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create surge zone %v: %w", zone.Name, err)
+	}
+
+	return nil
+}
+
+func (r *pricingRepository) ListSurgeZones(ctx context.Context) ([]*domain.SurgeZone, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + surgeZoneColumns + ` FROM surge_zones WHERE organization_id = $1 AND deleted_at IS NULL ORDER BY created_at`
+
+	return r.querySurgeZones(ctx, query, organizationID)
+}
+
+func (r *pricingRepository) DeleteSurgeZone(ctx context.Context, zoneID string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE surge_zones SET deleted_at = NOW()
+		WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, zoneID, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete surge zone %v: %w", zoneID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *pricingRepository) FindSurgeZones(ctx context.Context, point domain.Coordinate, vehicleType domain.VehicleType, at time.Time) ([]*domain.SurgeZone, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + surgeZoneColumns + `
+		FROM surge_zones
+		WHERE organization_id = $1
+			AND (vehicle_type IS NULL OR vehicle_type = $4)
+			AND (starts_at IS NULL OR starts_at <= $5)
+			AND (ends_at IS NULL OR ends_at > $5)
+			AND (deleted_at IS NULL OR deleted_at > $5)
+			AND ST_DWithin(center, ST_SetSRID(ST_MakePoint($3, $2), 4326)::geography, radius_meters)
+		ORDER BY multiplier DESC
+	`
+
+	return r.querySurgeZones(ctx, query, organizationID, point.Latitude, point.Longitude, vehicleType, at)
+}
+
+func (r *pricingRepository) querySurgeZones(ctx context.Context, query string, args ...interface{}) (zones []*domain.SurgeZone, err error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve surge zones: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		zone, err := scanSurgeZone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan surge zone: %w", err)
+		}
+		zones = append(zones, zone)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve surge zones: %w", err)
+	}
+
+	return zones, nil
+}
+
+func (r *pricingRepository) SaveFare(ctx context.Context, fare *domain.Fare) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	lines, err := json.Marshal(fare.Lines)
+	if err != nil {
+		return fmt.Errorf("Failed to encode fare of session %v: %w", fare.SessionID, err)
+	}
+
+	query := `
+		INSERT INTO session_fares
+			(session_id, organization_id, delivery_id, vehicle_type, currency, distance_meters,
+			 duration_seconds, paused_seconds, surge_multiplier, surge_zone_id, lines, total, computed_at)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (organization_id, session_id) DO NOTHING
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		fare.SessionID,
+		organizationID,
+		fare.DeliveryID,
+		fare.VehicleType,
+		fare.Currency,
+		fare.DistanceMeters,
+		fare.DurationSeconds,
+		fare.PausedSeconds,
+		fare.SurgeMultiplier,
+		fare.SurgeZoneID,
+		lines,
+		fare.Total,
+		fare.ComputedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to save fare of session %v: %w", fare.SessionID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrConflict
+	}
+
+	return nil
+}
+
+func (r *pricingRepository) GetFare(ctx context.Context, sessionID string) (*domain.Fare, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT session_id, delivery_id, vehicle_type, currency, distance_meters, duration_seconds,
+			paused_seconds, surge_multiplier, surge_zone_id, lines, total, computed_at
+		FROM session_fares
+		WHERE session_id = $1 AND organization_id = $2
+	`
+
+	fare := &domain.Fare{Kind: domain.FareFinal}
+	var deliveryID sql.NullString
+	var lines []byte
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, sessionID, organizationID).Scan(
+		&fare.SessionID, &deliveryID, &fare.VehicleType, &fare.Currency, &fare.DistanceMeters,
+		&fare.DurationSeconds, &fare.PausedSeconds, &fare.SurgeMultiplier, &fare.SurgeZoneID,
+		&lines, &fare.Total, &fare.ComputedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve fare of session %v: %w", sessionID, err)
+	}
+
+	fare.DeliveryID = deliveryID.String
+	if err := json.Unmarshal(lines, &fare.Lines); err != nil {
+		return nil, fmt.Errorf("Failed to decode fare of session %v: %w", sessionID, err)
+	}
+
+	return fare, nil
+}
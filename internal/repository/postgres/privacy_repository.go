@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
+)
+
+type privacyRepository struct {
+	db *sql.DB
+}
+
+func NewPrivacyRepository(db *sql.DB) repository.PrivacyRepository {
+	return &privacyRepository{db: db}
+}
+
+func (r *privacyRepository) CreateRequest(ctx context.Context, request *domain.PrivacyRequest) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO privacy_requests
+			(organization_id, rider_id, kind, reason, source_ip, session_count, point_count)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+		RETURNING id, created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		organizationID,
+		request.RiderID,
+		request.Kind,
+		request.Reason,
+		request.SourceIP,
+		request.SessionCount,
+		request.PointCount,
+	).Scan(&request.ID, &request.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to record privacy request: %w", err)
+	}
+
+	return nil
+}
+
+func (r *privacyRepository) ListRequests(ctx context.Context, riderID string) (requests []*domain.PrivacyRequest, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, rider_id, kind, reason, source_ip, session_count, point_count, created_at
+		FROM privacy_requests
+		WHERE rider_id = $1 AND organization_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, riderID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list privacy requests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		request := &domain.PrivacyRequest{}
+		var reason, sourceIP sql.NullString
+
+		err := rows.Scan(
+			&request.ID, &request.RiderID, &request.Kind, &reason, &sourceIP,
+			&request.SessionCount, &request.PointCount, &request.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan privacy request: %w", err)
+		}
+
+		request.Reason = reason.String
+		request.SourceIP = sourceIP.String
+		requests = append(requests, request)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list privacy requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+func (r *privacyRepository) EraseSessionTraces(ctx context.Context, sessionIDs []string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	sessions := pq.Array(sessionIDs)
+
+	// location events are coordinates through and through and are dropped,
+	// any other event keeps its facts and loses the position it carries
+	type statement struct {
+		what  string
+		query string
+		args  []interface{}
+	}
+	statements := []statement{
+		{"latest locations", `
+			DELETE FROM session_latest_locations
+			WHERE organization_id = $1 AND session_id = ANY($2)`,
+			[]interface{}{organizationID, sessions}},
+		{"summary routes", `
+			UPDATE session_summaries SET route = NULL, erased_at = NOW()
+			WHERE organization_id = $1 AND session_id = ANY($2)`,
+			[]interface{}{organizationID, sessions}},
+		{"proof locations", `
+			UPDATE delivery_proofs SET location = NULL, location_accuracy = NULL, location_recorded_at = NULL
+			WHERE organization_id = $1 AND session_id = ANY($2)`,
+			[]interface{}{organizationID, sessions}},
+	}
+
+	// every copy of an event: the outbox, queued webhooks and both dead letter tables
+	for _, table := range []string{"outbox_events", "outbox_dead_letters", "webhook_deliveries", "webhook_dead_letters"} {
+		statements = append(statements,
+			statement{"location events in " + table, `
+				DELETE FROM ` + table + `
+				WHERE organization_id = $1 AND payload->'data'->>'sessionId' = ANY($2) AND event_type = $3`,
+				[]interface{}{organizationID, sessions, domain.EventLocationRecorded}},
+			statement{"event locations in " + table, `
+				UPDATE ` + table + ` SET payload = payload #- '{data,location}'
+				WHERE organization_id = $1 AND payload->'data'->>'sessionId' = ANY($2) AND payload->'data' ? 'location'`,
+				[]interface{}{organizationID, sessions}},
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, statement.query, statement.args...); err != nil {
+			return fmt.Errorf("Failed to erase %s: %w", statement.what, err)
+		}
+	}
+
+	return nil
+}
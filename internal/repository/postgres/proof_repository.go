@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type proofRepository struct {
+	db *sql.DB
+}
+
+func NewProofRepository(db *sql.DB) repository.ProofRepository {
+	return &proofRepository{db: db}
+}
+
+const proofColumns = `
+	id, delivery_id, session_id, kind, content_type, size_bytes, sha256, blob_key,
+	ST_Y(location::geometry), ST_X(location::geometry), location_accuracy, location_recorded_at,
+	captured_at
+`
+
+func (r *proofRepository) Create(ctx context.Context, proof *domain.DeliveryProof) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	var latitude, longitude *float64
+	if proof.Location != nil {
+		latitude, longitude = &proof.Location.Latitude, &proof.Location.Longitude
+	}
+
+	query := `
+		INSERT INTO delivery_proofs
+			(organization_id, delivery_id, session_id, kind, content_type, size_bytes, sha256, blob_key,
+			 location, location_accuracy, location_recorded_at, captured_at)
+		VALUES (
+			$1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0), NULLIF($7, ''), NULLIF($8, ''),
+			CASE WHEN $9::float8 IS NULL THEN NULL ELSE ST_SetSRID(ST_MakePoint($10, $9), 4326) END,
+			$11, $12, $13
+		)
+		RETURNING id
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		organizationID,
+		proof.DeliveryID,
+		proof.SessionID,
+		proof.Kind,
+		proof.ContentType,
+		proof.SizeBytes,
+		proof.SHA256,
+		proof.BlobKey,
+		latitude,
+		longitude,
+		proof.LocationAccuracy,
+		proof.LocationRecordedAt,
+		proof.CapturedAt,
+	).Scan(&proof.ID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to save %v proof of delivery %v: %w", proof.Kind, proof.DeliveryID, err)
+	}
+
+	return nil
+}
+
+func (r *proofRepository) ListByDeliveryID(ctx context.Context, deliveryID string) (proofs []*domain.DeliveryProof, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + proofColumns + `
+		FROM delivery_proofs
+		WHERE delivery_id = $1 AND organization_id = $2
+		ORDER BY captured_at
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, deliveryID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve proofs of delivery %v: %w", deliveryID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		proof, err := scanProof(rows)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, proof)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve proofs of delivery %v: %w", deliveryID, err)
+	}
+
+	return proofs, nil
+}
+
+func (r *proofRepository) GetByID(ctx context.Context, deliveryID, proofID string) (*domain.DeliveryProof, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + proofColumns + `
+		FROM delivery_proofs
+		WHERE id = $1 AND delivery_id = $2 AND organization_id = $3
+	`
+
+	proof, err := scanProof(conn(ctx, r.db).QueryRowContext(ctx, query, proofID, deliveryID, organizationID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	return proof, err
+}
+
+func (r *proofRepository) SavePIN(ctx context.Context, pin *domain.DeliveryPIN) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO delivery_pins (delivery_id, organization_id, pin_hash, expires_at)
+		SELECT id, organization_id, $3, $4
+		FROM deliveries
+		WHERE id = $1 AND organization_id = $2
+		ON CONFLICT (delivery_id) DO UPDATE
+			SET pin_hash = EXCLUDED.pin_hash, expires_at = EXCLUDED.expires_at,
+				attempts = 0, verified_at = NULL, created_at = NOW()
+		RETURNING created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, pin.DeliveryID, organizationID, pin.PINHash, pin.ExpiresAt).Scan(&pin.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to issue PIN for delivery %v: %w", pin.DeliveryID, err)
+	}
+
+	return nil
+}
+
+func (r *proofRepository) GetPINForUpdate(ctx context.Context, deliveryID string) (*domain.DeliveryPIN, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT pin_hash, attempts, expires_at, verified_at, created_at
+		FROM delivery_pins
+		WHERE delivery_id = $1 AND organization_id = $2
+		FOR UPDATE
+	`
+
+	pin := &domain.DeliveryPIN{DeliveryID: deliveryID}
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, deliveryID, organizationID).Scan(&pin.PINHash, &pin.Attempts, &pin.ExpiresAt, &pin.VerifiedAt, &pin.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve PIN of delivery %v: %w", deliveryID, err)
+	}
+
+	return pin, nil
+}
+
+func (r *proofRepository) UpdatePIN(ctx context.Context, pin *domain.DeliveryPIN) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE delivery_pins
+			SET attempts = $3, verified_at = $4
+		WHERE delivery_id = $1 AND organization_id = $2
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, pin.DeliveryID, organizationID, pin.Attempts, pin.VerifiedAt)
+	if err != nil {
+		return fmt.Errorf("Failed to update PIN of delivery %v: %w", pin.DeliveryID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanProof(row rowScanner) (*domain.DeliveryProof, error) {
+	proof := &domain.DeliveryProof{}
+	var contentType, sha, blobKey sql.NullString
+	var size sql.NullInt64
+	var latitude, longitude sql.NullFloat64
+
+	err := row.Scan(
+		&proof.ID, &proof.DeliveryID, &proof.SessionID, &proof.Kind,
+		&contentType, &size, &sha, &blobKey,
+		&latitude, &longitude, &proof.LocationAccuracy, &proof.LocationRecordedAt,
+		&proof.CapturedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to Scan proof of delivery: %w", err)
+	}
+
+	proof.ContentType = contentType.String
+	proof.SizeBytes = size.Int64
+	proof.SHA256 = sha.String
+	proof.BlobKey = blobKey.String
+	if latitude.Valid && longitude.Valid {
+		proof.Location = &domain.Coordinate{Latitude: latitude.Float64, Longitude: longitude.Float64}
+	}
+
+	return proof, nil
+}
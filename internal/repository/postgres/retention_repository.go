@@ -0,0 +1,283 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type retentionRepository struct {
+	db *sql.DB
+}
+
+func NewRetentionRepository(db *sql.DB) repository.RetentionRepository {
+	return &retentionRepository{db: db}
+}
+
+// lineString is the GeoJSON geometry a summary route is stored and read as
+type lineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+func (r *retentionRepository) GetPolicy(ctx context.Context) (*domain.RetentionPolicy, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &domain.RetentionPolicy{}
+
+	query := `
+		SELECT organization_id, location_retention_days, created_at, updated_at
+		FROM retention_policies
+		WHERE organization_id = $1
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, organizationID).Scan(
+		&policy.OrganizationID, &policy.LocationRetentionDays, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve retention policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+func (r *retentionRepository) SetPolicy(ctx context.Context, policy *domain.RetentionPolicy) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO retention_policies (organization_id, location_retention_days)
+		VALUES ($1, $2)
+		ON CONFLICT (organization_id) DO UPDATE SET location_retention_days = EXCLUDED.location_retention_days
+		RETURNING organization_id, created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, organizationID, policy.LocationRetentionDays).Scan(
+		&policy.OrganizationID, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to save retention policy: %w", err)
+	}
+
+	return nil
+}
+
+func (r *retentionRepository) DeletePolicy(ctx context.Context) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM retention_policies WHERE organization_id = $1`, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete retention policy: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *retentionRepository) ListPolicies(ctx context.Context) (policies []*domain.RetentionPolicy, err error) {
+	query := `
+		SELECT organization_id, location_retention_days, created_at, updated_at
+		FROM retention_policies
+		ORDER BY organization_id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		policy := &domain.RetentionPolicy{}
+		if err := rows.Scan(&policy.OrganizationID, &policy.LocationRetentionDays, &policy.CreatedAt, &policy.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("Failed to Scan retention policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list retention policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (r *retentionRepository) ListExpiredSessions(ctx context.Context, cutoff time.Time, limit int) (sessions []*domain.TrackingSession, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ts.session_id, ts.delivery_id, ts.rider_id, ts.start_time, ts.end_time, ts.is_active, ts.version
+		FROM tracking_sessions ts
+		JOIN (
+			SELECT session_id, MAX(recorded_at) AS last_recorded_at
+			FROM location_updates
+			WHERE organization_id = $1
+			GROUP BY session_id
+		) lu ON lu.session_id = ts.session_id
+		WHERE ts.organization_id = $1 AND ts.is_active = false AND lu.last_recorded_at < $2
+		ORDER BY lu.last_recorded_at
+		LIMIT $3
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, organizationID, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list expired sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := &domain.TrackingSession{}
+		var deliveryID sql.NullString
+
+		if err := rows.Scan(&session.SessionID, &deliveryID, &session.RiderID, &session.StartTime, &session.EndTime, &session.IsActive, &session.Version); err != nil {
+			return nil, fmt.Errorf("Failed to Scan expired session: %w", err)
+		}
+
+		session.DeliveryID = deliveryID.String
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to list expired sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (r *retentionRepository) SaveSummary(ctx context.Context, summary *domain.SessionSummary) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	route := lineString{Type: "LineString"}
+	for _, c := range summary.Route {
+		route.Coordinates = append(route.Coordinates, [2]float64{c.Longitude, c.Latitude})
+	}
+	// a line needs two points, a session that never moved repeats its only one
+	if len(route.Coordinates) == 1 {
+		route.Coordinates = append(route.Coordinates, route.Coordinates[0])
+	}
+
+	// an erased session keeps no route
+	var geometry sql.NullString
+	if len(route.Coordinates) > 0 {
+		encoded, err := json.Marshal(route)
+		if err != nil {
+			return fmt.Errorf("Failed to encode summary route: %w", err)
+		}
+		geometry = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	// a session archived again, after points arrived late, gets an updated summary
+	query := `
+		INSERT INTO session_summaries
+			(session_id, organization_id, point_count, distance_meters, first_recorded_at, last_recorded_at, route, archive_key)
+		SELECT
+			ts.session_id, ts.organization_id, $3, $4, $5, $6, ST_SetSRID(ST_GeomFromGeoJSON($7), 4326)::geography, NULLIF($8, '')
+		FROM tracking_sessions ts
+		WHERE ts.session_id = $1 AND ts.organization_id = $2
+		ON CONFLICT (organization_id, session_id) DO UPDATE SET
+			point_count = EXCLUDED.point_count,
+			distance_meters = EXCLUDED.distance_meters,
+			first_recorded_at = EXCLUDED.first_recorded_at,
+			last_recorded_at = EXCLUDED.last_recorded_at,
+			route = EXCLUDED.route,
+			archive_key = EXCLUDED.archive_key,
+			archived_at = NOW()
+		RETURNING archived_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		summary.SessionID,
+		organizationID,
+		summary.PointCount,
+		summary.DistanceMeters,
+		summary.FirstRecordedAt,
+		summary.LastRecordedAt,
+		geometry,
+		summary.ArchiveKey,
+	).Scan(&summary.ArchivedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to save summary of session %v: %w", summary.SessionID, err)
+	}
+
+	return nil
+}
+
+func (r *retentionRepository) GetSummary(ctx context.Context, sessionID string) (*domain.SessionSummary, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &domain.SessionSummary{}
+	var geometry, archiveKey sql.NullString
+
+	query := `
+		SELECT
+			session_id, point_count, distance_meters, first_recorded_at, last_recorded_at,
+			ST_AsGeoJSON(route), archive_key, archived_at, erased_at
+		FROM session_summaries
+		WHERE session_id = $1 AND organization_id = $2
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, sessionID, organizationID).Scan(
+		&summary.SessionID, &summary.PointCount, &summary.DistanceMeters, &summary.FirstRecordedAt, &summary.LastRecordedAt,
+		&geometry, &archiveKey, &summary.ArchivedAt, &summary.ErasedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve summary of session %v: %w", sessionID, err)
+	}
+
+	summary.ArchiveKey = archiveKey.String
+
+	var route lineString
+	if geometry.Valid {
+		if err := json.Unmarshal([]byte(geometry.String), &route); err != nil {
+			return nil, fmt.Errorf("Failed to decode summary route: %w", err)
+		}
+	}
+
+	summary.Route = make([]domain.Coordinate, 0, len(route.Coordinates))
+	for _, c := range route.Coordinates {
+		summary.Route = append(summary.Route, domain.Coordinate{Latitude: c[1], Longitude: c[0]})
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// riderMessagesChannel carries messages for riders to the instance holding
+// their connection
+const riderMessagesChannel = "rider_messages"
+
+type riderMessage struct {
+	OrganizationID string          `json:"organizationId"`
+	SessionID      string          `json:"sessionId"`
+	Type           string          `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// RiderChannel sends messages to riders' sessions over NOTIFY, so the server
+// can reach a rider connected to any instance
+type RiderChannel struct {
+	db  *sql.DB
+	dsn string
+}
+
+func NewRiderChannel(db *sql.DB, dsn string) *RiderChannel {
+	return &RiderChannel{db: db, dsn: dsn}
+}
+
+// Send notifies every instance of a message for the session
+func (c *RiderChannel) Send(ctx context.Context, organizationID, sessionID, msgType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v message: %w", msgType, err)
+	}
+
+	message, err := json.Marshal(riderMessage{OrganizationID: organizationID, SessionID: sessionID, Type: msgType, Payload: data})
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v message: %w", msgType, err)
+	}
+	if len(message) > notifyPayloadLimit {
+		return fmt.Errorf("%v message of %d bytes is too large to send", msgType, len(message))
+	}
+
+	if _, err := c.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, riderMessagesChannel, string(message)); err != nil {
+		return fmt.Errorf("Failed to send %v message to session %v: %w", msgType, sessionID, err)
+	}
+
+	return nil
+}
+
+// Listen calls received for every message sent until ctx is cancelled.
+// Messages sent while the connection is down are lost.
+func (c *RiderChannel) Listen(ctx context.Context, received func(organizationID, sessionID, msgType string, payload json.RawMessage)) error {
+	return listen(ctx, c.dsn, riderMessagesChannel, func(payload string) {
+		var message riderMessage
+		if err := json.Unmarshal([]byte(payload), &message); err != nil {
+			log.Printf("[RIDERS] malformed message: %v", err)
+			return
+		}
+		received(message.OrganizationID, message.SessionID, message.Type, message.Payload)
+	}, func() {
+		log.Printf("[RIDERS] reconnected, messages sent meanwhile were lost")
+	})
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type riderRepository struct {
+	db *sql.DB
+}
+
+func NewRiderRepository(db *sql.DB) repository.RiderRepository {
+	return &riderRepository{db: db}
+}
+
+func (r *riderRepository) Create(ctx context.Context, rider *domain.Rider) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO riders
+			(name, phone, email, vehicle_type, vehicle_plate, availability, organization_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		rider.Name,
+		rider.Phone,
+		rider.Email,
+		rider.VehicleType,
+		rider.VehiclePlate,
+		rider.Availability,
+		organizationID,
+	).Scan(&rider.ID, &rider.CreatedAt, &rider.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create rider: %w", err)
+	}
+
+	return nil
+}
+
+func (r *riderRepository) GetByID(ctx context.Context, riderID string) (*domain.Rider, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rider := &domain.Rider{}
+	var email, plate sql.NullString
+
+	query := `
+		SELECT
+			id, name, phone, email, vehicle_type, vehicle_plate, availability, shift_start, shift_end, created_at, updated_at, erased_at
+		FROM riders
+		WHERE id = $1 AND organization_id = $2;
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, riderID, organizationID).Scan(
+		&rider.ID, &rider.Name, &rider.Phone, &email, &rider.VehicleType, &plate,
+		&rider.Availability, &rider.ShiftStart, &rider.ShiftEnd, &rider.CreatedAt, &rider.UpdatedAt, &rider.ErasedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve rider %v: %w", riderID, err)
+	}
+
+	rider.Email = email.String
+	rider.VehiclePlate = plate.String
+
+	return rider, nil
+}
+
+func (r *riderRepository) Update(ctx context.Context, rider *domain.Rider) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE riders
+			SET name = $2, phone = $3, email = NULLIF($4, ''), vehicle_type = $5, vehicle_plate = NULLIF($6, ''),
+				availability = $7, shift_start = $8, shift_end = $9
+		WHERE id = $1 AND organization_id = $10
+		RETURNING updated_at;
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		rider.ID,
+		rider.Name,
+		rider.Phone,
+		rider.Email,
+		rider.VehicleType,
+		rider.VehiclePlate,
+		rider.Availability,
+		rider.ShiftStart,
+		rider.ShiftEnd,
+		organizationID,
+	).Scan(&rider.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to update rider %v: %w", rider.ID, err)
+	}
+
+	return nil
+}
+
+func (r *riderRepository) Anonymize(ctx context.Context, riderID string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the phone stays unique per organization, the id without dashes fits the column
+	query := `
+		UPDATE riders
+			SET name = 'Erased rider', phone = replace(id::text, '-', ''), email = NULL, vehicle_plate = NULL,
+				availability = 'offline', shift_start = NULL, shift_end = NULL, erased_at = NOW()
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, riderID, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to anonymize rider %v: %w", riderID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
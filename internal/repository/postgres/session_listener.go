@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+)
+
+// sessionChangesChannel is notified whenever a tracking session is updated or
+// deleted, see migration 000023
+const sessionChangesChannel = "tracking_session_changes"
+
+// SessionChangeListener follows session changes made by any instance over a
+// dedicated LISTEN connection
+type SessionChangeListener struct {
+	dsn string
+}
+
+func NewSessionChangeListener(dsn string) *SessionChangeListener {
+	return &SessionChangeListener{dsn: dsn}
+}
+
+// Listen calls changed for every changed session until ctx is cancelled. The
+// connection is re-established when it drops; notifications sent meanwhile are
+// lost, so missed is called once it is back.
+func (l *SessionChangeListener) Listen(ctx context.Context, changed func(organizationID, sessionID string), missed func()) error {
+	return listen(ctx, l.dsn, sessionChangesChannel, func(payload string) {
+		if organizationID, sessionID, ok := strings.Cut(payload, "/"); ok {
+			changed(organizationID, sessionID)
+		}
+	}, missed)
+}
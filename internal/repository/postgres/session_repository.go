@@ -3,10 +3,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/SarkiMudboy/easebox-api/internal/domain"
 	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
 )
 
 type SessionRepository struct {
@@ -18,23 +22,36 @@ func NewSessionRepository(db *sql.DB) repository.SessionRepository {
 }
 
 func (r *SessionRepository) Create(ctx context.Context, session *domain.TrackingSession) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
 
 	query := `
 		INSERT INTO tracking_sessions
-			(session_id, delivery_id, start_time, is_active)
-		VALUES ($1, $2, $3, $4);
+			(organization_id, session_id, delivery_id, rider_id, start_time, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING version;
 	`
 
-	_, err := r.db.ExecContext(ctx, query, session.SessionID, session.DeliveryID, session.StartTime, session.IsActive)
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, organizationID, session.SessionID, session.DeliveryID, session.RiderID, session.StartTime, session.IsActive).Scan(&session.Version)
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation && pqErr.Constraint == "fk_tracking_sessions_rider_organization" {
+		return repository.ErrNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("Failed to create session for %v: %v", session.SessionID, err)
+		return fmt.Errorf("Failed to create session for %v: %w", session.SessionID, err)
 	}
 
 	return nil
 }
 
 func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (session *domain.TrackingSession, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	session = &domain.TrackingSession{
 		SessionID: sessionID,
@@ -42,27 +59,273 @@ func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (sess
 
 	query := `
 		SELECT 
-			delivery_id, start_time, end_time, is_active
+			delivery_id, rider_id, start_time, end_time, is_active, paused_at, version
 		FROM tracking_sessions 
-		WHERE session_id = $1;
+		WHERE session_id = $1 AND organization_id = $2;
 	`
 
-	err = r.db.QueryRowContext(ctx, query, sessionID).Scan(&session.DeliveryID, &session.StartTime, &session.EndTime, &session.IsActive)
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, sessionID, organizationID).Scan(&session.DeliveryID, &session.RiderID, &session.StartTime, &session.EndTime, &session.IsActive, &session.PausedAt, &session.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
 
 	return
 }
 
 func (r *SessionRepository) Update(ctx context.Context, session *domain.TrackingSession) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE tracking_sessions 
-			SET delivery_id = $2, start_time = $3, end_time = $4, is_active = $5
-		WHERE session_id = $1;
+			SET delivery_id = $2, rider_id = $3, start_time = $4, end_time = $5, is_active = $6, paused_at = $9, version = version + 1
+		WHERE session_id = $1 AND organization_id = $7 AND version = $8
+		RETURNING version;
 	`
 
-	_, err := r.db.ExecContext(ctx, query, session.SessionID, session.DeliveryID, session.StartTime, session.EndTime, session.IsActive)
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, session.SessionID, session.DeliveryID, session.RiderID, session.StartTime, session.EndTime, session.IsActive, organizationID, session.Version, session.PausedAt).Scan(&session.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		// either the session is gone or someone else updated it first
+		var exists bool
+		err = conn(ctx, r.db).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tracking_sessions WHERE session_id = $1 AND organization_id = $2)`, session.SessionID, organizationID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("Failed to update tracking session %v: %v", session.SessionID, err)
+		}
+		if !exists {
+			return repository.ErrNotFound
+		}
+		return repository.ErrConflict
+	}
 	if err != nil {
 		return fmt.Errorf("Failed to update tracking session %v: %v", session.SessionID, err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (r *SessionRepository) ListActive(ctx context.Context) (sessions []*domain.FleetSession, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			s.session_id, s.rider_id, s.start_time,
+			d.id, d.status,
+			l.location_id, l.delivery_id,
+			ST_Y(l.location::geometry), ST_X(l.location::geometry),
+			l.accuracy, l.speed, l.heading, l.recorded_at, l.created_at
+		FROM tracking_sessions s
+		LEFT JOIN LATERAL (
+			SELECT id, status FROM deliveries
+			WHERE organization_id = s.organization_id AND assigned_session_id = s.session_id
+				AND status NOT IN ('delivered', 'cancelled')
+			ORDER BY updated_at DESC
+			LIMIT 1
+		) d ON true
+		LEFT JOIN session_latest_locations l ON l.organization_id = s.organization_id AND l.session_id = s.session_id
+		WHERE s.organization_id = $1 AND s.is_active = true
+		ORDER BY s.start_time;
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve active sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := &domain.FleetSession{}
+
+		var deliveryStatus sql.NullString
+		var locationID sql.NullInt64
+		var locationDeliveryID sql.NullString
+		var latitude, longitude, accuracy sql.NullFloat64
+		var recordedAt, createdAt sql.NullTime
+		location := &domain.LocationUpdate{}
+
+		err := rows.Scan(
+			&session.SessionID, &session.RiderID, &session.StartTime,
+			&session.DeliveryID, &deliveryStatus,
+			&locationID, &locationDeliveryID, &latitude, &longitude,
+			&accuracy, &location.Speed, &location.Heading, &recordedAt, &createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan active session: %w", err)
+		}
+
+		if deliveryStatus.Valid {
+			status := domain.DeliveryStatus(deliveryStatus.String)
+			session.DeliveryStatus = &status
+		}
+
+		if locationID.Valid {
+			location.ID = locationID.Int64
+			location.SessionID = session.SessionID
+			location.DeliveryID = locationDeliveryID.String
+			location.Latitude = latitude.Float64
+			location.Longitude = longitude.Float64
+			location.Accuracy = accuracy.Float64
+			location.RecordedAt = recordedAt.Time
+			location.CreatedAt = createdAt.Time
+			session.Location = location
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate active sessions: %w", err)
+	}
+
+	return
+}
+
+func (r *SessionRepository) ListByRiderID(ctx context.Context, riderID string) (sessions []*domain.TrackingSession, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT session_id, delivery_id, rider_id, start_time, end_time, is_active, paused_at, version
+		FROM tracking_sessions
+		WHERE rider_id = $1 AND organization_id = $2
+		ORDER BY start_time
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, riderID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve sessions of rider %v: %w", riderID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := &domain.TrackingSession{}
+		var deliveryID sql.NullString
+
+		if err := rows.Scan(&session.SessionID, &deliveryID, &session.RiderID, &session.StartTime, &session.EndTime, &session.IsActive, &session.PausedAt, &session.Version); err != nil {
+			return nil, fmt.Errorf("Failed to Scan session: %w", err)
+		}
+
+		session.DeliveryID = deliveryID.String
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve sessions of rider %v: %w", riderID, err)
+	}
+
+	return sessions, nil
+}
+
+func (r *SessionRepository) OpenPause(ctx context.Context, sessionID string, startedAt time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO session_pauses (session_id, organization_id, started_at)
+		VALUES ($1, $2, $3)
+	`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, sessionID, organizationID, startedAt); err != nil {
+		return fmt.Errorf("Failed to pause session %v: %w", sessionID, err)
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) ClosePause(ctx context.Context, sessionID string, endedAt time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE session_pauses
+			SET ended_at = GREATEST($3, started_at)
+		WHERE session_id = $1 AND organization_id = $2 AND ended_at IS NULL
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, sessionID, organizationID, endedAt)
+	if err != nil {
+		return fmt.Errorf("Failed to resume session %v: %w", sessionID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) ListPauses(ctx context.Context, sessionID string) (pauses []*domain.SessionPause, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT started_at, ended_at
+		FROM session_pauses
+		WHERE session_id = $1 AND organization_id = $2
+		ORDER BY started_at
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, sessionID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve pauses of session %v: %w", sessionID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pause := &domain.SessionPause{}
+		if err := rows.Scan(&pause.StartedAt, &pause.EndedAt); err != nil {
+			return nil, fmt.Errorf("Failed to Scan session pause: %w", err)
+		}
+		pauses = append(pauses, pause)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve pauses of session %v: %w", sessionID, err)
+	}
+
+	return pauses, nil
+}
+
+func (r *SessionRepository) ListStale(ctx context.Context, idleSince time.Time, limit int) (sessions []*domain.StaleSession, err error) {
+	query := `
+		SELECT s.organization_id, s.session_id, COALESCE(l.recorded_at, s.start_time) AS last_seen_at
+		FROM tracking_sessions s
+		LEFT JOIN session_latest_locations l ON l.organization_id = s.organization_id AND l.session_id = s.session_id
+		WHERE s.is_active = true AND s.paused_at IS NULL
+			AND COALESCE(l.recorded_at, s.start_time) < $1
+		ORDER BY last_seen_at
+		LIMIT $2
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, idleSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve stale sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := &domain.StaleSession{}
+		if err := rows.Scan(&session.OrganizationID, &session.SessionID, &session.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("Failed to Scan stale session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve stale sessions: %w", err)
+	}
+
+	return sessions, nil
+}
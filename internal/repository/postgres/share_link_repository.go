@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+type shareLinkRepository struct {
+	db *sql.DB
+}
+
+func NewShareLinkRepository(db *sql.DB) repository.ShareLinkRepository {
+	return &shareLinkRepository{db: db}
+}
+
+const shareLinkColumns = `id, organization_id, delivery_id, token_hash, expires_at, revoked_at, created_at`
+
+func scanShareLink(row rowScanner) (*domain.ShareLink, error) {
+	link := &domain.ShareLink{}
+	err := row.Scan(&link.ID, &link.OrganizationID, &link.DeliveryID, &link.TokenHash, &link.ExpiresAt, &link.RevokedAt, &link.CreatedAt)
+	return link, err
+}
+
+func (r *shareLinkRepository) Create(ctx context.Context, link *domain.ShareLink) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the delivery must belong to the same organization
+	query := `
+		INSERT INTO share_links
+			(organization_id, delivery_id, token_hash, expires_at)
+		SELECT organization_id, id, $3, $4
+		FROM deliveries
+		WHERE id = $1 AND organization_id = $2
+		RETURNING id, organization_id, created_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, link.DeliveryID, organizationID, link.TokenHash, link.ExpiresAt).Scan(
+		&link.ID, &link.OrganizationID, &link.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to create share link: %w", err)
+	}
+
+	return nil
+}
+
+func (r *shareLinkRepository) ListByDeliveryID(ctx context.Context, deliveryID string) (links []*domain.ShareLink, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE delivery_id = $1 AND organization_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, deliveryID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve share links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate share links: %w", err)
+	}
+
+	return
+}
+
+func (r *shareLinkRepository) Revoke(ctx context.Context, deliveryID, linkID string, revokedAt time.Time) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE share_links
+			SET revoked_at = $4
+		WHERE id = $1 AND delivery_id = $2 AND organization_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, linkID, deliveryID, organizationID, revokedAt)
+	if err != nil {
+		return fmt.Errorf("Failed to revoke share link %v: %w", linkID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *shareLinkRepository) GetByTokenHash(ctx context.Context, tokenHash []byte) (*domain.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE token_hash = $1
+	`
+
+	link, err := scanShareLink(conn(ctx, r.db).QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve share link: %w", err)
+	}
+
+	return link, nil
+}
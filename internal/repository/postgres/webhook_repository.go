@@ -0,0 +1,365 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/lib/pq"
+)
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) repository.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_endpoints
+			(organization_id, url, secret, event_types, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, organization_id, created_at, updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, organizationID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.EventTypes), endpoint.Active).Scan(
+		&endpoint.ID, &endpoint.OrganizationID, &endpoint.CreatedAt, &endpoint.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to create webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) ListEndpoints(ctx context.Context) (endpoints []*domain.WebhookEndpoint, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, organization_id, url, secret, event_types, active, created_at, updated_at
+		FROM webhook_endpoints
+		WHERE organization_id = $1 AND active = true
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		endpoint := &domain.WebhookEndpoint{}
+		err = rows.Scan(
+			&endpoint.ID, &endpoint.OrganizationID, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.EventTypes),
+			&endpoint.Active, &endpoint.CreatedAt, &endpoint.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate webhook endpoints: %w", err)
+	}
+
+	return
+}
+
+// DeleteEndpoint deactivates the endpoint, keeping its delivery history
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, endpointID string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE webhook_endpoints
+			SET active = false
+		WHERE id = $1 AND organization_id = $2 AND active = true
+	`
+
+	result, err := r.db.ExecContext(ctx, query, endpointID, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to delete webhook endpoint %v: %w", endpointID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) Enqueue(ctx context.Context, event *domain.Event, payload []byte) error {
+	query := `
+		INSERT INTO webhook_deliveries
+			(endpoint_id, organization_id, event_id, event_type, payload)
+		SELECT
+			e.id, e.organization_id, $2, $3, $4
+		FROM webhook_endpoints e
+		WHERE e.organization_id = $1 AND e.active = true
+			AND ((cardinality(e.event_types) = 0 AND NOT $5) OR $3 = ANY(e.event_types))
+		ON CONFLICT (event_id, endpoint_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, event.OrganizationID, event.ID, event.Type, payload, domain.OptInEvent(event.Type))
+	if err != nil {
+		return fmt.Errorf("Failed to enqueue webhook for event %v: %w", event.ID, err)
+	}
+
+	return nil
+}
+
+const webhookDeliveryColumns = `d.id, d.endpoint_id, d.organization_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_error, d.created_at`
+
+func scanWebhookDelivery(row rowScanner, extra ...interface{}) (*domain.WebhookDelivery, error) {
+	delivery := &domain.WebhookDelivery{}
+	dest := []interface{}{
+		&delivery.ID, &delivery.EndpointID, &delivery.OrganizationID, &delivery.EventID, &delivery.EventType, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt,
+	}
+	return delivery, row.Scan(append(dest, extra...)...)
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, endpointID string, limit int) (deliveries []*domain.WebhookDelivery, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.endpoint_id = $1 AND d.organization_id = $2
+		ORDER BY d.created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, endpointID, organizationID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate webhook deliveries: %w", err)
+	}
+
+	return
+}
+
+func (r *webhookRepository) ListAttempts(ctx context.Context, deliveryID string) (attempts []*domain.WebhookAttempt, err error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT a.delivery_id, a.attempt, a.status_code, a.error, a.duration_ms, a.attempted_at
+		FROM webhook_attempts a
+		JOIN webhook_deliveries d ON d.id = a.delivery_id
+		WHERE a.delivery_id = $1 AND d.organization_id = $2
+		ORDER BY a.attempt ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, deliveryID, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attempt := &domain.WebhookAttempt{}
+		err = rows.Scan(&attempt.DeliveryID, &attempt.Attempt, &attempt.StatusCode, &attempt.Error, &attempt.Duration, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan webhook attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate webhook attempts: %w", err)
+	}
+
+	return
+}
+
+func (r *webhookRepository) Retry(ctx context.Context, deliveryID string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries
+			SET status = 'pending', next_attempt_at = NOW()
+		WHERE id = $1 AND organization_id = $2 AND status = 'dead'
+	`
+
+	result, err := tx.ExecContext(ctx, query, deliveryID, organizationID)
+	if err != nil {
+		return fmt.Errorf("Failed to retry webhook delivery %v: %w", deliveryID, err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return repository.ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_dead_letters WHERE delivery_id = $1`, deliveryID); err != nil {
+		return fmt.Errorf("Failed to remove dead letter %v: %w", deliveryID, err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *webhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) (deliveries []*domain.WebhookDelivery, err error) {
+	query := `
+		UPDATE webhook_deliveries d
+			SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM webhook_endpoints e
+		WHERE e.id = d.endpoint_id AND d.id IN (
+			-- deliveries of deleted endpoints stay queued but are never sent
+			SELECT pd.id FROM webhook_deliveries pd
+			JOIN webhook_endpoints pe ON pe.id = pd.endpoint_id AND pe.active = true
+			WHERE pd.status = 'pending' AND pd.next_attempt_at <= NOW()
+			ORDER BY pd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF pd SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns + `, e.url, e.secret
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanWebhookDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to Scan webhook delivery: %w", err)
+		}
+		delivery.URL = url
+		delivery.Secret = secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to iterate webhook deliveries: %w", err)
+	}
+
+	return
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, attempt *domain.WebhookAttempt) error {
+	query := `
+		INSERT INTO webhook_attempts
+			(delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, attempt.DeliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.Duration, attempt.AttemptedAt)
+	if err != nil {
+		return fmt.Errorf("Failed to record webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) MarkSucceeded(ctx context.Context, deliveryID string) error {
+	query := `
+		UPDATE webhook_deliveries
+			SET status = 'succeeded', attempts = attempts + 1, last_error = NULL
+		WHERE id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, deliveryID); err != nil {
+		return fmt.Errorf("Failed to mark webhook delivery %v succeeded: %w", deliveryID, err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) ScheduleRetry(ctx context.Context, deliveryID string, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries
+			SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE id = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, deliveryID, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("Failed to schedule webhook retry %v: %w", deliveryID, err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries
+			SET status = 'dead', attempts = attempts + 1, last_error = $2
+		WHERE id = $1
+		RETURNING endpoint_id, organization_id, event_type, payload
+	`
+
+	var endpointID, organizationID, eventType string
+	var payload []byte
+	err = tx.QueryRowContext(ctx, query, deliveryID, lastError).Scan(&endpointID, &organizationID, &eventType, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to mark webhook delivery %v dead: %w", deliveryID, err)
+	}
+
+	insert := `
+		INSERT INTO webhook_dead_letters
+			(delivery_id, endpoint_id, organization_id, event_type, payload, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (delivery_id) DO UPDATE SET last_error = EXCLUDED.last_error, failed_at = NOW()
+	`
+
+	if _, err := tx.ExecContext(ctx, insert, deliveryID, endpointID, organizationID, eventType, payload, lastError); err != nil {
+		return fmt.Errorf("Failed to write dead letter %v: %w", deliveryID, err)
+	}
+
+	return tx.Commit()
+}
@@ -2,23 +2,282 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/SarkiMudboy/easebox-api/internal/domain"
 )
 
+// ErrNotFound is returned by repositories when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
 
+// ErrConflict is returned by conditional updates when the record changed since it was read
+var ErrConflict = errors.New("record was modified concurrently")
+
+// ErrInvalidData is returned when the database refuses a record for its
+// content, retrying the same write cannot succeed
+var ErrInvalidData = errors.New("record rejected by the database")
 
 type LocationRepository interface {
 	Create(ctx context.Context, location *domain.LocationUpdate) error
+	// CreateBatch inserts many locations in one statement. Locations whose
+	// session does not exist are skipped and keep a zero CreatedAt. A value
+	// the database refuses fails the whole batch with ErrInvalidData.
+	CreateBatch(ctx context.Context, locations []*domain.LocationUpdate) error
 	GetBySessionID(ctx context.Context, sessionID string) ([]*domain.LocationUpdate, error)
 	GetByDeliveryID(ctx context.Context, deliveryID string) ([]*domain.LocationUpdate, error)
 	GetLatestBySessionID(ctx context.Context, sessionID string) (*domain.LocationUpdate, error)
-	// GetWithinRadius(ctx context.Context, lat, long, radiusMeters float64) ([]*domain.LocationUpdate, error)
+	// GetWithinRadius returns the latest location of every active session within radiusMeters, nearest first
+	GetWithinRadius(ctx context.Context, lat, long, radiusMeters float64) ([]*domain.LocationUpdate, error)
+	// GetLatestBySessionIDs returns the latest location of each of the sessions that has one
+	GetLatestBySessionIDs(ctx context.Context, sessionIDs []string) ([]*domain.LocationUpdate, error)
+	// GetLatestActive returns the latest location of every active session
+	GetLatestActive(ctx context.Context) ([]*domain.LocationUpdate, error)
+	// GetLatestWithinBounds returns the latest location of every active session inside bounds
+	GetLatestWithinBounds(ctx context.Context, bounds domain.BoundingBox) ([]*domain.LocationUpdate, error)
+	// GetByRiderID returns the locations recorded by every session of a rider between from and to
+	GetByRiderID(ctx context.Context, riderID string, from, to time.Time) ([]*domain.LocationUpdate, error)
+	// DeleteBySessionID removes every point recorded for the session
+	DeleteBySessionID(ctx context.Context, sessionID string) (int64, error)
 }
 
 
 type SessionRepository interface {
+	// Create fails with ErrNotFound when the session's rider is not one of the
+	// organization's riders
 	Create(ctx context.Context, session *domain.TrackingSession) error
 	GetByID(ctx context.Context, sessionID string) (*domain.TrackingSession, error)
+	// Update saves the session if it is still at session.Version and bumps the
+	// version, otherwise it fails with ErrConflict
 	Update(ctx context.Context, session *domain.TrackingSession) error
-}
\ No newline at end of file
+	// ListActive returns the organization's active sessions with their open delivery and latest location
+	ListActive(ctx context.Context) ([]*domain.FleetSession, error)
+	ListByRiderID(ctx context.Context, riderID string) ([]*domain.TrackingSession, error)
+
+	// OpenPause and ClosePause record when a session was paused and resumed
+	OpenPause(ctx context.Context, sessionID string, startedAt time.Time) error
+	ClosePause(ctx context.Context, sessionID string, endedAt time.Time) error
+	// ListPauses returns the session's pauses, oldest first
+	ListPauses(ctx context.Context, sessionID string) ([]*domain.SessionPause, error)
+
+	// ListStale returns active, unpaused sessions of every organization last
+	// heard from before idleSince, longest silent first. It is not tenant scoped.
+	ListStale(ctx context.Context, idleSince time.Time, limit int) ([]*domain.StaleSession, error)
+}
+
+type DeliveryRepository interface {
+	Create(ctx context.Context, delivery *domain.Delivery) error
+	GetByID(ctx context.Context, deliveryID string) (*domain.Delivery, error)
+	Update(ctx context.Context, delivery *domain.Delivery) error
+	// GetForUpdate is GetByID, locking the delivery until the caller's transaction ends
+	GetForUpdate(ctx context.Context, deliveryID string) (*domain.Delivery, error)
+	// RenewDispatch moves the dispatch claim expiring at claimedUntil to until,
+	// ErrConflict when the claim ran out and was taken over
+	RenewDispatch(ctx context.Context, deliveryID string, claimedUntil, until time.Time) error
+	// ListExpiredDispatches returns deliveries of every organization whose
+	// dispatch claim ran out, longest expired first. It is not tenant scoped.
+	ListExpiredDispatches(ctx context.Context, limit int) ([]*domain.ExpiredDispatch, error)
+}
+
+// DispatchRepository finds riders for a delivery and keeps the offers made to
+// them, so a rider's answer reaches whichever instance is dispatching
+type DispatchRepository interface {
+	// ListCandidates returns the active, unpaused sessions within radiusMeters
+	// of the point whose rider is available and on shift, nearest first
+	ListCandidates(ctx context.Context, point domain.Coordinate, radiusMeters float64) ([]*domain.DispatchCandidate, error)
+
+	CreateOffer(ctx context.Context, offer *domain.DispatchOffer) error
+	// RespondToOffer records the session's answer to an open offer made to it,
+	// ErrNotFound when there is none
+	RespondToOffer(ctx context.Context, offerID, sessionID string, accepted bool) error
+	// GetOfferResponse returns the answer to an offer, nil while it is open
+	GetOfferResponse(ctx context.Context, offerID string) (*bool, error)
+	// CloseOffer declines an offer still open and returns its final answer,
+	// which is the rider's if they answered first
+	CloseOffer(ctx context.Context, offerID string) (bool, error)
+}
+
+type RiderRepository interface {
+	Create(ctx context.Context, rider *domain.Rider) error
+	GetByID(ctx context.Context, riderID string) (*domain.Rider, error)
+	Update(ctx context.Context, rider *domain.Rider) error
+	// Anonymize strips the rider's personal details and marks them erased
+	Anonymize(ctx context.Context, riderID string) error
+}
+
+// OrganizationRepository is not tenant scoped, it is used to resolve tenants
+type OrganizationRepository interface {
+	Create(ctx context.Context, organization *domain.Organization) error
+	GetByID(ctx context.Context, organizationID string) (*domain.Organization, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	ListByOrganization(ctx context.Context) ([]*domain.APIKey, error)
+	GetByID(ctx context.Context, keyID string) (*domain.APIKey, error)
+	// GetByPrefix is not tenant scoped, it is used to authenticate requests
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	UpdateSecret(ctx context.Context, key *domain.APIKey) error
+	Revoke(ctx context.Context, keyID string, revokedAt time.Time) error
+	TouchLastUsed(ctx context.Context, keyID string, usedAt time.Time) error
+}
+
+type RiderCredentialRepository interface {
+	Create(ctx context.Context, credential *domain.RiderCredential) error
+	ListByRiderID(ctx context.Context, riderID string) ([]*domain.RiderCredential, error)
+	// GetByPrefix is not tenant scoped, it is used to authenticate requests
+	GetByPrefix(ctx context.Context, prefix string) (*domain.RiderCredential, error)
+	Revoke(ctx context.Context, riderID, credentialID string, revokedAt time.Time) error
+	TouchLastUsed(ctx context.Context, credentialID string, usedAt time.Time) error
+}
+
+type TicketRepository interface {
+	Create(ctx context.Context, ticket *domain.AccessTicket) error
+	// Consume deletes and returns an unexpired ticket. It is not tenant scoped,
+	// the ticket names its organization.
+	Consume(ctx context.Context, tokenHash []byte, now time.Time) (*domain.AccessTicket, error)
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error
+	ListEndpoints(ctx context.Context) ([]*domain.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, endpointID string) error
+
+	// Enqueue queues a delivery of the event to every active endpoint of the
+	// event's organization that subscribes to it
+	Enqueue(ctx context.Context, event *domain.Event, payload []byte) error
+	ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error)
+	ListAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookAttempt, error)
+	// Retry re-queues a dead delivery, its attempts keep counting
+	Retry(ctx context.Context, deliveryID string) error
+
+	// The following are used by the dispatcher and are not tenant scoped
+
+	// ClaimDue leases up to limit pending deliveries that are due, hiding them from
+	// other dispatchers until lease has passed
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, attempt *domain.WebhookAttempt) error
+	MarkSucceeded(ctx context.Context, deliveryID string) error
+	ScheduleRetry(ctx context.Context, deliveryID string, nextAttemptAt time.Time, lastError string) error
+	MarkDead(ctx context.Context, deliveryID string, lastError string) error
+}
+
+// Transactor runs fn in a database transaction. Repositories called with the
+// ctx passed to fn take part in the transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OutboxRepository interface {
+	// Add writes the event to the outbox, inside the caller's transaction if there is one
+	Add(ctx context.Context, event *domain.Event) error
+	AddBatch(ctx context.Context, events []*domain.Event) error
+
+	// ListPublishedAfter returns the published events of a delivery and of the
+	// sessions assigned to it that were written after the given event, oldest
+	// first. ErrNotFound means the event is unknown or has been pruned.
+	ListPublishedAfter(ctx context.Context, afterEventID, deliveryID string, limit int) ([]*domain.Event, error)
+
+	// The following are used by the relay and are not tenant scoped
+
+	// ClaimUnpublished leases up to limit due events, oldest first
+	ClaimUnpublished(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEvent, error)
+	MarkPublished(ctx context.Context, eventID string) error
+	// ScheduleRetry records the sinks the event reached so far, the retry only
+	// goes to the others
+	ScheduleRetry(ctx context.Context, eventID string, deliveredSinks []string, nextAttemptAt time.Time, lastError string) error
+	// DeadLetter finishes an event that ran out of attempts and keeps a dead
+	// letter for every sink that still failed, keyed by sink name
+	DeadLetter(ctx context.Context, eventID string, failures map[string]string) error
+	// DeletePublishedBefore prunes events published before the cutoff
+	DeletePublishedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PartitionRepository manages the monthly partitions of location_updates. It
+// is not tenant scoped.
+type PartitionRepository interface {
+	// ListLocationPartitions returns attached and detached partitions, oldest first
+	ListLocationPartitions(ctx context.Context) ([]*domain.Partition, error)
+	// CreateLocationPartition adds the partition for the month starting at
+	// month, moving the month's points out of the default partition
+	CreateLocationPartition(ctx context.Context, month time.Time) (string, error)
+	// DetachLocationPartition detaches a monthly partition, keeping it and its
+	// points as a plain table. A partition already detached is left alone.
+	DetachLocationPartition(ctx context.Context, name string) error
+}
+
+type RetentionRepository interface {
+	GetPolicy(ctx context.Context) (*domain.RetentionPolicy, error)
+	SetPolicy(ctx context.Context, policy *domain.RetentionPolicy) error
+	DeletePolicy(ctx context.Context) error
+
+	// ListExpiredSessions returns ended sessions that still have points and
+	// whose last point was recorded before the cutoff
+	ListExpiredSessions(ctx context.Context, cutoff time.Time, limit int) ([]*domain.TrackingSession, error)
+
+	SaveSummary(ctx context.Context, summary *domain.SessionSummary) error
+	GetSummary(ctx context.Context, sessionID string) (*domain.SessionSummary, error)
+
+	// ListPolicies returns every organization's policy and is not tenant scoped
+	ListPolicies(ctx context.Context) ([]*domain.RetentionPolicy, error)
+}
+
+type PrivacyRepository interface {
+	CreateRequest(ctx context.Context, request *domain.PrivacyRequest) error
+	ListRequests(ctx context.Context, riderID string) ([]*domain.PrivacyRequest, error)
+
+	// EraseSessionTraces removes what is left of the sessions' movements once
+	// their points are gone: latest positions, summary routes and coordinates
+	// in queued events and webhook payloads
+	EraseSessionTraces(ctx context.Context, sessionIDs []string) error
+}
+
+// AuditRepository is append-only
+type AuditRepository interface {
+	Append(ctx context.Context, entry *domain.AuditEntry) error
+	// List returns matching entries, newest first
+	List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error)
+}
+
+type ProofRepository interface {
+	Create(ctx context.Context, proof *domain.DeliveryProof) error
+	ListByDeliveryID(ctx context.Context, deliveryID string) ([]*domain.DeliveryProof, error)
+	GetByID(ctx context.Context, deliveryID, proofID string) (*domain.DeliveryProof, error)
+
+	// SavePIN issues the delivery's PIN, replacing any previous one
+	SavePIN(ctx context.Context, pin *domain.DeliveryPIN) error
+	// GetPINForUpdate locks the delivery's PIN until the caller's transaction ends
+	GetPINForUpdate(ctx context.Context, deliveryID string) (*domain.DeliveryPIN, error)
+	UpdatePIN(ctx context.Context, pin *domain.DeliveryPIN) error
+}
+
+type PricingRepository interface {
+	GetRateCard(ctx context.Context, vehicleType domain.VehicleType) (*domain.RateCard, error)
+	// GetRateCardAt returns the version of the card that was in effect at the given time
+	GetRateCardAt(ctx context.Context, vehicleType domain.VehicleType, at time.Time) (*domain.RateCard, error)
+	ListRateCards(ctx context.Context) ([]*domain.RateCard, error)
+	// SaveRateCard creates or replaces the card of card.VehicleType
+	SaveRateCard(ctx context.Context, card *domain.RateCard) error
+	DeleteRateCard(ctx context.Context, vehicleType domain.VehicleType) error
+
+	CreateSurgeZone(ctx context.Context, zone *domain.SurgeZone) error
+	ListSurgeZones(ctx context.Context) ([]*domain.SurgeZone, error)
+	// DeleteSurgeZone retires the zone, trips that started before still find it
+	DeleteSurgeZone(ctx context.Context, zoneID string) error
+	// FindSurgeZones returns the zones covering point for the vehicle type that
+	// were in effect and not yet deleted at the given time
+	FindSurgeZones(ctx context.Context, point domain.Coordinate, vehicleType domain.VehicleType, at time.Time) ([]*domain.SurgeZone, error)
+
+	// SaveFare stores a session's final fare once, ErrConflict means it already has one
+	SaveFare(ctx context.Context, fare *domain.Fare) error
+	GetFare(ctx context.Context, sessionID string) (*domain.Fare, error)
+}
+
+type ShareLinkRepository interface {
+	Create(ctx context.Context, link *domain.ShareLink) error
+	ListByDeliveryID(ctx context.Context, deliveryID string) ([]*domain.ShareLink, error)
+	Revoke(ctx context.Context, deliveryID, linkID string, revokedAt time.Time) error
+
+	// GetByTokenHash resolves a presented token and is not tenant scoped
+	GetByTokenHash(ctx context.Context, tokenHash []byte) (*domain.ShareLink, error)
+}
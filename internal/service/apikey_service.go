@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+const (
+	apiKeyTag = "ebx"
+	// last_used_at is only written once per interval to avoid a write on every request
+	lastUsedResolution = time.Minute
+)
+
+var errInvalidAPIKey = &domain.DomainError{Code: "INVALID_API_KEY", Message: "api key is invalid or revoked"}
+
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+// CreateKey issues a key for the organization in ctx. The returned secret is
+// the full key and cannot be recovered later.
+func (s *APIKeyService) CreateKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", &domain.DomainError{Code: "INVALID_API_KEY_NAME", Message: "name is required"}
+	}
+
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	secret, prefix, hash := generateAPIKey()
+	key := &domain.APIKey{
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  scopes,
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.apiKeyRepo.ListByOrganization(ctx)
+}
+
+// RotateKey replaces a key's secret, keeping its name and scopes. The old secret stops working immediately.
+func (s *APIKeyService) RotateKey(ctx context.Context, keyID string) (*domain.APIKey, string, error) {
+	key, err := s.getKey(ctx, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if key.Revoked() {
+		return nil, "", &domain.DomainError{Code: "API_KEY_REVOKED", Message: "revoked keys cannot be rotated"}
+	}
+
+	secret, prefix, hash := generateAPIKey()
+	key.Prefix = prefix
+	key.KeyHash = hash
+
+	if err := s.apiKeyRepo.UpdateSecret(ctx, key); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", &domain.DomainError{Code: "API_KEY_NOT_FOUND", Message: "api key does not exist"}
+		}
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, keyID string) error {
+	err := s.apiKeyRepo.Revoke(ctx, keyID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "API_KEY_NOT_FOUND", Message: "api key does not exist or is already revoked"}
+	}
+	return err
+}
+
+// Authenticate resolves a raw key presented by a client
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	prefix, ok := parseAPIKeyPrefix(rawKey)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(rawKey))
+	if subtle.ConstantTimeCompare(hash[:], key.KeyHash) != 1 || key.Revoked() {
+		return nil, errInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("Failed to record api key use: %v", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyService) getKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(ctx, keyID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "API_KEY_NOT_FOUND", Message: "api key does not exist"}
+	}
+	return key, err
+}
+
+// generateAPIKey returns a key of the form ebx_<prefix>_<secret>, its prefix and its hash
+func generateAPIKey() (string, string, []byte) {
+	return generateSecret(apiKeyTag)
+}
+
+func parseAPIKeyPrefix(rawKey string) (string, bool) {
+	return parseSecretPrefix(apiKeyTag, rawKey)
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return &domain.DomainError{Code: "INVALID_SCOPES", Message: "at least one scope is required"}
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, k := range domain.KnownScopes {
+			if scope == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return &domain.DomainError{Code: "INVALID_SCOPES", Message: "unknown scope " + scope}
+		}
+	}
+
+	return nil
+}
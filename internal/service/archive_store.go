@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/pkg/s3"
+)
+
+// ArchiveStore keeps exported location archives. Keys are slash separated
+// paths such as <organization>/<yyyy>/<mm>/<session>.geojson.gz.
+type ArchiveStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes an archive, a missing one is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewArchiveStore returns the store selected by ARCHIVE_STORE
+func NewArchiveStore(cfg *config.RetentionConfig) (ArchiveStore, error) {
+	switch cfg.Store {
+	case "local":
+		return &LocalArchiveStore{dir: cfg.LocalDir}, nil
+	case "s3":
+		return &S3ArchiveStore{client: &s3.Client{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			HTTP:      &http.Client{Timeout: time.Minute},
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown archive store %q", cfg.Store)
+}
+
+// LocalArchiveStore writes archives to a directory, the stand-in for an
+// object store in development
+type LocalArchiveStore struct {
+	dir string
+}
+
+func (s *LocalArchiveStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// written aside and renamed so a crash never leaves a partial archive
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalArchiveStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalArchiveStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive key %q escapes the archive directory", key)
+	}
+	return path, nil
+}
+
+// S3ArchiveStore uploads archives to an S3 compatible bucket
+type S3ArchiveStore struct {
+	client *s3.Client
+}
+
+func (s *S3ArchiveStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.client.PutObject(ctx, key, data, contentType)
+}
+
+func (s *S3ArchiveStore) Delete(ctx context.Context, key string) error {
+	return s.client.DeleteObject(ctx, key)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+// fields that change on every write and say nothing about what was done
+var auditIgnoredFields = map[string]bool{"updatedAt": true}
+
+// AuditLog records who changed what. Called inside a transaction the entry is
+// only kept if the change commits.
+type AuditLog struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditLog(auditRepo repository.AuditRepository) *AuditLog {
+	return &AuditLog{auditRepo: auditRepo}
+}
+
+// Record appends an entry for a change made by the context's actor. before is
+// nil for creations, after is nil for deletions.
+func (a *AuditLog) Record(ctx context.Context, action, entityType, entityID string, before, after interface{}) error {
+	entry := &domain.AuditEntry{
+		Actor:      actor.FromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	var beforeFields, afterFields map[string]interface{}
+	var err error
+
+	if before != nil {
+		if entry.Before, beforeFields, err = auditSnapshot(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, afterFields, err = auditSnapshot(after); err != nil {
+			return err
+		}
+	}
+
+	if entry.Diff, err = auditDiff(beforeFields, afterFields); err != nil {
+		return err
+	}
+
+	return a.auditRepo.Append(ctx, entry)
+}
+
+// List queries the organization's audit log, newest first
+func (a *AuditLog) List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &domain.DomainError{Code: "INVALID_RANGE", Message: "from must be before to"}
+	}
+
+	return a.auditRepo.List(ctx, filter)
+}
+
+func auditSnapshot(value interface{}) (json.RawMessage, map[string]interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, fmt.Errorf("audit snapshot is not an object: %w", err)
+	}
+
+	return raw, fields, nil
+}
+
+// auditDiff lists the fields whose value differs between the two snapshots
+func auditDiff(before, after map[string]interface{}) (json.RawMessage, error) {
+	type change struct {
+		From interface{} `json:"from"`
+		To   interface{} `json:"to"`
+	}
+
+	diff := make(map[string]change)
+	for field, to := range after {
+		if from := before[field]; !auditIgnoredFields[field] && !reflect.DeepEqual(from, to) {
+			diff[field] = change{From: from, To: to}
+		}
+	}
+	for field, from := range before {
+		if _, ok := after[field]; !ok && !auditIgnoredFields[field] {
+			diff[field] = change{From: from}
+		}
+	}
+
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(diff)
+}
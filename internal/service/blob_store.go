@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/pkg/s3"
+)
+
+// errBlobNotFound is returned by blob stores for keys they do not hold
+var errBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files such as proof of delivery photos. It is an
+// ArchiveStore that can also read back what it stored.
+type BlobStore interface {
+	ArchiveStore
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// NewBlobStore returns the store selected by PROOF_STORE
+func NewBlobStore(cfg *config.ProofConfig) (BlobStore, error) {
+	switch cfg.Store {
+	case "local":
+		return &LocalBlobStore{LocalArchiveStore{dir: cfg.LocalDir}}, nil
+	case "s3":
+		return &S3BlobStore{S3ArchiveStore{client: &s3.Client{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			HTTP:      &http.Client{Timeout: time.Minute},
+		}}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown proof store %q", cfg.Store)
+}
+
+// LocalBlobStore keeps files in a directory
+type LocalBlobStore struct {
+	LocalArchiveStore
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return data, err
+}
+
+// S3BlobStore keeps files in an S3 compatible bucket
+type S3BlobStore struct {
+	S3ArchiveStore
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.client.GetObject(ctx, key)
+}
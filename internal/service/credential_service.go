@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+const (
+	riderCredentialTag = "ebr"
+	// tickets only have to survive the round trip between issuing and the handshake
+	ticketTTL = 30 * time.Second
+)
+
+var errInvalidRiderCredential = &domain.DomainError{Code: "INVALID_RIDER_CREDENTIAL", Message: "rider credential is invalid or revoked"}
+var errInvalidTicket = &domain.DomainError{Code: "INVALID_TICKET", Message: "ticket is invalid, expired or already used"}
+
+type RiderCredentialService struct {
+	credentialRepo repository.RiderCredentialRepository
+}
+
+func NewRiderCredentialService(credentialRepo repository.RiderCredentialRepository) *RiderCredentialService {
+	return &RiderCredentialService{credentialRepo: credentialRepo}
+}
+
+// IsRiderCredential tells rider credentials apart from api keys by their tag
+func IsRiderCredential(raw string) bool {
+	return strings.HasPrefix(raw, riderCredentialTag+"_")
+}
+
+// IssueCredential creates a credential for a rider of the organization in
+// ctx. The returned secret cannot be recovered later.
+func (s *RiderCredentialService) IssueCredential(ctx context.Context, riderID, name string) (*domain.RiderCredential, string, error) {
+	secret, prefix, hash := generateSecret(riderCredentialTag)
+	credential := &domain.RiderCredential{
+		RiderID:   riderID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    prefix,
+		TokenHash: hash,
+	}
+
+	err := s.credentialRepo.Create(ctx, credential)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", &domain.DomainError{Code: "RIDER_NOT_FOUND", Message: "rider does not exist"}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return credential, secret, nil
+}
+
+func (s *RiderCredentialService) ListCredentials(ctx context.Context, riderID string) ([]*domain.RiderCredential, error) {
+	return s.credentialRepo.ListByRiderID(ctx, riderID)
+}
+
+func (s *RiderCredentialService) RevokeCredential(ctx context.Context, riderID, credentialID string) error {
+	err := s.credentialRepo.Revoke(ctx, riderID, credentialID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "RIDER_CREDENTIAL_NOT_FOUND", Message: "rider credential does not exist or is already revoked"}
+	}
+	return err
+}
+
+// Authenticate resolves a raw credential presented by a rider's app
+func (s *RiderCredentialService) Authenticate(ctx context.Context, raw string) (*domain.RiderCredential, error) {
+	prefix, ok := parseSecretPrefix(riderCredentialTag, raw)
+	if !ok {
+		return nil, errInvalidRiderCredential
+	}
+
+	credential, err := s.credentialRepo.GetByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidRiderCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(raw))
+	if subtle.ConstantTimeCompare(hash[:], credential.TokenHash) != 1 || credential.Revoked() {
+		return nil, errInvalidRiderCredential
+	}
+
+	now := time.Now()
+	if credential.LastUsedAt == nil || now.Sub(*credential.LastUsedAt) > lastUsedResolution {
+		if err := s.credentialRepo.TouchLastUsed(ctx, credential.ID, now); err != nil {
+			log.Printf("Failed to record rider credential use: %v", err)
+		}
+		credential.LastUsedAt = &now
+	}
+
+	return credential, nil
+}
+
+type TicketService struct {
+	ticketRepo repository.TicketRepository
+}
+
+func NewTicketService(ticketRepo repository.TicketRepository) *TicketService {
+	return &TicketService{ticketRepo: ticketRepo}
+}
+
+// Issue returns a single use ticket for scope on behalf of the actor and
+// organization in ctx
+func (s *TicketService) Issue(ctx context.Context, scope string) (string, *domain.AccessTicket, error) {
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	hash := sha256.Sum256([]byte(token))
+
+	ticket := &domain.AccessTicket{
+		TokenHash: hash[:],
+		Scope:     scope,
+		Actor:     actor.FromContext(ctx),
+		ExpiresAt: time.Now().Add(ticketTTL).UTC(),
+	}
+
+	if err := s.ticketRepo.Create(ctx, ticket); err != nil {
+		return "", nil, err
+	}
+
+	return token, ticket, nil
+}
+
+// Redeem consumes a ticket, which must have been issued for scope
+func (s *TicketService) Redeem(ctx context.Context, token, scope string) (*domain.AccessTicket, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	ticket, err := s.ticketRepo.Consume(ctx, hash[:], time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidTicket
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.Scope != scope {
+		return nil, errInvalidTicket
+	}
+
+	return ticket, nil
+}
+
+// generateSecret returns a secret of the form <tag>_<prefix>_<secret>, its
+// prefix and its hash
+func generateSecret(tag string) (string, string, []byte) {
+	prefixBytes := make([]byte, 6)
+	rand.Read(prefixBytes)
+	secretBytes := make([]byte, 32)
+	rand.Read(secretBytes)
+
+	prefix := hex.EncodeToString(prefixBytes)
+	secret := tag + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+	hash := sha256.Sum256([]byte(secret))
+
+	return secret, prefix, hash[:]
+}
+
+func parseSecretPrefix(tag, raw string) (string, bool) {
+	parts := strings.SplitN(raw, "_", 3)
+	if len(parts) != 3 || parts[0] != tag || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+// RiderNotifier pushes messages to riders over their live connection, on
+// whichever instance holds it. Delivery is not confirmed; a rider who is not
+// connected anywhere lets an offer run out.
+type RiderNotifier interface {
+	SendToSession(ctx context.Context, sessionID string, msgType string, payload interface{}) error
+}
+
+// RiderChannel carries messages for riders' sessions between instances
+type RiderChannel interface {
+	Send(ctx context.Context, organizationID, sessionID, msgType string, payload interface{}) error
+	// Listen calls received for every message sent, to any instance, until
+	// ctx is cancelled
+	Listen(ctx context.Context, received func(organizationID, sessionID, msgType string, payload json.RawMessage)) error
+}
+
+const (
+	// how often an open offer is checked for the rider's answer
+	offerPollInterval = 500 * time.Millisecond
+	// how long a dispatch claim outlives the offer it was renewed for
+	dispatchLeaseGrace = 30 * time.Second
+	// abandoned dispatches claimed again per pass, the rest wait for the next one
+	reclaimBatchSize = 100
+)
+
+var (
+	errDispatchInProgress = &domain.DomainError{Code: "DISPATCH_IN_PROGRESS", Message: "delivery is already being dispatched"}
+	errOfferExpired       = &domain.DomainError{Code: "OFFER_EXPIRED", Message: "offer does not exist or has expired"}
+)
+
+type dispatchCandidate struct {
+	sessionID string
+	distance  float64
+	load      int
+	rank      float64
+}
+
+// DispatchService offers deliveries to nearby riders. Dispatch state and
+// offers live in the database, so any instance can take a rider's answer.
+type DispatchService struct {
+	deliveryRepo repository.DeliveryRepository
+	dispatchRepo repository.DispatchRepository
+	tx           repository.Transactor
+	events       EventPublisher
+	audit        *AuditLog
+	notifier     RiderNotifier
+	cfg          *config.DispatchConfig
+}
+
+func NewDispatchService(deliveryRepo repository.DeliveryRepository, dispatchRepo repository.DispatchRepository, tx repository.Transactor, events EventPublisher, audit *AuditLog, notifier RiderNotifier, cfg *config.DispatchConfig) *DispatchService {
+	return &DispatchService{
+		deliveryRepo: deliveryRepo,
+		dispatchRepo: dispatchRepo,
+		tx:           tx,
+		events:       events,
+		audit:        audit,
+		notifier:     notifier,
+		cfg:          cfg,
+	}
+}
+
+// CreateDelivery stores a new delivery and starts dispatching it in the background
+func (s *DispatchService) CreateDelivery(ctx context.Context, pickup, dropoff domain.Coordinate) (*domain.Delivery, error) {
+	if err := validateCoordinate(pickup); err != nil {
+		return nil, err
+	}
+	if err := validateCoordinate(dropoff); err != nil {
+		return nil, err
+	}
+
+	delivery := &domain.Delivery{
+		Pickup:  pickup,
+		Dropoff: dropoff,
+		Status:  domain.DeliveryPending,
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, domain.AuditDeliveryCreate, domain.AuditEntityDelivery, delivery.ID, nil, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dispatching, err := s.DispatchAsync(ctx, delivery.ID)
+	if err != nil {
+		log.Printf("[DISPATCH] delivery %s: %v", delivery.ID, err)
+		return delivery, nil
+	}
+
+	return dispatching, nil
+}
+
+func (s *DispatchService) GetDelivery(ctx context.Context, deliveryID string) (*domain.Delivery, error) {
+	delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+	}
+	return delivery, err
+}
+
+// DispatchAsync claims the delivery for dispatching, then makes the offers in
+// the background, detached from the caller's cancellation. It returns the
+// delivery as claimed, or why it cannot be dispatched.
+func (s *DispatchService) DispatchAsync(ctx context.Context, deliveryID string) (*domain.Delivery, error) {
+	delivery, err := s.claim(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	claimed := *delivery
+
+	go func() {
+		if err := s.offerDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+			log.Printf("[DISPATCH] delivery %s: %v", deliveryID, err)
+		}
+	}()
+
+	return &claimed, nil
+}
+
+// claim moves a pending or unassigned delivery to dispatching, or takes over
+// a dispatch whose claim ran out. The row is locked meanwhile, so of two
+// instances claiming it only one succeeds.
+func (s *DispatchService) claim(ctx context.Context, deliveryID string) (*domain.Delivery, error) {
+	var delivery *domain.Delivery
+
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		delivery, err = s.deliveryRepo.GetForUpdate(ctx, deliveryID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+		}
+		if err != nil {
+			return err
+		}
+
+		switch delivery.Status {
+		case domain.DeliveryPending, domain.DeliveryUnassigned:
+		case domain.DeliveryDispatching:
+			if delivery.DispatchExpiresAt != nil && delivery.DispatchExpiresAt.After(time.Now()) {
+				return errDispatchInProgress
+			}
+			log.Printf("[DISPATCH] delivery %s: taking over an abandoned dispatch", delivery.ID)
+		default:
+			return &domain.DomainError{Code: "DELIVERY_NOT_DISPATCHABLE", Message: fmt.Sprintf("delivery is %s", delivery.Status)}
+		}
+
+		expiresAt := s.leaseExpiry()
+		delivery.DispatchExpiresAt = &expiresAt
+		return s.setStatus(ctx, delivery, domain.DeliveryDispatching, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// offerDelivery offers a claimed delivery to the best ranked riders one at a
+// time until one accepts. If every offer is declined or times out the delivery
+// is marked unassigned.
+func (s *DispatchService) offerDelivery(ctx context.Context, delivery *domain.Delivery) error {
+	candidates, err := s.rankCandidates(ctx, delivery)
+	if err != nil {
+		s.setStatus(ctx, delivery, domain.DeliveryUnassigned, nil)
+		return err
+	}
+
+	for _, candidate := range candidates {
+		// the claim must last through the offer, and may have been taken over
+		if err := s.renewClaim(ctx, delivery); err != nil {
+			return err
+		}
+
+		if s.offer(ctx, delivery, candidate) {
+			sessionID := candidate.sessionID
+			if err := s.setStatus(ctx, delivery, domain.DeliveryAssigned, &sessionID); err != nil {
+				return err
+			}
+
+			log.Printf("[DISPATCH] delivery %s assigned to session %s", delivery.ID, sessionID)
+			if err := s.notifier.SendToSession(ctx, sessionID, "assignment", delivery); err != nil {
+				log.Printf("[DISPATCH] failed to confirm assignment to %s: %v", sessionID, err)
+			}
+			return nil
+		}
+	}
+
+	log.Printf("[DISPATCH] delivery %s: no rider accepted (%d offered)", delivery.ID, len(candidates))
+	return s.setStatus(ctx, delivery, domain.DeliveryUnassigned, nil)
+}
+
+// renewClaim extends the delivery's dispatch claim past the next offer. It
+// fails when the claim ran out and another dispatch took the delivery over.
+func (s *DispatchService) renewClaim(ctx context.Context, delivery *domain.Delivery) error {
+	expiresAt := s.leaseExpiry()
+
+	err := s.deliveryRepo.RenewDispatch(ctx, delivery.ID, *delivery.DispatchExpiresAt, expiresAt)
+	if errors.Is(err, repository.ErrConflict) {
+		return fmt.Errorf("dispatch claim ran out and was taken over")
+	}
+	if err != nil {
+		return err
+	}
+
+	delivery.DispatchExpiresAt = &expiresAt
+	return nil
+}
+
+// leaseExpiry is when a claim made or renewed now runs out. It is kept to the
+// database's precision so the claim can be matched when renewed.
+func (s *DispatchService) leaseExpiry() time.Time {
+	return time.Now().Add(s.cfg.OfferTimeout + dispatchLeaseGrace).Truncate(time.Microsecond)
+}
+
+// Run claims deliveries whose dispatch was abandoned, e.g. by an instance that
+// stopped mid-offer, and dispatches them again until ctx is cancelled
+func (s *DispatchService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.ReclaimInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.reclaim(ctx); err != nil {
+			log.Printf("[DISPATCH] %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *DispatchService) reclaim(ctx context.Context) error {
+	expired, err := s.deliveryRepo.ListExpiredDispatches(ctx, reclaimBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, dispatch := range expired {
+		orgCtx := tenant.WithOrganization(ctx, dispatch.OrganizationID)
+
+		// another instance may have reclaimed it first
+		if _, err := s.DispatchAsync(orgCtx, dispatch.DeliveryID); err != nil && !errors.Is(err, errDispatchInProgress) {
+			log.Printf("[DISPATCH] delivery %s: %v", dispatch.DeliveryID, err)
+		}
+	}
+
+	return nil
+}
+
+// setStatus moves the delivery to status, assigning it to a session if one is
+// given. The change is audited and published so watchers learn the session.
+func (s *DispatchService) setStatus(ctx context.Context, delivery *domain.Delivery, status domain.DeliveryStatus, sessionID *string) error {
+	before := *delivery
+	delivery.Status = status
+	if sessionID != nil {
+		delivery.AssignedSessionID = sessionID
+	}
+	// only a delivery being dispatched is claimed
+	if status != domain.DeliveryDispatching {
+		delivery.DispatchExpiresAt = nil
+	}
+
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.deliveryRepo.Update(ctx, delivery); err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, domain.AuditDeliveryUpdate, domain.AuditEntityDelivery, delivery.ID, &before, delivery); err != nil {
+			return err
+		}
+
+		eventType := domain.EventDeliveryStatusChanged
+		if status == domain.DeliveryAssigned {
+			eventType = domain.EventDeliveryAssigned
+		}
+
+		data := domain.DeliveryEventData{DeliveryID: delivery.ID, Status: status}
+		if delivery.AssignedSessionID != nil {
+			data.SessionID = *delivery.AssignedSessionID
+		}
+		return s.events.Publish(ctx, eventType, data)
+	})
+}
+
+// RespondToOffer records a rider's answer to an outstanding offer. The
+// instance dispatching the delivery picks it up from the database.
+func (s *DispatchService) RespondToOffer(ctx context.Context, sessionID, offerID string, accepted bool) error {
+	err := s.dispatchRepo.RespondToOffer(ctx, offerID, sessionID, accepted)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errOfferExpired
+	}
+	return err
+}
+
+// rankCandidates orders the available, on shift riders near the pickup by
+// distance, penalizing those already carrying deliveries
+func (s *DispatchService) rankCandidates(ctx context.Context, delivery *domain.Delivery) ([]dispatchCandidate, error) {
+	nearby, err := s.dispatchRepo.ListCandidates(ctx, delivery.Pickup, s.cfg.SearchRadius)
+	if err != nil {
+		return nil, err
+	}
+
+	pickup := geo.Point{Latitude: delivery.Pickup.Latitude, Longitude: delivery.Pickup.Longitude}
+
+	var candidates []dispatchCandidate
+	for _, found := range nearby {
+		if found.Load >= s.cfg.MaxLoad {
+			continue
+		}
+
+		distance := geo.Haversine(pickup, geo.Point{Latitude: found.Location.Latitude, Longitude: found.Location.Longitude})
+		candidates = append(candidates, dispatchCandidate{
+			sessionID: found.SessionID,
+			distance:  distance,
+			load:      found.Load,
+			rank:      distance + float64(found.Load)*s.cfg.LoadPenalty,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].rank < candidates[j].rank })
+	if len(candidates) > s.cfg.MaxCandidates {
+		candidates = candidates[:s.cfg.MaxCandidates]
+	}
+
+	return candidates, nil
+}
+
+// offer sends the delivery to a single rider and waits for their answer
+func (s *DispatchService) offer(ctx context.Context, delivery *domain.Delivery, candidate dispatchCandidate) bool {
+	offer := &domain.DispatchOffer{
+		ID:         newOfferID(),
+		DeliveryID: delivery.ID,
+		SessionID:  candidate.sessionID,
+		Pickup:     delivery.Pickup,
+		Dropoff:    delivery.Dropoff,
+		Distance:   candidate.distance,
+		ExpiresAt:  time.Now().Add(s.cfg.OfferTimeout),
+	}
+
+	if err := s.dispatchRepo.CreateOffer(ctx, offer); err != nil {
+		log.Printf("[DISPATCH] could not store offer of delivery %s to %s: %v", delivery.ID, candidate.sessionID, err)
+		return false
+	}
+
+	// whatever happens the offer is closed, a late answer must not count
+	defer func() {
+		if _, err := s.dispatchRepo.CloseOffer(context.WithoutCancel(ctx), offer.ID); err != nil {
+			log.Printf("[DISPATCH] failed to close offer %s: %v", offer.ID, err)
+		}
+	}()
+
+	if err := s.notifier.SendToSession(ctx, candidate.sessionID, "offer", offer); err != nil {
+		log.Printf("[DISPATCH] could not offer delivery %s to %s: %v", delivery.ID, candidate.sessionID, err)
+		return false
+	}
+
+	timer := time.NewTimer(s.cfg.OfferTimeout)
+	defer timer.Stop()
+	poll := time.NewTicker(offerPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+			accepted, err := s.dispatchRepo.GetOfferResponse(ctx, offer.ID)
+			if err != nil {
+				log.Printf("[DISPATCH] failed to check offer %s: %v", offer.ID, err)
+				continue
+			}
+			if accepted != nil {
+				return *accepted
+			}
+		case <-timer.C:
+			// the rider may have answered since the last check
+			accepted, err := s.dispatchRepo.CloseOffer(ctx, offer.ID)
+			if err != nil {
+				log.Printf("[DISPATCH] failed to close offer %s: %v", offer.ID, err)
+				return false
+			}
+			if accepted {
+				return true
+			}
+
+			log.Printf("[DISPATCH] offer %s to %s timed out", offer.ID, candidate.sessionID)
+			s.notifier.SendToSession(ctx, candidate.sessionID, "offer_expired", offer)
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func newOfferID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validateCoordinate(c domain.Coordinate) error {
+	if c.Latitude < -90 || c.Latitude > 90 {
+		return &domain.DomainError{Code: "INVALID_LATITUDE", Message: "Latitude must be between -90 and 90"}
+	}
+	if c.Longitude < -180 || c.Longitude > 180 {
+		return &domain.DomainError{Code: "INVALID_LONGITUDE", Message: "Longitude must be between -180 and 180"}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+)
+
+// LogSink writes every relayed event to the standard logger
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, event *domain.Event) error {
+	data, _ := json.Marshal(event.Data)
+	log.Printf("[EVENT] %s %s org=%s %s", event.Type, event.ID, event.OrganizationID, data)
+	return nil
+}
+
+// WebhookSink queues relayed events for the organization's webhook endpoints
+type WebhookSink struct {
+	webhooks *WebhookService
+}
+
+func NewWebhookSink(webhooks *WebhookService) *WebhookSink {
+	return &WebhookSink{webhooks: webhooks}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Publish(ctx context.Context, event *domain.Event) error {
+	return s.webhooks.Enqueue(ctx, event)
+}
+
+// eventBusBuffer is how many events a subscriber may fall behind before it
+// is cut off
+const eventBusBuffer = 256
+
+// EventBroadcaster carries relayed events to every instance of the service
+type EventBroadcaster interface {
+	Broadcast(ctx context.Context, event *domain.Event) error
+	// Listen calls received for every broadcast event until ctx is cancelled,
+	// and missed when events of the organization, or of any organization when
+	// it is empty, could not be received
+	Listen(ctx context.Context, received func(event *domain.Event), missed func(organizationID string)) error
+}
+
+// EventBus is the sink live subscribers follow. The relay publishes each event
+// on one instance only, so the bus broadcasts it and every instance, this one
+// included, fans what it receives out to its own subscribers.
+type EventBus struct {
+	broadcaster EventBroadcaster
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewEventBus(broadcaster EventBroadcaster) *EventBus {
+	return &EventBus{
+		broadcaster: broadcaster,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *EventBus) Name() string { return "bus" }
+
+func (b *EventBus) Publish(ctx context.Context, event *domain.Event) error {
+	return b.broadcaster.Broadcast(ctx, event)
+}
+
+// Run delivers broadcast events to the subscribers of this instance until ctx
+// is cancelled
+func (b *EventBus) Run(ctx context.Context) error {
+	return b.broadcaster.Listen(ctx, b.deliver, b.lose)
+}
+
+func (b *EventBus) deliver(event *domain.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if sub.organizationID == event.OrganizationID && sub.filter(event) {
+			sub.send(event)
+		}
+	}
+}
+
+// lose cuts off the subscribers of an organization whose events were missed,
+// every subscriber when organizationID is empty
+func (b *EventBus) lose(organizationID string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if organizationID == "" || sub.organizationID == organizationID {
+			sub.end(true)
+		}
+	}
+}
+
+// Subscribe follows the organization's events that pass filter. filter runs
+// on the bus, before the event is queued for the subscriber.
+func (b *EventBus) Subscribe(organizationID string, filter func(event *domain.Event) bool) *Subscription {
+	events := make(chan *domain.Event, eventBusBuffer)
+	sub := &Subscription{
+		Events:         events,
+		bus:            b,
+		organizationID: organizationID,
+		filter:         filter,
+		events:         events,
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Subscription is one subscriber's stream of events. A subscriber that falls
+// behind, or would miss events for another reason, is cut off rather than
+// skipped over: Events is closed and Lost reports true, and it must resume
+// from the stored events.
+type Subscription struct {
+	Events <-chan *domain.Event
+
+	bus            *EventBus
+	organizationID string
+	filter         func(event *domain.Event) bool
+
+	mu     sync.Mutex
+	events chan *domain.Event
+	closed bool
+	lost   bool
+}
+
+// Lost reports whether Events was closed because events were missed
+func (s *Subscription) Lost() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lost
+}
+
+// Close ends the subscription and closes Events
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subscribers, s)
+	s.bus.mu.Unlock()
+
+	s.end(false)
+}
+
+func (s *Subscription) send(event *domain.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		log.Printf("[EVENT] subscriber for %s fell behind at %s, cutting it off", s.organizationID, event.ID)
+		s.closed, s.lost = true, true
+		close(s.events)
+	}
+}
+
+func (s *Subscription) end(lost bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed, s.lost = true, lost
+		close(s.events)
+	}
+}
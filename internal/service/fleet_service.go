@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+// fleetStatuses are the statuses an active session's delivery can be in; a
+// delivered or cancelled one is no longer shown on the session
+var fleetStatuses = map[string]bool{
+	domain.FleetStatusIdle:             true,
+	string(domain.DeliveryPending):     true,
+	string(domain.DeliveryDispatching): true,
+	string(domain.DeliveryAssigned):    true,
+	string(domain.DeliveryInTransit):   true,
+	string(domain.DeliveryUnassigned):  true,
+}
+
+const (
+	maxPositionSessions = 500
+	maxPositionRadius   = 50000
+)
+
+// FleetService gives dispatchers an overview of the riders currently tracking
+type FleetService struct {
+	sessionRepo  repository.SessionRepository
+	locationRepo repository.LocationRepository
+}
+
+func NewFleetService(sessionRepo repository.SessionRepository, locationRepo repository.LocationRepository) *FleetService {
+	return &FleetService{sessionRepo: sessionRepo, locationRepo: locationRepo}
+}
+
+// PositionQuery narrows LatestPositions to a set of sessions, a bounding box
+// or a radius around a point. At most one of them may be set.
+type PositionQuery struct {
+	SessionIDs []string
+	Bounds     *domain.BoundingBox
+	Center     *domain.Coordinate
+	// meters around Center
+	Radius float64
+}
+
+// ListActiveSessions returns the active sessions whose delivery status is one
+// of statuses, or all of them when statuses is empty. Sessions without a
+// delivery match domain.FleetStatusIdle.
+func (s *FleetService) ListActiveSessions(ctx context.Context, statuses []string) ([]*domain.FleetSession, error) {
+	wanted := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		if !fleetStatuses[status] {
+			return nil, &domain.DomainError{Code: "INVALID_STATUS_FILTER", Message: "unknown delivery status " + status}
+		}
+		wanted[status] = true
+	}
+
+	sessions, err := s.sessionRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wanted) == 0 {
+		return sessions, nil
+	}
+
+	filtered := sessions[:0]
+	for _, session := range sessions {
+		status := domain.FleetStatusIdle
+		if session.DeliveryStatus != nil {
+			status = string(*session.DeliveryStatus)
+		}
+		if wanted[status] {
+			filtered = append(filtered, session)
+		}
+	}
+
+	return filtered, nil
+}
+
+// LatestPositions returns the latest location of the organization's sessions.
+// Without a filter every active session is returned.
+func (s *FleetService) LatestPositions(ctx context.Context, query PositionQuery) ([]*domain.LocationUpdate, error) {
+	filters := 0
+	if len(query.SessionIDs) > 0 {
+		filters++
+	}
+	if query.Bounds != nil {
+		filters++
+	}
+	if query.Center != nil {
+		filters++
+	}
+	if filters > 1 {
+		return nil, &domain.DomainError{Code: "INVALID_POSITION_QUERY", Message: "filter by sessions, bounds or radius, not several"}
+	}
+
+	switch {
+	case len(query.SessionIDs) > 0:
+		if len(query.SessionIDs) > maxPositionSessions {
+			return nil, &domain.DomainError{Code: "INVALID_POSITION_QUERY", Message: fmt.Sprintf("at most %d sessions can be requested at once", maxPositionSessions)}
+		}
+		return s.locationRepo.GetLatestBySessionIDs(ctx, query.SessionIDs)
+
+	case query.Bounds != nil:
+		b := query.Bounds
+		if err := validateCoordinate(domain.Coordinate{Latitude: b.MinLatitude, Longitude: b.MinLongitude}); err != nil {
+			return nil, err
+		}
+		if err := validateCoordinate(domain.Coordinate{Latitude: b.MaxLatitude, Longitude: b.MaxLongitude}); err != nil {
+			return nil, err
+		}
+		if b.MinLatitude > b.MaxLatitude || b.MinLongitude > b.MaxLongitude {
+			return nil, &domain.DomainError{Code: "INVALID_BOUNDS", Message: "bounds minimum must not exceed the maximum"}
+		}
+		return s.locationRepo.GetLatestWithinBounds(ctx, *b)
+
+	case query.Center != nil:
+		if err := validateCoordinate(*query.Center); err != nil {
+			return nil, err
+		}
+		if query.Radius <= 0 || query.Radius > maxPositionRadius {
+			return nil, &domain.DomainError{Code: "INVALID_RADIUS", Message: fmt.Sprintf("radius must be between 0 and %d meters", maxPositionRadius)}
+		}
+		return s.locationRepo.GetWithinRadius(ctx, query.Center.Latitude, query.Center.Longitude, query.Radius)
+	}
+
+	return s.locationRepo.GetLatestActive(ctx)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+var (
+	errIngestBusy   = &domain.DomainError{Code: "INGEST_BUSY", Message: "server is busy, retry shortly"}
+	errIngestClosed = &domain.DomainError{Code: "INGEST_CLOSED", Message: "server is shutting down"}
+)
+
+type pipelineItem struct {
+	organizationID string
+	session        *domain.TrackingSession
+	location       *domain.LocationUpdate
+}
+
+// LocationPipeline buffers accepted locations and writes them in batches.
+// Each shard has its own queue and worker; a session always maps to the same
+// shard so its points are stored in order. Points and their location.recorded
+// events are committed together. Accepted points are only dropped when the
+// database refuses them: any other failed flush is retried until it succeeds,
+// and while it is the shard's queue fills up and Submit pushes back with
+// INGEST_BUSY.
+type LocationPipeline struct {
+	locationRepo repository.LocationRepository
+	outboxRepo   repository.OutboxRepository
+	tx           repository.Transactor
+	cfg          *config.IngestConfig
+
+	// called for every stored point once its batch has committed
+	afterFlush func(ctx context.Context, item pipelineItem)
+
+	shards []chan pipelineItem
+	wg     sync.WaitGroup
+	// closed when Close runs out of time, retrying flushes give up then
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewLocationPipeline(locationRepo repository.LocationRepository, outboxRepo repository.OutboxRepository, tx repository.Transactor, cfg *config.IngestConfig) *LocationPipeline {
+	p := &LocationPipeline{
+		locationRepo: locationRepo,
+		outboxRepo:   outboxRepo,
+		tx:           tx,
+		cfg:          cfg,
+		shards:       make([]chan pipelineItem, cfg.Shards),
+		quit:         make(chan struct{}),
+	}
+
+	for i := range p.shards {
+		p.shards[i] = make(chan pipelineItem, cfg.QueueSize)
+	}
+
+	return p
+}
+
+// Start launches one worker per shard
+func (p *LocationPipeline) Start() {
+	for _, shard := range p.shards {
+		p.wg.Add(1)
+		go p.work(shard)
+	}
+}
+
+// Submit queues a location of an active session. When the shard stays full
+// for the enqueue timeout the client is told to back off with INGEST_BUSY.
+func (p *LocationPipeline) Submit(ctx context.Context, session *domain.TrackingSession, location *domain.LocationUpdate) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	item := pipelineItem{organizationID: organizationID, session: session, location: location}
+
+	// held while sending so Close cannot close the queue underneath us
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return errIngestClosed
+	}
+
+	shard := p.shards[p.shardFor(organizationID, session.SessionID)]
+
+	select {
+	case shard <- item:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(p.cfg.EnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case shard <- item:
+		return nil
+	case <-timer.C:
+		return errIngestBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting points and waits for the queues to be flushed, or
+// for ctx to end. Points still unwritten by then are lost.
+func (p *LocationPipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, shard := range p.shards {
+			close(shard)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.quitOnce.Do(func() { close(p.quit) })
+		return ctx.Err()
+	}
+}
+
+func (p *LocationPipeline) shardFor(organizationID, sessionID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(organizationID))
+	h.Write([]byte(sessionID))
+	return int(h.Sum32() % uint32(len(p.shards)))
+}
+
+func (p *LocationPipeline) work(shard chan pipelineItem) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pipelineItem, 0, p.cfg.BatchSize)
+
+	for {
+		select {
+		case item, ok := <-shard:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush writes a batch, one transaction per organization in it
+func (p *LocationPipeline) flush(batch []pipelineItem) {
+	if len(batch) == 0 {
+		return
+	}
+
+	byOrganization := make(map[string][]pipelineItem)
+	for _, item := range batch {
+		byOrganization[item.organizationID] = append(byOrganization[item.organizationID], item)
+	}
+
+	for organizationID, items := range byOrganization {
+		ctx := tenant.WithOrganization(context.Background(), organizationID)
+
+		if !p.writeUntilStored(ctx, items) {
+			log.Printf("[INGEST] dropped %d locations of organization %s, shutting down", len(items), organizationID)
+			continue
+		}
+
+		if p.afterFlush == nil {
+			continue
+		}
+		for _, item := range items {
+			if !item.location.CreatedAt.IsZero() {
+				p.afterFlush(ctx, item)
+			}
+		}
+	}
+}
+
+// writeUntilStored retries the write with capped backoff until it succeeds.
+// Rows the database refuses are dropped, see storeEach. It only gives up once
+// the pipeline is told to quit.
+func (p *LocationPipeline) writeUntilStored(ctx context.Context, items []pipelineItem) bool {
+	for attempt := 1; ; attempt++ {
+		err := p.write(ctx, items)
+		if err == nil {
+			return true
+		}
+
+		// a rolled back attempt may have stamped rows that were not stored
+		for _, item := range items {
+			item.location.CreatedAt = time.Time{}
+		}
+
+		if errors.Is(err, repository.ErrInvalidData) {
+			return p.storeEach(ctx, items, err)
+		}
+
+		delay := backoff(p.cfg.FlushBaseBackoff, p.cfg.FlushMaxBackoff, attempt)
+		log.Printf("[INGEST] failed to store %d locations, attempt %d, retrying in %v: %v", len(items), attempt, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-p.quit:
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// storeEach writes the items of a refused batch one at a time, so only the
+// rows the database will never accept are dropped
+func (p *LocationPipeline) storeEach(ctx context.Context, items []pipelineItem, err error) bool {
+	if len(items) == 1 {
+		location := items[0].location
+		log.Printf("[INGEST] dropped location of session %s recorded at %v: %v", location.SessionID, location.RecordedAt, err)
+		return true
+	}
+
+	for _, item := range items {
+		if !p.writeUntilStored(ctx, []pipelineItem{item}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *LocationPipeline) write(ctx context.Context, items []pipelineItem) error {
+	locations := make([]*domain.LocationUpdate, len(items))
+	for i, item := range items {
+		locations[i] = item.location
+	}
+
+	return p.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := p.locationRepo.CreateBatch(ctx, locations); err != nil {
+			return err
+		}
+
+		events := make([]*domain.Event, 0, len(locations))
+		for _, location := range locations {
+			// skipped rows, their session no longer exists
+			if location.CreatedAt.IsZero() {
+				continue
+			}
+			events = append(events, newEvent(items[0].organizationID, domain.EventLocationRecorded, location))
+		}
+
+		return p.outboxRepo.AddBatch(ctx, events)
+	})
+}
@@ -2,22 +2,53 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/config"
 	"github.com/SarkiMudboy/easebox-api/internal/domain"
 	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
 )
 
+var errSessionNotFound = &domain.DomainError{Code: "SESSION_NOT_FOUND", Message: "session does not exist"}
+var errSessionConflict = &domain.DomainError{Code: "SESSION_CONFLICT", Message: "session was changed by another request, reload it and try again"}
+var errSessionInactive = &domain.DomainError{Code: "SESSION_INACTIVE", Message: "session has ended"}
+var errSessionPaused = &domain.DomainError{Code: "SESSION_PAUSED", Message: "cannot record location while the session is paused"}
+
+// how often a session update is retried after losing to a concurrent one
+const sessionUpdateAttempts = 3
+
 type LocationService struct {
 	locationRepo repository.LocationRepository
 	sessionRepo repository.SessionRepository
+	deliveryRepo repository.DeliveryRepository
+	tx repository.Transactor
+	// events are published in the same transaction as the change that raised them
+	events EventPublisher
+	// session and delivery changes are audited in the same transaction
+	audit *AuditLog
+	cfg *config.TrackingConfig
+	// batches location writes when set, see UsePipeline
+	pipeline *LocationPipeline
+	// session state reused across points, see UseSessionCache
+	sessions SessionCache
 }
 
-func NewLocationService(locationRepo repository.LocationRepository, sessionRepo repository.SessionRepository) *LocationService {
+func NewLocationService(locationRepo repository.LocationRepository, sessionRepo repository.SessionRepository, deliveryRepo repository.DeliveryRepository, tx repository.Transactor, events EventPublisher, audit *AuditLog, cfg *config.TrackingConfig) *LocationService {
 	return &LocationService{
 		locationRepo: locationRepo,
 		sessionRepo: sessionRepo,
+		deliveryRepo: deliveryRepo,
+		tx: tx,
+		events: events,
+		audit: audit,
+		cfg: cfg,
+		sessions: NewMemorySessionCache(cfg.SessionCacheTTL, cfg.SessionCacheSize),
 	}
 }
 
@@ -26,9 +57,9 @@ func (s *LocationService) RecordLocation(ctx context.Context, location *domain.L
 		return err
 	}
 
-	session, err := s.sessionRepo.GetByID(ctx, location.SessionID)
+	session, err := s.getSession(ctx, location.SessionID)
 	if err != nil {
-		return fmt.Errorf("session not found: %w", err)
+		return err
 	}
 
 	if !session.IsActive {
@@ -38,14 +69,120 @@ func (s *LocationService) RecordLocation(ctx context.Context, location *domain.L
 		}
 	}
 
-	if err := s.locationRepo.Create(ctx, location); err != nil {
-		return fmt.Errorf("failed to record location: %w", err)
+	if session.Paused() {
+		return errSessionPaused
+	}
+
+	if s.pipeline != nil {
+		return s.pipeline.Submit(ctx, session, location)
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.locationRepo.Create(ctx, location); err != nil {
+			return fmt.Errorf("failed to record location: %w", err)
+		}
+
+		return s.events.Publish(ctx, domain.EventLocationRecorded, location)
+	})
+	if err != nil {
+		return err
+	}
+
+	if session.DeliveryID != "" {
+		s.checkArrival(ctx, session, location)
 	}
 
 	return nil
 }
 
-func (s *LocationService) StartTracking(ctx context.Context, sessionID, deliveryID string) error {
+// UsePipeline makes RecordLocation queue points for batched writes instead of
+// inserting them one by one. Arrival checks run once a point's batch commits.
+func (s *LocationService) UsePipeline(pipeline *LocationPipeline) {
+	s.pipeline = pipeline
+	pipeline.afterFlush = func(ctx context.Context, item pipelineItem) {
+		if item.session.DeliveryID != "" {
+			s.checkArrival(ctx, item.session, item.location)
+		}
+	}
+}
+
+// UseSessionCache replaces the in-memory session cache, e.g. with one shared
+// between instances
+func (s *LocationService) UseSessionCache(cache SessionCache) {
+	s.sessions = cache
+}
+
+// InvalidateSession drops cached state of a session changed outside this
+// service, such as by another instance
+func (s *LocationService) InvalidateSession(ctx context.Context, sessionID string) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.sessions.Invalidate(ctx, organizationID, sessionID)
+	return nil
+}
+
+// FollowSessionChanges invalidates cached sessions as any instance changes
+// them, until ctx is cancelled
+func (s *LocationService) FollowSessionChanges(ctx context.Context, changes SessionChanges) error {
+	changed := func(organizationID, sessionID string) {
+		s.InvalidateSession(tenant.WithOrganization(ctx, organizationID), sessionID)
+	}
+	missed := func() {
+		s.sessions.Clear(ctx)
+	}
+
+	return changes.Listen(ctx, changed, missed)
+}
+
+// getSession loads a session for the location hot path, from the cache when possible
+func (s *LocationService) getSession(ctx context.Context, sessionID string) (*domain.TrackingSession, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := s.sessions.Get(ctx, organizationID, sessionID)
+	if !ok {
+		since := s.sessions.Generation(ctx)
+		session, err = s.sessionRepo.GetByID(ctx, sessionID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errSessionNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("session not found: %w", err)
+		}
+
+		s.sessions.Set(ctx, organizationID, session, since)
+	}
+
+	if !sessionVisible(ctx, session) {
+		return nil, errSessionNotFound
+	}
+	return session, nil
+}
+
+// sessionVisible hides other riders' sessions from a rider's app. Api keys
+// and the server itself act on any session of their organization.
+func sessionVisible(ctx context.Context, session *domain.TrackingSession) bool {
+	a := actor.FromContext(ctx)
+	if a.Type != domain.ActorRiderApp {
+		return true
+	}
+	return session.RiderID != nil && *session.RiderID == a.ID
+}
+
+func (s *LocationService) StartTracking(ctx context.Context, sessionID, deliveryID, riderID string) error {
+	// a rider's app always tracks for its own rider
+	if a := actor.FromContext(ctx); a.Type == domain.ActorRiderApp {
+		if riderID != "" && riderID != a.ID {
+			return &domain.DomainError{Code: "RIDER_MISMATCH", Message: "cannot track for another rider"}
+		}
+		riderID = a.ID
+	}
+
 	session := &domain.TrackingSession{
 		SessionID: sessionID,
 		DeliveryID: deliveryID,
@@ -53,26 +190,423 @@ func (s *LocationService) StartTracking(ctx context.Context, sessionID, delivery
 		IsActive: true,
 	}
 
-	return s.sessionRepo.Create(ctx, session)
+	if riderID != "" {
+		session.RiderID = &riderID
+	}
+
+	if deliveryID != "" {
+		delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+		}
+		if err != nil {
+			return err
+		}
+
+		assigned, err := s.assignedTo(ctx, delivery, session)
+		if err != nil {
+			return err
+		}
+		if !assigned {
+			return &domain.DomainError{Code: "DELIVERY_NOT_ASSIGNED", Message: "delivery is not assigned to this session or rider"}
+		}
+	}
+
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.sessionRepo.Create(ctx, session)
+		if errors.Is(err, repository.ErrNotFound) {
+			return &domain.DomainError{Code: "RIDER_NOT_FOUND", Message: "rider does not exist"}
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, domain.AuditSessionStart, domain.AuditEntitySession, sessionID, nil, session); err != nil {
+			return err
+		}
+
+		return s.events.Publish(ctx, domain.EventSessionStarted, sessionEventData(session))
+	})
 }
 
 func (s *LocationService) StopTracking(ctx context.Context, sessionID string) error {
-	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	return s.stopSession(ctx, sessionID, time.Now())
+}
+
+// ReapSession stops a session whose rider went silent, ending it at the last
+// time it was heard from rather than now
+func (s *LocationService) ReapSession(ctx context.Context, sessionID string, lastSeenAt time.Time) error {
+	return s.stopSession(ctx, sessionID, lastSeenAt)
+}
+
+func (s *LocationService) stopSession(ctx context.Context, sessionID string, endTime time.Time) error {
+	session, err := s.changeSession(ctx, sessionID, domain.AuditSessionStop, domain.EventSessionStopped, func(ctx context.Context, session *domain.TrackingSession) error {
+		// stopping again would overwrite the end time and its fare
+		if !session.IsActive {
+			return errSessionInactive
+		}
+
+		if session.Paused() {
+			if err := s.sessionRepo.ClosePause(ctx, sessionID, endTime); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			session.PausedAt = nil
+		}
+
+		session.EndTime = &endTime
+		session.IsActive = false
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	now := time.Now()
-	session.EndTime = &now
-	session.IsActive = false
+	if session.DeliveryID != "" {
+		s.completeDelivery(ctx, session)
+	}
+
+	return nil
+}
+
+// PauseTracking suspends an active session, e.g. while the rider is on a
+// break. Points are rejected and time is not billed until it is resumed.
+func (s *LocationService) PauseTracking(ctx context.Context, sessionID string) error {
+	_, err := s.changeSession(ctx, sessionID, domain.AuditSessionPause, domain.EventSessionPaused, func(ctx context.Context, session *domain.TrackingSession) error {
+		if !session.IsActive {
+			return errSessionInactive
+		}
+		if session.Paused() {
+			return &domain.DomainError{Code: "SESSION_ALREADY_PAUSED", Message: "session is already paused"}
+		}
+
+		now := time.Now()
+		if err := s.sessionRepo.OpenPause(ctx, sessionID, now); err != nil {
+			return err
+		}
+
+		session.PausedAt = &now
+		return nil
+	})
+	return err
+}
+
+func (s *LocationService) ResumeTracking(ctx context.Context, sessionID string) error {
+	_, err := s.changeSession(ctx, sessionID, domain.AuditSessionResume, domain.EventSessionResumed, func(ctx context.Context, session *domain.TrackingSession) error {
+		if !session.IsActive {
+			return errSessionInactive
+		}
+		if !session.Paused() {
+			return &domain.DomainError{Code: "SESSION_NOT_PAUSED", Message: "session is not paused"}
+		}
 
-	return s.sessionRepo.Update(ctx, session)
+		if err := s.sessionRepo.ClosePause(ctx, sessionID, time.Now()); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		session.PausedAt = nil
+		return nil
+	})
+	return err
+}
+
+// changeSession applies change to the latest state of the session and saves
+// it, audited and with an event, in one transaction. When a concurrent update
+// wins the session is reloaded and change runs again.
+func (s *LocationService) changeSession(ctx context.Context, sessionID, action, eventType string, change func(ctx context.Context, session *domain.TrackingSession) error) (*domain.TrackingSession, error) {
+	if organizationID, ok := tenant.FromContext(ctx); ok {
+		defer s.sessions.Invalidate(ctx, organizationID, sessionID)
+	}
+
+	for attempt := 1; ; attempt++ {
+		session, err := s.sessionRepo.GetByID(ctx, sessionID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errSessionNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !sessionVisible(ctx, session) {
+			return nil, errSessionNotFound
+		}
+
+		before := *session
+
+		err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := change(ctx, session); err != nil {
+				return err
+			}
+
+			if err := s.sessionRepo.Update(ctx, session); err != nil {
+				return err
+			}
+
+			if err := s.audit.Record(ctx, action, domain.AuditEntitySession, sessionID, &before, session); err != nil {
+				return err
+			}
+
+			return s.events.Publish(ctx, eventType, sessionEventData(session))
+		})
+		if errors.Is(err, repository.ErrConflict) {
+			if attempt == sessionUpdateAttempts {
+				return nil, errSessionConflict
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return session, nil
+	}
+}
+
+// GetSessionStats sums up the session's route. Paused time is excluded from
+// the active duration.
+func (s *LocationService) GetSessionStats(ctx context.Context, sessionID string) (*domain.SessionStats, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := s.locationRepo.GetBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pauses, err := s.sessionRepo.ListPauses(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionStats(session, locations, pauses, time.Now()), nil
+}
+
+func sessionStats(session *domain.TrackingSession, locations []*domain.LocationUpdate, pauses []*domain.SessionPause, now time.Time) *domain.SessionStats {
+	end := now
+	if session.EndTime != nil {
+		end = *session.EndTime
+	}
+
+	stats := &domain.SessionStats{
+		SessionID:  session.SessionID,
+		IsActive:   session.IsActive,
+		Paused:     session.Paused(),
+		PointCount: len(locations),
+		Pauses:     make([]domain.SessionPause, 0, len(pauses)),
+	}
+
+	// the trace is split at every pause, the rider's move between the last
+	// point before a pause and the first one after it is not part of the trip
+	segment := make([]geo.Point, 0, len(locations))
+	for i, location := range locations {
+		if i > 0 && pausedBetween(pauses, locations[i-1].RecordedAt, location.RecordedAt) {
+			stats.DistanceMeters += geo.PathLength(segment)
+			segment = segment[:0]
+		}
+		segment = append(segment, geo.Point{Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	stats.DistanceMeters += geo.PathLength(segment)
+
+	elapsed := end.Sub(session.StartTime)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	var paused time.Duration
+	for _, pause := range pauses {
+		stats.Pauses = append(stats.Pauses, *pause)
+
+		// only the part of a pause inside the session counts
+		from, to := pause.StartedAt, end
+		if pause.EndedAt != nil && pause.EndedAt.Before(end) {
+			to = *pause.EndedAt
+		}
+		if from.Before(session.StartTime) {
+			from = session.StartTime
+		}
+		if to.After(from) {
+			paused += to.Sub(from)
+		}
+	}
+	paused = min(paused, elapsed)
+
+	stats.ElapsedSeconds = elapsed.Seconds()
+	stats.PausedSeconds = paused.Seconds()
+	stats.ActiveSeconds = (elapsed - paused).Seconds()
+	if stats.ActiveSeconds > 0 {
+		stats.AverageSpeed = stats.DistanceMeters / stats.ActiveSeconds
+	}
+
+	return stats
+}
+
+// pausedBetween reports whether a pause started after from and no later than to
+func pausedBetween(pauses []*domain.SessionPause, from, to time.Time) bool {
+	for _, pause := range pauses {
+		if pause.StartedAt.After(from) && !pause.StartedAt.After(to) {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *LocationService) GetSessionRoute(ctx context.Context, sessionID string) ([]*domain.LocationUpdate, error) {
 	return s.locationRepo.GetBySessionID(ctx, sessionID)
 }
 
+// assignedTo reports whether the delivery is assigned to the session, or to
+// another session of the same rider, e.g. one started before an app restart
+func (s *LocationService) assignedTo(ctx context.Context, delivery *domain.Delivery, session *domain.TrackingSession) (bool, error) {
+	if delivery.AssignedSessionID == nil {
+		return false, nil
+	}
+	if *delivery.AssignedSessionID == session.SessionID {
+		return true, nil
+	}
+	if session.RiderID == nil {
+		return false, nil
+	}
+
+	assigned, err := s.sessionRepo.GetByID(ctx, *delivery.AssignedSessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return assigned.RiderID != nil && *assigned.RiderID == *session.RiderID, nil
+}
+
+// checkArrival moves the session's delivery along as the rider reaches the pickup
+// and then the drop-off. Failures are logged, they must not reject the location.
+func (s *LocationService) checkArrival(ctx context.Context, session *domain.TrackingSession, location *domain.LocationUpdate) {
+	sessionID, deliveryID := session.SessionID, session.DeliveryID
+
+	delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load delivery %s for arrival check: %v", deliveryID, err)
+		return
+	}
+
+	// the delivery may have been reassigned since tracking started
+	assigned, err := s.assignedTo(ctx, delivery, session)
+	if err != nil {
+		log.Printf("Failed to check assignment of delivery %s: %v", deliveryID, err)
+		return
+	}
+	if !assigned {
+		return
+	}
+
+	position := geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+	coordinate := &domain.Coordinate{Latitude: location.Latitude, Longitude: location.Longitude}
+	before := *delivery
+
+	var eventType string
+	switch {
+	case delivery.Status == domain.DeliveryAssigned && s.within(position, delivery.Pickup):
+		delivery.Status = domain.DeliveryInTransit
+		delivery.PickedUpAt = &location.RecordedAt
+		eventType = domain.EventRiderArrivedAtPickup
+	case delivery.Status == domain.DeliveryInTransit && delivery.ArrivedAtDropoff == nil && s.within(position, delivery.Dropoff):
+		delivery.ArrivedAtDropoff = &location.RecordedAt
+		eventType = domain.EventRiderArrivedAtDropoff
+	default:
+		return
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.deliveryRepo.Update(ctx, delivery); err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, domain.AuditDeliveryUpdate, domain.AuditEntityDelivery, delivery.ID, &before, delivery); err != nil {
+			return err
+		}
+
+		return s.events.Publish(ctx, eventType, domain.DeliveryEventData{
+			DeliveryID: delivery.ID,
+			SessionID:  sessionID,
+			Status:     delivery.Status,
+			Location:   coordinate,
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to update delivery %s on arrival: %v", deliveryID, err)
+	}
+}
+
+// completeDelivery marks the session's delivery delivered when the rider stops
+// tracking after reaching the drop-off
+func (s *LocationService) completeDelivery(ctx context.Context, session *domain.TrackingSession) {
+	delivery, err := s.deliveryRepo.GetByID(ctx, session.DeliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load delivery %s for completion: %v", session.DeliveryID, err)
+		return
+	}
+
+	if delivery.Status != domain.DeliveryInTransit || delivery.ArrivedAtDropoff == nil {
+		return
+	}
+
+	assigned, err := s.assignedTo(ctx, delivery, session)
+	if err != nil {
+		log.Printf("Failed to check assignment of delivery %s: %v", session.DeliveryID, err)
+		return
+	}
+	if !assigned {
+		return
+	}
+
+	before := *delivery
+	delivery.Status = domain.DeliveryDelivered
+	delivery.DeliveredAt = session.EndTime
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.deliveryRepo.Update(ctx, delivery); err != nil {
+			return err
+		}
+
+		if err := s.audit.Record(ctx, domain.AuditDeliveryUpdate, domain.AuditEntityDelivery, delivery.ID, &before, delivery); err != nil {
+			return err
+		}
+
+		return s.events.Publish(ctx, domain.EventDeliveryCompleted, domain.DeliveryEventData{
+			DeliveryID: delivery.ID,
+			SessionID:  session.SessionID,
+			Status:     delivery.Status,
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to complete delivery %s: %v", delivery.ID, err)
+	}
+}
+
+func (s *LocationService) within(position geo.Point, target domain.Coordinate) bool {
+	return geo.Haversine(position, geo.Point{Latitude: target.Latitude, Longitude: target.Longitude}) <= s.cfg.ArrivalRadius
+}
+
+func sessionEventData(session *domain.TrackingSession) domain.SessionEventData {
+	return domain.SessionEventData{
+		SessionID:  session.SessionID,
+		DeliveryID: session.DeliveryID,
+		RiderID:    session.RiderID,
+		StartTime:  session.StartTime,
+		EndTime:    session.EndTime,
+		PausedAt:   session.PausedAt,
+	}
+}
+
 func (s *LocationService) validateLocation (location *domain.LocationUpdate) error {
 	if location.Latitude < -90 || location.Latitude > 90 {
 		return &domain.DomainError{Code: "INVALID_LATITUDE", Message: "Latitude must be between -90 and 90"}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/mapmatch"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+type MatchingService struct {
+	locationRepo repository.LocationRepository
+	matcher      *mapmatch.Matcher
+}
+
+// NewMatchingService creates the map matching service. matcher may be nil when no
+// road graph is configured, in which case every request fails with MAP_MATCHING_DISABLED.
+func NewMatchingService(locationRepo repository.LocationRepository, matcher *mapmatch.Matcher) *MatchingService {
+	return &MatchingService{
+		locationRepo: locationRepo,
+		matcher:      matcher,
+	}
+}
+
+func (s *MatchingService) MatchSession(ctx context.Context, sessionID string) (*domain.MatchedRoute, error) {
+	if s.matcher == nil {
+		return nil, &domain.DomainError{Code: "MAP_MATCHING_DISABLED", Message: "no road graph is configured"}
+	}
+
+	locations, err := s.locationRepo.GetBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(locations) < 2 {
+		return nil, &domain.DomainError{Code: "INSUFFICIENT_POINTS", Message: "at least two location updates are required to match a route"}
+	}
+
+	trace := make([]geo.Point, len(locations))
+	for i, loc := range locations {
+		trace[i] = geo.Point{Latitude: loc.Latitude, Longitude: loc.Longitude}
+	}
+
+	result, err := s.matcher.Match(trace)
+	if errors.Is(err, mapmatch.ErrNoMatch) {
+		return nil, &domain.DomainError{Code: "NO_ROAD_MATCH", Message: err.Error()}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to match route: %w", err)
+	}
+
+	route := &domain.MatchedRoute{
+		SessionID:    sessionID,
+		RoadDistance: result.Distance,
+		RawDistance:  geo.PathLength(trace),
+		Breaks:       result.Breaks,
+	}
+
+	for _, p := range result.Points {
+		route.Points = append(route.Points, domain.MatchedPoint{
+			LocationID: locations[p.Index].ID,
+			Original:   toCoordinate(p.Original),
+			Snapped:    toCoordinate(p.Snapped),
+			WayID:      p.WayID,
+			RoadName:   p.RoadName,
+		})
+	}
+
+	for _, p := range result.Geometry {
+		route.Geometry = append(route.Geometry, toCoordinate(p))
+	}
+
+	return route, nil
+}
+
+func toCoordinate(p geo.Point) domain.Coordinate {
+	return domain.Coordinate{Latitude: p.Latitude, Longitude: p.Longitude}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+type OrganizationService struct {
+	organizationRepo repository.OrganizationRepository
+}
+
+func NewOrganizationService(organizationRepo repository.OrganizationRepository) *OrganizationService {
+	return &OrganizationService{organizationRepo: organizationRepo}
+}
+
+func (s *OrganizationService) CreateOrganization(ctx context.Context, name string) (*domain.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &domain.DomainError{Code: "INVALID_ORGANIZATION_NAME", Message: "name is required"}
+	}
+
+	organization := &domain.Organization{Name: name}
+	if err := s.organizationRepo.Create(ctx, organization); err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (s *OrganizationService) GetOrganization(ctx context.Context, organizationID string) (*domain.Organization, error) {
+	organization, err := s.organizationRepo.GetByID(ctx, organizationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "ORGANIZATION_NOT_FOUND", Message: "organization does not exist"}
+	}
+	return organization, err
+}
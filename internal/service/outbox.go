@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+// OutboxPublisher implements EventPublisher by writing events to the outbox.
+// Called inside a transaction the event is only stored if the transaction commits.
+type OutboxPublisher struct {
+	outboxRepo repository.OutboxRepository
+}
+
+func NewOutboxPublisher(outboxRepo repository.OutboxRepository) *OutboxPublisher {
+	return &OutboxPublisher{outboxRepo: outboxRepo}
+}
+
+func (p *OutboxPublisher) Publish(ctx context.Context, eventType string, data interface{}) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return p.outboxRepo.Add(ctx, newEvent(organizationID, eventType, data))
+}
+
+func newEvent(organizationID, eventType string, data interface{}) *domain.Event {
+	return &domain.Event{
+		ID:             newEventID(),
+		Type:           eventType,
+		OrganizationID: organizationID,
+		OccurredAt:     time.Now().UTC(),
+		Data:           data,
+	}
+}
+
+// EventSink receives events relayed from the outbox. Delivery is at least once,
+// so sinks may see the same event ID more than once.
+type EventSink interface {
+	Name() string
+	Publish(ctx context.Context, event *domain.Event) error
+}
+
+// OutboxRelay publishes stored events to every sink. A sink that failed gets
+// the event again with exponential backoff, the others do not; after the last
+// attempt the sinks still failing get a dead letter.
+type OutboxRelay struct {
+	outboxRepo repository.OutboxRepository
+	sinks      []EventSink
+	cfg        *config.OutboxConfig
+}
+
+func NewOutboxRelay(outboxRepo repository.OutboxRepository, cfg *config.OutboxConfig, sinks ...EventSink) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		sinks:      sinks,
+		cfg:        cfg,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+
+	for {
+		drain("OUTBOX", r.cfg.BatchSize, func() (int, error) { return r.RelayPending(ctx) })
+
+		if time.Since(lastPrune) > time.Hour {
+			if pruned, err := r.outboxRepo.DeletePublishedBefore(ctx, time.Now().Add(-r.cfg.Retention)); err != nil {
+				log.Printf("[OUTBOX] %v", err)
+			} else if pruned > 0 {
+				log.Printf("[OUTBOX] pruned %d published events", pruned)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RelayPending publishes one batch of due events and returns how many were handled
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	// long enough that a slow sink does not let another relay claim the batch
+	lease := r.cfg.PollInterval*time.Duration(r.cfg.BatchSize) + time.Minute
+
+	events, err := r.outboxRepo.ClaimUnpublished(ctx, r.cfg.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		r.relay(ctx, event)
+	}
+
+	return len(events), nil
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, event *domain.OutboxEvent) {
+	// the sinks act on behalf of the organization that raised the event
+	ctx = tenant.WithOrganization(ctx, event.OrganizationID)
+
+	delivered := slices.Clone(event.DeliveredSinks)
+	failures := make(map[string]string)
+
+	for _, sink := range r.sinks {
+		if slices.Contains(event.DeliveredSinks, sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(ctx, event.Event); err != nil {
+			failures[sink.Name()] = err.Error()
+			continue
+		}
+		delivered = append(delivered, sink.Name())
+	}
+
+	attempt := event.Attempts + 1
+
+	var err error
+	switch {
+	case len(failures) == 0:
+		err = r.outboxRepo.MarkPublished(ctx, event.ID)
+	case attempt >= r.cfg.MaxAttempts:
+		log.Printf("[OUTBOX] event %s (%s) dead lettered after %d attempts: %v", event.ID, event.Type, attempt, failures)
+		err = r.outboxRepo.DeadLetter(ctx, event.ID, failures)
+	default:
+		log.Printf("[OUTBOX] event %s (%s) failed: %v", event.ID, event.Type, failures)
+		next := time.Now().Add(backoff(r.cfg.BaseBackoff, r.cfg.MaxBackoff, attempt))
+		err = r.outboxRepo.ScheduleRetry(ctx, event.ID, delivered, next, fmt.Sprint(failures))
+	}
+
+	if err != nil {
+		log.Printf("[OUTBOX] %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+// PartitionMaintainer keeps location_updates partitioned by month: it creates
+// the upcoming months ahead of time and detaches months past retention.
+// Detached partitions stay in the database as plain tables, their points are
+// no longer read or written; dropping them is left to the operator.
+type PartitionMaintainer struct {
+	partitionRepo repository.PartitionRepository
+	cfg           *config.PartitionConfig
+
+	// one run at a time, the ticker and the admin endpoint may overlap
+	runMu sync.Mutex
+
+	mu        sync.Mutex
+	lastRunAt *time.Time
+	lastError string
+	created   []string
+	detached  []string
+}
+
+func NewPartitionMaintainer(partitionRepo repository.PartitionRepository, cfg *config.PartitionConfig) *PartitionMaintainer {
+	return &PartitionMaintainer{partitionRepo: partitionRepo, cfg: cfg}
+}
+
+// Run maintains the partitions until ctx is cancelled
+func (m *PartitionMaintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Maintain(ctx); err != nil {
+			log.Printf("[PARTITION] %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Maintain runs one pass. It carries on past a failed partition and returns
+// the errors it met.
+func (m *PartitionMaintainer) Maintain(ctx context.Context) error {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var created, detached []string
+	var errs []error
+
+	partitions, err := m.partitionRepo.ListLocationPartitions(ctx)
+	if err != nil {
+		m.record(now, nil, nil, err)
+		return err
+	}
+
+	existing := make(map[time.Time]bool, len(partitions))
+	for _, partition := range partitions {
+		if partition.From != nil {
+			existing[*partition.From] = true
+		}
+	}
+
+	for i := 0; i <= m.cfg.PremakeMonths; i++ {
+		month := currentMonth.AddDate(0, i, 0)
+		if existing[month] {
+			continue
+		}
+
+		name, err := m.partitionRepo.CreateLocationPartition(ctx, month)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		created = append(created, name)
+	}
+
+	if m.cfg.RetainMonths > 0 {
+		cutoff := currentMonth.AddDate(0, -m.cfg.RetainMonths, 0)
+
+		for _, partition := range partitions {
+			if !partition.Attached || partition.To == nil || partition.To.After(cutoff) {
+				continue
+			}
+
+			if err := m.partitionRepo.DetachLocationPartition(ctx, partition.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			detached = append(detached, partition.Name)
+		}
+	}
+
+	for _, name := range created {
+		log.Printf("[PARTITION] created %s", name)
+	}
+	for _, name := range detached {
+		log.Printf("[PARTITION] detached %s", name)
+	}
+
+	err = errors.Join(errs...)
+	m.record(now, created, detached, err)
+	return err
+}
+
+func (m *PartitionMaintainer) record(at time.Time, created, detached []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastRunAt = &at
+	m.created = created
+	m.detached = detached
+	m.lastError = ""
+	if err != nil {
+		m.lastError = err.Error()
+	}
+}
+
+// Status returns the outcome of the last run with the current partitions
+func (m *PartitionMaintainer) Status(ctx context.Context) (*domain.PartitionStatus, error) {
+	partitions, err := m.partitionRepo.ListLocationPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := &domain.PartitionStatus{
+		LastRunAt:  m.lastRunAt,
+		LastError:  m.lastError,
+		Created:    append([]string{}, m.created...),
+		Detached:   append([]string{}, m.detached...),
+		Partitions: partitions,
+	}
+	if status.Partitions == nil {
+		status.Partitions = []*domain.Partition{}
+	}
+
+	return status, nil
+}
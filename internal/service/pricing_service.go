@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+var errRateCardNotFound = &domain.DomainError{Code: "RATE_CARD_NOT_FOUND", Message: "no rate card is set for this vehicle type"}
+var errSurgeZoneNotFound = &domain.DomainError{Code: "SURGE_ZONE_NOT_FOUND", Message: "surge zone does not exist"}
+
+// surge multipliers are capped so a typo cannot price trips at 100x
+const maxSurgeMultiplier = 10
+
+type PricingService struct {
+	pricingRepo  repository.PricingRepository
+	sessionRepo  repository.SessionRepository
+	locationRepo repository.LocationRepository
+	riderRepo    repository.RiderRepository
+	deliveryRepo repository.DeliveryRepository
+	cfg          *config.PricingConfig
+}
+
+func NewPricingService(pricingRepo repository.PricingRepository, sessionRepo repository.SessionRepository, locationRepo repository.LocationRepository, riderRepo repository.RiderRepository, deliveryRepo repository.DeliveryRepository, cfg *config.PricingConfig) *PricingService {
+	return &PricingService{
+		pricingRepo:  pricingRepo,
+		sessionRepo:  sessionRepo,
+		locationRepo: locationRepo,
+		riderRepo:    riderRepo,
+		deliveryRepo: deliveryRepo,
+		cfg:          cfg,
+	}
+}
+
+// Quote estimates the fare of a trip before it starts. The distance is the
+// straight line between pickup and drop-off scaled by the route factor.
+func (s *PricingService) Quote(ctx context.Context, pickup, dropoff domain.Coordinate, vehicleType domain.VehicleType) (*domain.Fare, error) {
+	if err := validateCoordinate(pickup); err != nil {
+		return nil, err
+	}
+	if err := validateCoordinate(dropoff); err != nil {
+		return nil, err
+	}
+
+	if vehicleType == "" {
+		vehicleType = domain.VehicleType(s.cfg.DefaultVehicle)
+	}
+	if !vehicleType.Valid() {
+		return nil, &domain.DomainError{Code: "INVALID_VEHICLE_TYPE", Message: "vehicleType must be bicycle, motorcycle, car or van"}
+	}
+
+	card, err := s.rateCard(ctx, vehicleType)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	surge, err := s.surgeAt(ctx, pickup, vehicleType, now)
+	if err != nil {
+		return nil, err
+	}
+
+	distance := geo.Haversine(
+		geo.Point{Latitude: pickup.Latitude, Longitude: pickup.Longitude},
+		geo.Point{Latitude: dropoff.Latitude, Longitude: dropoff.Longitude},
+	) * s.cfg.RouteFactor
+
+	var duration float64
+	if s.cfg.AverageSpeed > 0 {
+		duration = distance / s.cfg.AverageSpeed
+	}
+
+	fare := &domain.Fare{
+		Kind:            domain.FareQuote,
+		DistanceMeters:  distance,
+		DurationSeconds: duration,
+		ComputedAt:      now,
+	}
+	priceTrip(fare, card, surge)
+
+	return fare, nil
+}
+
+// FinalizeFare prices a stopped session from its recorded route with the rate
+// card in effect when it stopped. Paused time is not billed. The first fare
+// stored is final, a later computation returns it instead.
+func (s *PricingService) FinalizeFare(ctx context.Context, sessionID string) (*domain.Fare, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.IsActive {
+		return nil, &domain.DomainError{Code: "SESSION_STILL_ACTIVE", Message: "fare is final once the session has stopped"}
+	}
+
+	locations, err := s.locationRepo.GetBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pauses, err := s.sessionRepo.ListPauses(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := sessionStats(session, locations, pauses, time.Now())
+
+	vehicleType, err := s.sessionVehicle(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	stoppedAt := time.Now()
+	if session.EndTime != nil {
+		stoppedAt = *session.EndTime
+	}
+
+	card, err := s.pricingRepo.GetRateCardAt(ctx, vehicleType, stoppedAt)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRateCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// surge is judged where and when the trip started, as it would have been quoted
+	var surge *domain.SurgeZone
+	if start, ok, err := s.tripStart(ctx, session, locations); err != nil {
+		return nil, err
+	} else if ok {
+		if surge, err = s.surgeAt(ctx, start, vehicleType, session.StartTime); err != nil {
+			return nil, err
+		}
+	}
+
+	fare := &domain.Fare{
+		Kind:            domain.FareFinal,
+		SessionID:       session.SessionID,
+		DeliveryID:      session.DeliveryID,
+		DistanceMeters:  stats.DistanceMeters,
+		DurationSeconds: stats.ActiveSeconds,
+		PausedSeconds:   stats.PausedSeconds,
+		ComputedAt:      time.Now().UTC(),
+	}
+	priceTrip(fare, card, surge)
+
+	err = s.pricingRepo.SaveFare(ctx, fare)
+	if errors.Is(err, repository.ErrConflict) {
+		return s.pricingRepo.GetFare(ctx, sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fare, nil
+}
+
+// GetSessionFare returns the session's final fare. It is computed when the
+// session stops; should that have failed it is computed now.
+func (s *PricingService) GetSessionFare(ctx context.Context, sessionID string) (*domain.Fare, error) {
+	fare, err := s.pricingRepo.GetFare(ctx, sessionID)
+	if err == nil {
+		return fare, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	fare, err = s.FinalizeFare(ctx, sessionID)
+	var domainErr *domain.DomainError
+	if errors.As(err, &domainErr) && domainErr.Code == "SESSION_STILL_ACTIVE" {
+		return nil, &domain.DomainError{Code: "FARE_NOT_FOUND", Message: "session has not stopped, request a quote instead"}
+	}
+
+	return fare, err
+}
+
+func (s *PricingService) ListRateCards(ctx context.Context) ([]*domain.RateCard, error) {
+	return s.pricingRepo.ListRateCards(ctx)
+}
+
+// SaveRateCard creates or replaces the rate card of card.VehicleType
+func (s *PricingService) SaveRateCard(ctx context.Context, card *domain.RateCard) error {
+	if !card.VehicleType.Valid() {
+		return &domain.DomainError{Code: "INVALID_VEHICLE_TYPE", Message: "vehicleType must be bicycle, motorcycle, car or van"}
+	}
+
+	card.Currency = strings.ToUpper(card.Currency)
+	if len(card.Currency) != 3 || strings.Trim(card.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return &domain.DomainError{Code: "INVALID_CURRENCY", Message: "currency must be a three letter ISO 4217 code"}
+	}
+
+	if card.BaseFare < 0 || card.PerKm < 0 || card.PerMinute < 0 || card.MinimumFare < 0 {
+		return &domain.DomainError{Code: "INVALID_RATE", Message: "amounts cannot be negative"}
+	}
+
+	return s.pricingRepo.SaveRateCard(ctx, card)
+}
+
+func (s *PricingService) DeleteRateCard(ctx context.Context, vehicleType domain.VehicleType) error {
+	err := s.pricingRepo.DeleteRateCard(ctx, vehicleType)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errRateCardNotFound
+	}
+	return err
+}
+
+func (s *PricingService) ListSurgeZones(ctx context.Context) ([]*domain.SurgeZone, error) {
+	return s.pricingRepo.ListSurgeZones(ctx)
+}
+
+func (s *PricingService) CreateSurgeZone(ctx context.Context, zone *domain.SurgeZone) error {
+	zone.Name = strings.TrimSpace(zone.Name)
+	if zone.Name == "" {
+		return &domain.DomainError{Code: "MISSING_NAME", Message: "surge zone name is required"}
+	}
+
+	if zone.VehicleType != nil && !zone.VehicleType.Valid() {
+		return &domain.DomainError{Code: "INVALID_VEHICLE_TYPE", Message: "vehicleType must be bicycle, motorcycle, car or van"}
+	}
+
+	if err := validateCoordinate(zone.Center); err != nil {
+		return err
+	}
+
+	if zone.RadiusMeters <= 0 {
+		return &domain.DomainError{Code: "INVALID_RADIUS", Message: "radiusMeters must be positive"}
+	}
+
+	if zone.Multiplier < 1 || zone.Multiplier > maxSurgeMultiplier {
+		return &domain.DomainError{Code: "INVALID_MULTIPLIER", Message: fmt.Sprintf("multiplier must be between 1 and %d", maxSurgeMultiplier)}
+	}
+
+	if zone.StartsAt != nil && zone.EndsAt != nil && !zone.EndsAt.After(*zone.StartsAt) {
+		return &domain.DomainError{Code: "INVALID_RANGE", Message: "endsAt must be after startsAt"}
+	}
+
+	return s.pricingRepo.CreateSurgeZone(ctx, zone)
+}
+
+func (s *PricingService) DeleteSurgeZone(ctx context.Context, zoneID string) error {
+	err := s.pricingRepo.DeleteSurgeZone(ctx, zoneID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errSurgeZoneNotFound
+	}
+	return err
+}
+
+// Name and Publish let the outbox relay finalize fares as sessions stop
+func (s *PricingService) Name() string { return "pricing" }
+
+// Publish never fails: an error would make the relay deliver the event to
+// every sink again. A fare that could not be computed here is computed when
+// it is first requested.
+func (s *PricingService) Publish(ctx context.Context, event *domain.Event) error {
+	if event.Type != domain.EventSessionStopped {
+		return nil
+	}
+
+	var data domain.SessionEventData
+	if err := decodeEventData(event, &data); err != nil {
+		log.Printf("[PRICING] could not read %s: %v", event.ID, err)
+		return nil
+	}
+
+	if _, err := s.FinalizeFare(ctx, data.SessionID); err != nil {
+		log.Printf("[PRICING] could not price session %s: %v", data.SessionID, err)
+	}
+
+	return nil
+}
+
+func (s *PricingService) rateCard(ctx context.Context, vehicleType domain.VehicleType) (*domain.RateCard, error) {
+	card, err := s.pricingRepo.GetRateCard(ctx, vehicleType)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRateCardNotFound
+	}
+	return card, err
+}
+
+// surgeAt returns the zone with the highest multiplier covering point, or nil
+func (s *PricingService) surgeAt(ctx context.Context, point domain.Coordinate, vehicleType domain.VehicleType, at time.Time) (*domain.SurgeZone, error) {
+	zones, err := s.pricingRepo.FindSurgeZones(ctx, point, vehicleType, at)
+	if err != nil || len(zones) == 0 {
+		return nil, err
+	}
+	return zones[0], nil
+}
+
+// sessionVehicle is the vehicle type of the session's rider
+func (s *PricingService) sessionVehicle(ctx context.Context, session *domain.TrackingSession) (domain.VehicleType, error) {
+	vehicleType := domain.VehicleType(s.cfg.DefaultVehicle)
+	if session.RiderID == nil {
+		return vehicleType, nil
+	}
+
+	rider, err := s.riderRepo.GetByID(ctx, *session.RiderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return vehicleType, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if rider.VehicleType.Valid() {
+		vehicleType = rider.VehicleType
+	}
+	return vehicleType, nil
+}
+
+// tripStart is the delivery's pickup, or the first recorded position of a
+// session without one
+func (s *PricingService) tripStart(ctx context.Context, session *domain.TrackingSession, locations []*domain.LocationUpdate) (domain.Coordinate, bool, error) {
+	if session.DeliveryID != "" {
+		delivery, err := s.deliveryRepo.GetByID(ctx, session.DeliveryID)
+		if err == nil {
+			return delivery.Pickup, true, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return domain.Coordinate{}, false, err
+		}
+	}
+
+	if len(locations) == 0 {
+		return domain.Coordinate{}, false, nil
+	}
+	return domain.Coordinate{Latitude: locations[0].Latitude, Longitude: locations[0].Longitude}, true, nil
+}
+
+// priceTrip fills in the fare's breakdown from the rate card. Every line is
+// rounded to a minor unit so the lines always add up to the total.
+func priceTrip(fare *domain.Fare, card *domain.RateCard, surge *domain.SurgeZone) {
+	km := fare.DistanceMeters / 1000
+	minutes := fare.DurationSeconds / 60
+
+	fare.VehicleType = card.VehicleType
+	fare.Currency = card.Currency
+	fare.SurgeMultiplier = 1
+	fare.Lines = []domain.FareLine{
+		{Code: "base", Description: "Base fare", Amount: card.BaseFare},
+		{Code: "distance", Description: "Distance", Quantity: roundTo(km, 3), UnitPrice: card.PerKm, Amount: int64(math.Round(km * float64(card.PerKm)))},
+		{Code: "time", Description: "Time", Quantity: roundTo(minutes, 2), UnitPrice: card.PerMinute, Amount: int64(math.Round(minutes * float64(card.PerMinute)))},
+	}
+
+	var subtotal int64
+	for _, line := range fare.Lines {
+		subtotal += line.Amount
+	}
+
+	if surge != nil && surge.Multiplier > 1 {
+		fare.SurgeMultiplier = surge.Multiplier
+		fare.SurgeZoneID = &surge.ID
+
+		amount := int64(math.Round(float64(subtotal) * (surge.Multiplier - 1)))
+		fare.Lines = append(fare.Lines, domain.FareLine{
+			Code:        "surge",
+			Description: fmt.Sprintf("Surge x%.2f in %s", surge.Multiplier, surge.Name),
+			Quantity:    surge.Multiplier,
+			Amount:      amount,
+		})
+		subtotal += amount
+	}
+
+	if subtotal < card.MinimumFare {
+		fare.Lines = append(fare.Lines, domain.FareLine{
+			Code:        "minimum_fare",
+			Description: "Minimum fare adjustment",
+			Amount:      card.MinimumFare - subtotal,
+		})
+		subtotal = card.MinimumFare
+	}
+
+	fare.Total = subtotal
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+// PrivacyService answers riders' requests for their location data: exports
+// gather it, erasure removes it for good. Both leave an audit record.
+type PrivacyService struct {
+	riderRepo     repository.RiderRepository
+	sessionRepo   repository.SessionRepository
+	locationRepo  repository.LocationRepository
+	retentionRepo repository.RetentionRepository
+	privacyRepo   repository.PrivacyRepository
+	tx            repository.Transactor
+	archives      ArchiveStore
+}
+
+func NewPrivacyService(
+	riderRepo repository.RiderRepository,
+	sessionRepo repository.SessionRepository,
+	locationRepo repository.LocationRepository,
+	retentionRepo repository.RetentionRepository,
+	privacyRepo repository.PrivacyRepository,
+	tx repository.Transactor,
+	archives ArchiveStore,
+) *PrivacyService {
+	return &PrivacyService{
+		riderRepo:     riderRepo,
+		sessionRepo:   sessionRepo,
+		locationRepo:  locationRepo,
+		retentionRepo: retentionRepo,
+		privacyRepo:   privacyRepo,
+		tx:            tx,
+		archives:      archives,
+	}
+}
+
+func (s *PrivacyService) getRider(ctx context.Context, riderID string) (*domain.Rider, error) {
+	rider, err := s.riderRepo.GetByID(ctx, riderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "RIDER_NOT_FOUND", Message: "rider does not exist"}
+	}
+	return rider, err
+}
+
+// ExportRider gathers the rider's profile, sessions and location points.
+// Points already archived under retention are described by the session
+// summary instead.
+func (s *PrivacyService) ExportRider(ctx context.Context, riderID, sourceIP string) (*domain.RiderExport, error) {
+	rider, err := s.getRider(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.ListByRiderID(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &domain.RiderExport{Rider: rider, Sessions: []*domain.ExportSession{}}
+
+	for _, session := range sessions {
+		exported := &domain.ExportSession{
+			SessionID:  session.SessionID,
+			DeliveryID: session.DeliveryID,
+			StartTime:  session.StartTime,
+			EndTime:    session.EndTime,
+			IsActive:   session.IsActive,
+		}
+
+		summary, err := s.retentionRepo.GetSummary(ctx, session.SessionID)
+		if err == nil {
+			exported.Summary = summary
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+
+		points, err := s.locationRepo.GetBySessionID(ctx, session.SessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		export.Sessions = append(export.Sessions, exported)
+		export.Locations = append(export.Locations, points...)
+	}
+
+	err = s.privacyRepo.CreateRequest(ctx, &domain.PrivacyRequest{
+		RiderID:      riderID,
+		Kind:         domain.PrivacyExport,
+		SourceIP:     sourceIP,
+		SessionCount: len(sessions),
+		PointCount:   int64(len(export.Locations)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// WriteExportArchive writes an export as a zip of rider.json, sessions.json
+// and locations.geojson
+func WriteExportArchive(w io.Writer, export *domain.RiderExport) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"rider.json", export.Rider},
+		{"sessions.json", export.Sessions},
+		{"locations.geojson", locationFeatures(export.Locations)},
+	}
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// EraseRider deletes the rider's location points, archives and every copy of
+// their coordinates, and strips their profile. Sessions, deliveries and the
+// per-session totals invoices are built from are kept.
+func (s *PrivacyService) EraseRider(ctx context.Context, riderID, reason, sourceIP string) (*domain.PrivacyRequest, error) {
+	rider, err := s.getRider(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+	if rider.ErasedAt != nil {
+		return nil, &domain.DomainError{Code: "RIDER_ALREADY_ERASED", Message: "rider has already been erased"}
+	}
+
+	sessions, err := s.sessionRepo.ListByRiderID(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]string, len(sessions))
+	for i, session := range sessions {
+		if session.IsActive {
+			return nil, &domain.DomainError{Code: "RIDER_HAS_ACTIVE_SESSION", Message: "stop the rider's active sessions before erasing them"}
+		}
+		sessionIDs[i] = session.SessionID
+	}
+
+	// archives go first, they are being erased either way and a failure here
+	// leaves the request safe to retry
+	for _, sessionID := range sessionIDs {
+		summary, err := s.retentionRepo.GetSummary(ctx, sessionID)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if summary.ArchiveKey != "" {
+			if err := s.archives.Delete(ctx, summary.ArchiveKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	request := &domain.PrivacyRequest{
+		RiderID:      riderID,
+		Kind:         domain.PrivacyErasure,
+		Reason:       reason,
+		SourceIP:     sourceIP,
+		SessionCount: len(sessions),
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		for _, sessionID := range sessionIDs {
+			deleted, err := s.eraseSessionPoints(ctx, sessionID)
+			if err != nil {
+				return err
+			}
+			request.PointCount += deleted
+		}
+
+		if err := s.privacyRepo.EraseSessionTraces(ctx, sessionIDs); err != nil {
+			return err
+		}
+
+		if err := s.riderRepo.Anonymize(ctx, riderID); err != nil {
+			return err
+		}
+
+		return s.privacyRepo.CreateRequest(ctx, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// eraseSessionPoints deletes a session's points, keeping their totals in the
+// session summary
+func (s *PrivacyService) eraseSessionPoints(ctx context.Context, sessionID string) (int64, error) {
+	points, err := s.locationRepo.GetBySessionID(ctx, sessionID)
+	if err != nil || len(points) == 0 {
+		return 0, err
+	}
+
+	summary := summarizeSession(sessionID, points, 0)
+
+	previous, err := s.retentionRepo.GetSummary(ctx, sessionID)
+	if err == nil {
+		mergeSummary(summary, previous)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+
+	summary.Route = nil
+	summary.ArchiveKey = ""
+
+	if err := s.retentionRepo.SaveSummary(ctx, summary); err != nil {
+		return 0, err
+	}
+
+	return s.locationRepo.DeleteBySessionID(ctx, sessionID)
+}
+
+func (s *PrivacyService) ListRequests(ctx context.Context, riderID string) ([]*domain.PrivacyRequest, error) {
+	if _, err := s.getRider(ctx, riderID); err != nil {
+		return nil, err
+	}
+
+	return s.privacyRepo.ListRequests(ctx, riderID)
+}
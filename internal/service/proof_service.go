@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/actor"
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+var (
+	errProofNotFound = &domain.DomainError{Code: "PROOF_NOT_FOUND", Message: "proof of delivery does not exist"}
+	errPINsDisabled  = &domain.DomainError{Code: "PINS_DISABLED", Message: "recipient PINs are not configured"}
+)
+
+// content types accepted per kind of proof, sniffed from the upload rather
+// than trusted from the client, and the extension they are stored with
+var proofContentTypes = map[domain.ProofKind]map[string]string{
+	domain.ProofPhoto: {
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	},
+	domain.ProofSignature: {
+		"image/png":  ".png",
+		"image/jpeg": ".jpg",
+	},
+}
+
+type ProofService struct {
+	proofRepo    repository.ProofRepository
+	deliveryRepo repository.DeliveryRepository
+	sessionRepo  repository.SessionRepository
+	locationRepo repository.LocationRepository
+	tx           repository.Transactor
+	audit        *AuditLog
+	store        BlobStore
+	cfg          *config.ProofConfig
+}
+
+func NewProofService(proofRepo repository.ProofRepository, deliveryRepo repository.DeliveryRepository, sessionRepo repository.SessionRepository, locationRepo repository.LocationRepository, tx repository.Transactor, audit *AuditLog, store BlobStore, cfg *config.ProofConfig) *ProofService {
+	return &ProofService{
+		proofRepo:    proofRepo,
+		deliveryRepo: deliveryRepo,
+		sessionRepo:  sessionRepo,
+		locationRepo: locationRepo,
+		tx:           tx,
+		audit:        audit,
+		store:        store,
+		cfg:          cfg,
+	}
+}
+
+// CaptureProof stores a photo or signature submitted by the rider of the
+// session the delivery is assigned to
+func (s *ProofService) CaptureProof(ctx context.Context, deliveryID, sessionID string, kind domain.ProofKind, data []byte) (*domain.DeliveryProof, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, ok := proofContentTypes[kind]
+	if !ok {
+		return nil, &domain.DomainError{Code: "INVALID_PROOF_KIND", Message: "kind must be photo or signature"}
+	}
+
+	if len(data) == 0 {
+		return nil, &domain.DomainError{Code: "EMPTY_PROOF", Message: "proof file is empty"}
+	}
+	if int64(len(data)) > s.cfg.MaxUploadBytes {
+		return nil, &domain.DomainError{Code: "PROOF_TOO_LARGE", Message: fmt.Sprintf("proof file is larger than %d bytes", s.cfg.MaxUploadBytes)}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	ext, ok := allowed[contentType]
+	if !ok {
+		return nil, &domain.DomainError{Code: "UNSUPPORTED_PROOF_TYPE", Message: fmt.Sprintf("%s is not accepted as a %s", contentType, kind)}
+	}
+
+	if err := s.checkDelivery(ctx, deliveryID, sessionID); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	proof := &domain.DeliveryProof{
+		DeliveryID:  deliveryID,
+		SessionID:   sessionID,
+		Kind:        kind,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		BlobKey:     fmt.Sprintf("%s/%s/%s-%s%s", organizationID, deliveryID, kind, newBlobID(), ext),
+		CapturedAt:  time.Now().UTC(),
+	}
+
+	if err := s.stampLocation(ctx, proof); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Put(ctx, proof.BlobKey, data, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store proof of delivery %s: %w", deliveryID, err)
+	}
+
+	if err := s.save(ctx, proof); err != nil {
+		if err := s.store.Delete(ctx, proof.BlobKey); err != nil {
+			log.Printf("Failed to remove orphaned proof %s: %v", proof.BlobKey, err)
+		}
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// IssuePIN generates the delivery's one-time recipient PIN, replacing any
+// earlier one. The PIN is only returned here, it is stored hashed.
+func (s *ProofService) IssuePIN(ctx context.Context, deliveryID string) (string, *domain.DeliveryPIN, error) {
+	if s.cfg.PINSecret == "" {
+		return "", nil, errPINsDisabled
+	}
+
+	pin, err := newPIN(s.cfg.PINLength)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &domain.DeliveryPIN{
+		DeliveryID: deliveryID,
+		PINHash:    s.hashPIN(deliveryID, pin),
+		ExpiresAt:  time.Now().Add(s.cfg.PINTTL).UTC(),
+	}
+
+	err = s.proofRepo.SavePIN(ctx, record)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil, &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return pin, record, nil
+}
+
+// VerifyPIN checks the PIN the recipient gave the rider and records it as
+// proof. A PIN is accepted once; wrong guesses count towards a lockout.
+func (s *ProofService) VerifyPIN(ctx context.Context, deliveryID, sessionID, pin string) (*domain.DeliveryProof, error) {
+	if s.cfg.PINSecret == "" {
+		return nil, errPINsDisabled
+	}
+
+	if err := s.checkDelivery(ctx, deliveryID, sessionID); err != nil {
+		return nil, err
+	}
+
+	proof := &domain.DeliveryProof{
+		DeliveryID: deliveryID,
+		SessionID:  sessionID,
+		Kind:       domain.ProofPIN,
+		CapturedAt: time.Now().UTC(),
+	}
+
+	if err := s.stampLocation(ctx, proof); err != nil {
+		return nil, err
+	}
+
+	// a wrong guess must still be counted, so it commits and fails afterwards
+	wrong := false
+
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		record, err := s.proofRepo.GetPINForUpdate(ctx, deliveryID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return &domain.DomainError{Code: "PIN_NOT_ISSUED", Message: "no PIN was issued for this delivery"}
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case record.VerifiedAt != nil:
+			return &domain.DomainError{Code: "PIN_ALREADY_USED", Message: "PIN was already used"}
+		case proof.CapturedAt.After(record.ExpiresAt):
+			return &domain.DomainError{Code: "PIN_EXPIRED", Message: "PIN has expired"}
+		case record.Attempts >= s.cfg.PINMaxAttempts:
+			return &domain.DomainError{Code: "PIN_LOCKED", Message: "too many wrong attempts, a new PIN must be issued"}
+		}
+
+		if subtle.ConstantTimeCompare(s.hashPIN(deliveryID, pin), record.PINHash) != 1 {
+			wrong = true
+			record.Attempts++
+			return s.proofRepo.UpdatePIN(ctx, record)
+		}
+
+		record.VerifiedAt = &proof.CapturedAt
+		if err := s.proofRepo.UpdatePIN(ctx, record); err != nil {
+			return err
+		}
+
+		return s.create(ctx, proof)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if wrong {
+		return nil, &domain.DomainError{Code: "INVALID_PIN", Message: "PIN is incorrect"}
+	}
+
+	return proof, nil
+}
+
+func (s *ProofService) ListProofs(ctx context.Context, deliveryID string) ([]*domain.DeliveryProof, error) {
+	return s.proofRepo.ListByDeliveryID(ctx, deliveryID)
+}
+
+// GetProofContent returns a photo or signature with the file it was captured with
+func (s *ProofService) GetProofContent(ctx context.Context, deliveryID, proofID string) (*domain.DeliveryProof, []byte, error) {
+	proof, err := s.proofRepo.GetByID(ctx, deliveryID, proofID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, errProofNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if proof.BlobKey == "" {
+		return nil, nil, &domain.DomainError{Code: "PROOF_HAS_NO_CONTENT", Message: fmt.Sprintf("a %s proof has no file", proof.Kind)}
+	}
+
+	data, err := s.store.Get(ctx, proof.BlobKey)
+	if errors.Is(err, errBlobNotFound) {
+		log.Printf("Proof %s is missing from the store at %s", proof.ID, proof.BlobKey)
+		return nil, nil, errProofNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return proof, data, nil
+}
+
+// checkDelivery only lets the session carrying the delivery submit proof, and
+// a rider's app only for its own session
+func (s *ProofService) checkDelivery(ctx context.Context, deliveryID, sessionID string) error {
+	delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+	}
+	if err != nil {
+		return err
+	}
+
+	if delivery.AssignedSessionID == nil || *delivery.AssignedSessionID != sessionID {
+		return &domain.DomainError{Code: "PROOF_SESSION_MISMATCH", Message: "delivery is not assigned to this session"}
+	}
+
+	if actor.FromContext(ctx).Type == domain.ActorRiderApp {
+		session, err := s.sessionRepo.GetByID(ctx, sessionID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if session == nil || !sessionVisible(ctx, session) {
+			return &domain.DomainError{Code: "PROOF_SESSION_MISMATCH", Message: "delivery is not assigned to this session"}
+		}
+	}
+
+	if delivery.Status != domain.DeliveryInTransit && delivery.Status != domain.DeliveryDelivered {
+		return &domain.DomainError{Code: "DELIVERY_NOT_IN_TRANSIT", Message: fmt.Sprintf("delivery is %s", delivery.Status)}
+	}
+
+	return nil
+}
+
+// stampLocation records where the rider was when the proof was captured
+func (s *ProofService) stampLocation(ctx context.Context, proof *domain.DeliveryProof) error {
+	location, err := s.locationRepo.GetLatestBySessionID(ctx, proof.SessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	proof.Location = &domain.Coordinate{Latitude: location.Latitude, Longitude: location.Longitude}
+	proof.LocationAccuracy = &location.Accuracy
+	proof.LocationRecordedAt = &location.RecordedAt
+	return nil
+}
+
+func (s *ProofService) save(ctx context.Context, proof *domain.DeliveryProof) error {
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.create(ctx, proof)
+	})
+}
+
+func (s *ProofService) create(ctx context.Context, proof *domain.DeliveryProof) error {
+	if err := s.proofRepo.Create(ctx, proof); err != nil {
+		return err
+	}
+
+	return s.audit.Record(ctx, domain.AuditDeliveryProof, domain.AuditEntityDelivery, proof.DeliveryID, nil, proof)
+}
+
+func newBlobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newPIN returns length random decimal digits
+func newPIN(length int) (string, error) {
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
+
+// hashPIN keys the hash with the server's secret, a short PIN could otherwise
+// be brute forced from a leaked hash. The delivery makes equal PINs differ.
+func (s *ProofService) hashPIN(deliveryID, pin string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.cfg.PINSecret))
+	mac.Write([]byte(deliveryID + ":" + pin))
+	return mac.Sum(nil)
+}
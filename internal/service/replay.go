@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+const (
+	MinReplaySpeed = 0.1
+	MaxReplaySpeed = 1000
+	// gaps in the recording (tunnels, app in background) are shortened to this
+	// so a replay does not sit idle for minutes
+	maxReplayGap = 30 * time.Second
+)
+
+// ReplayState describes where a replay is, sent to the client after every change
+type ReplayState struct {
+	SessionID string    `json:"sessionId"`
+	Position  int       `json:"position"`
+	Total     int       `json:"total"`
+	Speed     float64   `json:"speed"`
+	Paused    bool      `json:"paused"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// recorded_at of the last point sent, the start time before the first
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+// Replay is a cursor over a finished session's points that knows how long to
+// wait between them at the chosen speed. It is not safe for concurrent use.
+type Replay struct {
+	sessionID string
+	points    []*domain.LocationUpdate
+	next      int
+	speed     float64
+	paused    bool
+}
+
+// LoadReplay prepares a replay of a finished session in recorded_at order
+func (s *LocationService) LoadReplay(ctx context.Context, sessionID string, speed float64) (*Replay, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.IsActive {
+		return nil, &domain.DomainError{Code: "SESSION_STILL_ACTIVE", Message: "only finished sessions can be replayed"}
+	}
+
+	points, err := s.locationRepo.GetBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(points) == 0 {
+		return nil, &domain.DomainError{Code: "INSUFFICIENT_POINTS", Message: "session has no recorded locations"}
+	}
+
+	replay := &Replay{sessionID: sessionID, points: points, speed: 1}
+	if err := replay.SetSpeed(speed); err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+func (r *Replay) Done() bool {
+	return r.next >= len(r.points)
+}
+
+func (r *Replay) Paused() bool {
+	return r.paused
+}
+
+func (r *Replay) Pause() {
+	r.paused = true
+}
+
+func (r *Replay) Resume() {
+	r.paused = false
+}
+
+// Delay returns how long to wait before sending the next point
+func (r *Replay) Delay() time.Duration {
+	if r.next == 0 || r.Done() {
+		return 0
+	}
+
+	gap := r.points[r.next].RecordedAt.Sub(r.points[r.next-1].RecordedAt)
+	if gap < 0 {
+		gap = 0
+	}
+	if gap > maxReplayGap {
+		gap = maxReplayGap
+	}
+
+	return time.Duration(float64(gap) / r.speed)
+}
+
+// Advance returns the next point and moves past it
+func (r *Replay) Advance() *domain.LocationUpdate {
+	if r.Done() {
+		return nil
+	}
+
+	point := r.points[r.next]
+	r.next++
+	return point
+}
+
+func (r *Replay) SetSpeed(speed float64) error {
+	if math.IsNaN(speed) || speed < MinReplaySpeed || speed > MaxReplaySpeed {
+		return &domain.DomainError{Code: "INVALID_REPLAY_SPEED", Message: "speed must be between 0.1 and 1000"}
+	}
+
+	r.speed = speed
+	return nil
+}
+
+// SeekIndex makes the point at index the next one sent
+func (r *Replay) SeekIndex(index int) error {
+	if index < 0 || index >= len(r.points) {
+		return &domain.DomainError{Code: "INVALID_SEEK", Message: "position is outside the recording"}
+	}
+
+	r.next = index
+	return nil
+}
+
+// SeekTime makes the first point recorded at or after t the next one sent
+func (r *Replay) SeekTime(t time.Time) error {
+	index := sort.Search(len(r.points), func(i int) bool {
+		return !r.points[i].RecordedAt.Before(t)
+	})
+
+	return r.SeekIndex(index)
+}
+
+func (r *Replay) State() ReplayState {
+	state := ReplayState{
+		SessionID: r.sessionID,
+		Position:  r.next,
+		Total:     len(r.points),
+		Speed:     r.speed,
+		Paused:    r.paused,
+		StartTime: r.points[0].RecordedAt,
+		EndTime:   r.points[len(r.points)-1].RecordedAt,
+	}
+
+	state.CurrentTime = state.StartTime
+	if r.next > 0 {
+		state.CurrentTime = r.points[r.next-1].RecordedAt
+	}
+
+	return state
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+)
+
+func TestReplaySetSpeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed float64
+		valid bool
+	}{
+		{"minimum", MinReplaySpeed, true},
+		{"maximum", MaxReplaySpeed, true},
+		{"below minimum", 0.05, false},
+		{"above maximum", 1001, false},
+		{"negative", -1, false},
+		{"not a number", math.NaN(), false},
+		{"infinite", math.Inf(1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := &Replay{speed: 1}
+			err := replay.SetSpeed(tt.speed)
+
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("SetSpeed(%v) = %v, want nil", tt.speed, err)
+				}
+				if replay.speed != tt.speed {
+					t.Errorf("speed = %v, want %v", replay.speed, tt.speed)
+				}
+				return
+			}
+
+			var domainErr *domain.DomainError
+			if !errors.As(err, &domainErr) || domainErr.Code != "INVALID_REPLAY_SPEED" {
+				t.Fatalf("SetSpeed(%v) = %v, want INVALID_REPLAY_SPEED", tt.speed, err)
+			}
+			if replay.speed != 1 {
+				t.Errorf("speed changed to %v after a rejected value", replay.speed)
+			}
+		})
+	}
+}
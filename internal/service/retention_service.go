@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+var errRetentionPolicyNotFound = &domain.DomainError{Code: "RETENTION_POLICY_NOT_FOUND", Message: "organization has no retention policy"}
+
+// RetentionService manages how long an organization keeps raw location
+// points and serves what is kept of sessions once they are archived
+type RetentionService struct {
+	retentionRepo repository.RetentionRepository
+}
+
+func NewRetentionService(retentionRepo repository.RetentionRepository) *RetentionService {
+	return &RetentionService{retentionRepo: retentionRepo}
+}
+
+func (s *RetentionService) GetPolicy(ctx context.Context) (*domain.RetentionPolicy, error) {
+	policy, err := s.retentionRepo.GetPolicy(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errRetentionPolicyNotFound
+	}
+	return policy, err
+}
+
+// SetPolicy keeps raw points for days after a session's last point
+func (s *RetentionService) SetPolicy(ctx context.Context, days int) (*domain.RetentionPolicy, error) {
+	if days < 1 {
+		return nil, &domain.DomainError{Code: "INVALID_RETENTION", Message: "retention must be at least one day"}
+	}
+
+	policy := &domain.RetentionPolicy{LocationRetentionDays: days}
+	if err := s.retentionRepo.SetPolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// DeletePolicy makes the organization keep raw points forever
+func (s *RetentionService) DeletePolicy(ctx context.Context) error {
+	err := s.retentionRepo.DeletePolicy(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return errRetentionPolicyNotFound
+	}
+	return err
+}
+
+func (s *RetentionService) GetSessionSummary(ctx context.Context, sessionID string) (*domain.SessionSummary, error) {
+	summary, err := s.retentionRepo.GetSummary(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "SESSION_SUMMARY_NOT_FOUND", Message: "session has not been archived"}
+	}
+	return summary, err
+}
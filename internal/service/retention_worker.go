@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+// RetentionWorker enforces retention policies. Sessions whose last point is
+// older than the organization's retention have their points exported to the
+// archive store as gzipped GeoJSON, summarized and then deleted.
+type RetentionWorker struct {
+	retentionRepo repository.RetentionRepository
+	locationRepo  repository.LocationRepository
+	tx            repository.Transactor
+	store         ArchiveStore
+	cfg           *config.RetentionConfig
+}
+
+func NewRetentionWorker(retentionRepo repository.RetentionRepository, locationRepo repository.LocationRepository, tx repository.Transactor, store ArchiveStore, cfg *config.RetentionConfig) *RetentionWorker {
+	return &RetentionWorker{
+		retentionRepo: retentionRepo,
+		locationRepo:  locationRepo,
+		tx:            tx,
+		store:         store,
+		cfg:           cfg,
+	}
+}
+
+// Run enforces the policies until ctx is cancelled
+func (w *RetentionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := w.Sweep(ctx)
+		if err != nil {
+			log.Printf("[RETENTION] %v", err)
+		}
+		if archived > 0 {
+			log.Printf("[RETENTION] archived %d sessions", archived)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep archives every expired session of every organization with a policy
+// and returns how many were archived
+func (w *RetentionWorker) Sweep(ctx context.Context) (int, error) {
+	policies, err := w.retentionRepo.ListPolicies(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, policy := range policies {
+		orgCtx := tenant.WithOrganization(ctx, policy.OrganizationID)
+		cutoff := time.Now().AddDate(0, 0, -policy.LocationRetentionDays)
+
+		archived, err := w.sweepOrganization(orgCtx, cutoff)
+		total += archived
+		if err != nil {
+			// one organization failing must not hold up the others
+			log.Printf("[RETENTION] organization %s: %v", policy.OrganizationID, err)
+		}
+	}
+
+	return total, nil
+}
+
+func (w *RetentionWorker) sweepOrganization(ctx context.Context, cutoff time.Time) (int, error) {
+	archived := 0
+
+	for {
+		sessions, err := w.retentionRepo.ListExpiredSessions(ctx, cutoff, w.cfg.BatchSize)
+		if err != nil {
+			return archived, err
+		}
+
+		for _, session := range sessions {
+			if err := w.archiveSession(ctx, session); err != nil {
+				return archived, fmt.Errorf("session %s: %w", session.SessionID, err)
+			}
+			archived++
+		}
+
+		if len(sessions) < w.cfg.BatchSize {
+			return archived, nil
+		}
+	}
+}
+
+// archiveSession exports before deleting, a failure in between leaves the
+// points in place and the session is archived again on the next pass
+func (w *RetentionWorker) archiveSession(ctx context.Context, session *domain.TrackingSession) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	points, err := w.locationRepo.GetBySessionID(ctx, session.SessionID)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	data, err := encodeArchive(points)
+	if err != nil {
+		return err
+	}
+
+	// the first point id keeps a later archive of late points from replacing this one
+	key := fmt.Sprintf("%s/%s/%s-%d.geojson.gz", organizationID, points[0].RecordedAt.UTC().Format("2006/01"), url.PathEscape(session.SessionID), points[0].ID)
+	if err := w.store.Put(ctx, key, data, "application/gzip"); err != nil {
+		return fmt.Errorf("failed to store archive: %w", err)
+	}
+
+	summary := summarizeSession(session.SessionID, points, w.cfg.RouteTolerance)
+	summary.ArchiveKey = key
+
+	// points that arrived after an earlier archive extend its summary
+	if previous, err := w.retentionRepo.GetSummary(ctx, session.SessionID); err == nil {
+		mergeSummary(summary, previous)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	return w.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := w.retentionRepo.SaveSummary(ctx, summary); err != nil {
+			return err
+		}
+
+		_, err := w.locationRepo.DeleteBySessionID(ctx, session.SessionID)
+		return err
+	})
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// locationFeatures returns points as a GeoJSON FeatureCollection
+func locationFeatures(points []*domain.LocationUpdate) interface{} {
+	features := make([]geoJSONFeature, len(points))
+	for i, p := range points {
+		properties := map[string]interface{}{
+			"id":         p.ID,
+			"sessionId":  p.SessionID,
+			"accuracy":   p.Accuracy,
+			"recordedAt": p.RecordedAt,
+			"createdAt":  p.CreatedAt,
+		}
+		if p.DeliveryID != "" {
+			properties["deliveryId"] = p.DeliveryID
+		}
+		if p.Speed != nil {
+			properties["speed"] = *p.Speed
+		}
+		if p.Heading != nil {
+			properties["heading"] = *p.Heading
+		}
+
+		features[i] = geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{p.Longitude, p.Latitude}},
+			Properties: properties,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}
+}
+
+// encodeArchive writes points as a gzipped GeoJSON FeatureCollection
+func encodeArchive(points []*domain.LocationUpdate) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	if err := json.NewEncoder(zw).Encode(locationFeatures(points)); err != nil {
+		return nil, fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// summarizeSession keeps totals and a simplified route of points, which are
+// ordered by recorded time
+func summarizeSession(sessionID string, points []*domain.LocationUpdate, tolerance float64) *domain.SessionSummary {
+	path := make([]geo.Point, len(points))
+	for i, p := range points {
+		path[i] = geo.Point{Latitude: p.Latitude, Longitude: p.Longitude}
+	}
+
+	simplified := geo.Simplify(path, tolerance)
+	route := make([]domain.Coordinate, len(simplified))
+	for i, p := range simplified {
+		route[i] = domain.Coordinate{Latitude: p.Latitude, Longitude: p.Longitude}
+	}
+
+	return &domain.SessionSummary{
+		SessionID:       sessionID,
+		PointCount:      len(points),
+		DistanceMeters:  geo.PathLength(path),
+		FirstRecordedAt: points[0].RecordedAt,
+		LastRecordedAt:  points[len(points)-1].RecordedAt,
+		Route:           route,
+	}
+}
+
+// mergeSummary folds an earlier summary of the same session into summary
+func mergeSummary(summary, previous *domain.SessionSummary) {
+	summary.PointCount += previous.PointCount
+	summary.DistanceMeters += previous.DistanceMeters
+	if previous.FirstRecordedAt.Before(summary.FirstRecordedAt) {
+		summary.FirstRecordedAt = previous.FirstRecordedAt
+	}
+	if previous.LastRecordedAt.After(summary.LastRecordedAt) {
+		summary.LastRecordedAt = previous.LastRecordedAt
+	}
+	summary.Route = append(previous.Route, summary.Route...)
+}
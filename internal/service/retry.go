@@ -0,0 +1,35 @@
+package service
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// backoff returns the delay before the next attempt, doubling from the base
+// delay with up to 20% jitter and never longer than max
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	delay += time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// drain runs batch while it keeps coming back full, logging failures under tag
+func drain(tag string, size int, batch func() (int, error)) {
+	for {
+		n, err := batch()
+		if err != nil {
+			log.Printf("[%s] %v", tag, err)
+		}
+		if err != nil || n < size {
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+type RiderService struct {
+	riderRepo    repository.RiderRepository
+	locationRepo repository.LocationRepository
+}
+
+func NewRiderService(riderRepo repository.RiderRepository, locationRepo repository.LocationRepository) *RiderService {
+	return &RiderService{
+		riderRepo:    riderRepo,
+		locationRepo: locationRepo,
+	}
+}
+
+func (s *RiderService) CreateRider(ctx context.Context, rider *domain.Rider) error {
+	rider.Name = strings.TrimSpace(rider.Name)
+	rider.Phone = strings.TrimSpace(rider.Phone)
+
+	if rider.Name == "" {
+		return &domain.DomainError{Code: "INVALID_RIDER_NAME", Message: "name is required"}
+	}
+	if rider.Phone == "" {
+		return &domain.DomainError{Code: "INVALID_RIDER_PHONE", Message: "phone is required"}
+	}
+	if !rider.VehicleType.Valid() {
+		return &domain.DomainError{Code: "INVALID_VEHICLE_TYPE", Message: "vehicle type must be one of bicycle, motorcycle, car, van"}
+	}
+
+	rider.Availability = domain.RiderOffline
+	rider.ShiftStart = nil
+	rider.ShiftEnd = nil
+
+	return s.riderRepo.Create(ctx, rider)
+}
+
+func (s *RiderService) GetRider(ctx context.Context, riderID string) (*domain.Rider, error) {
+	rider, err := s.riderRepo.GetByID(ctx, riderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "RIDER_NOT_FOUND", Message: "rider does not exist"}
+	}
+	return rider, err
+}
+
+// SetAvailability changes a rider's status. Only riders on shift can be anything but offline.
+func (s *RiderService) SetAvailability(ctx context.Context, riderID string, availability domain.RiderAvailability) (*domain.Rider, error) {
+	if !availability.Valid() {
+		return nil, &domain.DomainError{Code: "INVALID_AVAILABILITY", Message: "availability must be one of offline, available, busy, on_break"}
+	}
+
+	rider, err := s.GetRider(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if availability != domain.RiderOffline && !rider.OnShift() {
+		return nil, &domain.DomainError{Code: "RIDER_OFF_SHIFT", Message: "rider must start a shift first"}
+	}
+
+	rider.Availability = availability
+	if err := s.riderRepo.Update(ctx, rider); err != nil {
+		return nil, err
+	}
+
+	return rider, nil
+}
+
+func (s *RiderService) StartShift(ctx context.Context, riderID string) (*domain.Rider, error) {
+	rider, err := s.GetRider(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rider.OnShift() {
+		return nil, &domain.DomainError{Code: "SHIFT_ALREADY_STARTED", Message: "rider is already on shift"}
+	}
+
+	now := time.Now()
+	rider.ShiftStart = &now
+	rider.ShiftEnd = nil
+	rider.Availability = domain.RiderAvailable
+
+	if err := s.riderRepo.Update(ctx, rider); err != nil {
+		return nil, err
+	}
+
+	return rider, nil
+}
+
+func (s *RiderService) EndShift(ctx context.Context, riderID string) (*domain.Rider, error) {
+	rider, err := s.GetRider(ctx, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rider.OnShift() {
+		return nil, &domain.DomainError{Code: "SHIFT_NOT_STARTED", Message: "rider is not on shift"}
+	}
+
+	now := time.Now()
+	rider.ShiftEnd = &now
+	rider.Availability = domain.RiderOffline
+
+	if err := s.riderRepo.Update(ctx, rider); err != nil {
+		return nil, err
+	}
+
+	return rider, nil
+}
+
+// GetRiderLocations returns every point recorded by the rider's sessions between from and to
+func (s *RiderService) GetRiderLocations(ctx context.Context, riderID string, from, to time.Time) ([]*domain.LocationUpdate, error) {
+	if !to.After(from) {
+		return nil, &domain.DomainError{Code: "INVALID_TIME_RANGE", Message: "to must be after from"}
+	}
+
+	if _, err := s.GetRider(ctx, riderID); err != nil {
+		return nil, err
+	}
+
+	return s.locationRepo.GetByRiderID(ctx, riderID, from, to)
+}
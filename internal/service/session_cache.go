@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+)
+
+// SessionCache holds tracking session state between location points so
+// RecordLocation does not load the session for every point. Entries are keyed
+// by organization and session id.
+type SessionCache interface {
+	Get(ctx context.Context, organizationID, sessionID string) (*domain.TrackingSession, bool)
+	// Generation is taken before reading a session from the database and
+	// passed to Set, which drops the session if it was invalidated since
+	Generation(ctx context.Context) uint64
+	Set(ctx context.Context, organizationID string, session *domain.TrackingSession, since uint64)
+	Invalidate(ctx context.Context, organizationID, sessionID string)
+	// Clear drops every entry, for when invalidations may have been missed
+	Clear(ctx context.Context)
+}
+
+// SessionChanges reports sessions changed by any instance, see
+// LocationService.FollowSessionChanges
+type SessionChanges interface {
+	Listen(ctx context.Context, changed func(organizationID, sessionID string), missed func()) error
+}
+
+type cachedSession struct {
+	session   domain.TrackingSession
+	expiresAt time.Time
+}
+
+// MemorySessionCache is a SessionCache local to this process. Other instances
+// learn of changes through SessionChanges; the TTL bounds how long they may act
+// on a stale session if a notification is lost.
+type MemorySessionCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cachedSession
+	// generation counts invalidations. invalidated holds the generation each
+	// key was last invalidated at, Sets from before floor are all dropped.
+	generation  uint64
+	floor       uint64
+	invalidated map[string]uint64
+}
+
+func NewMemorySessionCache(ttl time.Duration, maxEntries int) *MemorySessionCache {
+	return &MemorySessionCache{
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		entries:     make(map[string]cachedSession),
+		invalidated: make(map[string]uint64),
+	}
+}
+
+func (c *MemorySessionCache) Get(ctx context.Context, organizationID, sessionID string) (*domain.TrackingSession, bool) {
+	key := organizationID + "/" + sessionID
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	// a copy, callers must not change what other points will see
+	session := entry.session
+	return &session, true
+}
+
+func (c *MemorySessionCache) Generation(ctx context.Context) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+func (c *MemorySessionCache) Set(ctx context.Context, organizationID string, session *domain.TrackingSession, since uint64) {
+	now := time.Now()
+	key := organizationID + "/" + session.SessionID
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// read before an invalidation, the session may already have changed
+	if since < c.floor || since < c.invalidated[key] {
+		return
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.sweep(now)
+	}
+	// still full of live sessions, serve this one from the database
+	if len(c.entries) >= c.maxEntries {
+		return
+	}
+
+	// a reader that loaded the session before a change must not put it back
+	if entry, ok := c.entries[key]; ok && entry.session.Version > session.Version {
+		return
+	}
+
+	c.entries[key] = cachedSession{session: *session, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *MemorySessionCache) Invalidate(ctx context.Context, organizationID, sessionID string) {
+	key := organizationID + "/" + sessionID
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	c.generation++
+	// forgetting every key is the same as invalidating them all at once
+	if len(c.invalidated) >= c.maxEntries {
+		c.floor = c.generation
+		clear(c.invalidated)
+		return
+	}
+	c.invalidated[key] = c.generation
+}
+
+func (c *MemorySessionCache) Clear(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.generation++
+	c.floor = c.generation
+	clear(c.invalidated)
+}
+
+func (c *MemorySessionCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+// sessions stopped per pass, the rest wait for the next one
+const reapBatchSize = 100
+
+// SessionReaper stops sessions whose rider went silent, e.g. because the app
+// was killed, so they do not stay active and keep accruing time
+type SessionReaper struct {
+	sessionRepo     repository.SessionRepository
+	locationService *LocationService
+	cfg             *config.TrackingConfig
+}
+
+func NewSessionReaper(sessionRepo repository.SessionRepository, locationService *LocationService, cfg *config.TrackingConfig) *SessionReaper {
+	return &SessionReaper{sessionRepo: sessionRepo, locationService: locationService, cfg: cfg}
+}
+
+// Run reaps stale sessions until ctx is cancelled
+func (r *SessionReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		reaped, err := r.Reap(ctx)
+		if err != nil {
+			log.Printf("[REAPER] %v", err)
+		}
+		if reaped > 0 {
+			log.Printf("[REAPER] stopped %d stale sessions", reaped)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reap stops the sessions of every organization that have been silent for
+// longer than the stale session timeout and returns how many were stopped
+func (r *SessionReaper) Reap(ctx context.Context) (int, error) {
+	sessions, err := r.sessionRepo.ListStale(ctx, time.Now().Add(-r.cfg.StaleSessionTimeout), reapBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	reaped := 0
+	for _, session := range sessions {
+		orgCtx := tenant.WithOrganization(ctx, session.OrganizationID)
+
+		err := r.locationService.ReapSession(orgCtx, session.SessionID, session.LastSeenAt)
+		if errors.Is(err, errSessionInactive) {
+			// stopped elsewhere in the meantime, only the cached copy may be stale
+			r.locationService.InvalidateSession(orgCtx, session.SessionID)
+			continue
+		}
+		if err != nil {
+			log.Printf("[REAPER] session %s: %v", session.SessionID, err)
+			continue
+		}
+		reaped++
+	}
+
+	return reaped, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+	"github.com/SarkiMudboy/easebox-api/pkg/geo"
+)
+
+var errShareLinkNotFound = &domain.DomainError{Code: "SHARE_LINK_NOT_FOUND", Message: "tracking link is invalid or has expired"}
+
+type ShareService struct {
+	shareRepo    repository.ShareLinkRepository
+	deliveryRepo repository.DeliveryRepository
+	locationRepo repository.LocationRepository
+	cfg          *config.ShareConfig
+}
+
+func NewShareService(shareRepo repository.ShareLinkRepository, deliveryRepo repository.DeliveryRepository, locationRepo repository.LocationRepository, cfg *config.ShareConfig) *ShareService {
+	return &ShareService{
+		shareRepo:    shareRepo,
+		deliveryRepo: deliveryRepo,
+		locationRepo: locationRepo,
+		cfg:          cfg,
+	}
+}
+
+// CreateLink issues a share link for a delivery of the organization in ctx.
+// ttl is capped at the configured lifetime, zero means the full lifetime.
+// The returned token cannot be recovered later.
+func (s *ShareService) CreateLink(ctx context.Context, deliveryID string, ttl time.Duration) (*domain.ShareLink, string, error) {
+	if ttl <= 0 || ttl > s.cfg.LinkTTL {
+		ttl = s.cfg.LinkTTL
+	}
+
+	tokenBytes := make([]byte, 32)
+	rand.Read(tokenBytes)
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	link := &domain.ShareLink{
+		DeliveryID: deliveryID,
+		TokenHash:  hashShareToken(token),
+		ExpiresAt:  time.Now().Add(ttl).UTC(),
+	}
+
+	if err := s.shareRepo.Create(ctx, link); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+		}
+		return nil, "", err
+	}
+
+	return link, token, nil
+}
+
+// URL returns the public page a token opens
+func (s *ShareService) URL(token string) string {
+	return s.cfg.BaseURL + "/t/" + token
+}
+
+func (s *ShareService) ListLinks(ctx context.Context, deliveryID string) ([]*domain.ShareLink, error) {
+	return s.shareRepo.ListByDeliveryID(ctx, deliveryID)
+}
+
+func (s *ShareService) RevokeLink(ctx context.Context, deliveryID, linkID string) error {
+	err := s.shareRepo.Revoke(ctx, deliveryID, linkID, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "SHARE_LINK_NOT_FOUND", Message: "share link does not exist or is already revoked"}
+	}
+	return err
+}
+
+// GetTracking resolves a token to the recipient's view of its delivery. The
+// rider's position is only disclosed while the delivery is underway.
+func (s *ShareService) GetTracking(ctx context.Context, token string) (*domain.SharedTracking, error) {
+	link, err := s.shareRepo.GetByTokenHash(ctx, hashShareToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errShareLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !link.Usable(now) {
+		return nil, errShareLinkNotFound
+	}
+
+	// everything below is read on behalf of the link's organization
+	ctx = tenant.WithOrganization(ctx, link.OrganizationID)
+
+	delivery, err := s.deliveryRepo.GetByID(ctx, link.DeliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errShareLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	view := &domain.SharedTracking{
+		DeliveryID:  delivery.ID,
+		Status:      delivery.Status,
+		Dropoff:     delivery.Dropoff,
+		PickedUpAt:  delivery.PickedUpAt,
+		DeliveredAt: delivery.DeliveredAt,
+		ExpiresAt:   link.ExpiresAt,
+	}
+
+	var target domain.Coordinate
+	switch delivery.Status {
+	case domain.DeliveryAssigned:
+		target = delivery.Pickup
+	case domain.DeliveryInTransit:
+		target = delivery.Dropoff
+	default:
+		return view, nil
+	}
+
+	if delivery.AssignedSessionID == nil {
+		return view, nil
+	}
+
+	latest, err := s.locationRepo.GetLatestBySessionID(ctx, *delivery.AssignedSessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return view, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	position := geo.Point{Latitude: latest.Latitude, Longitude: latest.Longitude}
+	remaining := geo.Haversine(position, geo.Point{Latitude: target.Latitude, Longitude: target.Longitude})
+	if delivery.Status == domain.DeliveryAssigned {
+		remaining += geo.Haversine(
+			geo.Point{Latitude: delivery.Pickup.Latitude, Longitude: delivery.Pickup.Longitude},
+			geo.Point{Latitude: delivery.Dropoff.Latitude, Longitude: delivery.Dropoff.Longitude},
+		)
+	}
+
+	speed := s.cfg.AverageSpeed
+	// a rider stopped at a light should not push the ETA out indefinitely
+	if latest.Speed != nil && *latest.Speed > 1 {
+		speed = *latest.Speed
+	}
+
+	eta := latest.RecordedAt.Add(time.Duration(remaining / speed * float64(time.Second)))
+	if eta.Before(now) {
+		eta = now
+	}
+
+	view.Location = &domain.Coordinate{Latitude: latest.Latitude, Longitude: latest.Longitude}
+	view.LocationRecordedAt = &latest.RecordedAt
+	view.DistanceRemaining = &remaining
+	view.ETA = &eta
+
+	return view, nil
+}
+
+func hashShareToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
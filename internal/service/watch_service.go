@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+// resumeLimit caps how many stored events a reconnecting watcher is sent
+const resumeLimit = 1000
+
+// EventSubscriber is the subscription model shared by every live watcher. Each
+// subscriber receives the events of one organization that pass its filter, as
+// the outbox relays them.
+type EventSubscriber interface {
+	Subscribe(organizationID string, filter func(event *domain.Event) bool) *Subscription
+}
+
+// WatchService lets clients follow a single delivery, over websocket or SSE
+type WatchService struct {
+	deliveryRepo repository.DeliveryRepository
+	outboxRepo   repository.OutboxRepository
+	events       EventSubscriber
+}
+
+func NewWatchService(deliveryRepo repository.DeliveryRepository, outboxRepo repository.OutboxRepository, events EventSubscriber) *WatchService {
+	return &WatchService{
+		deliveryRepo: deliveryRepo,
+		outboxRepo:   outboxRepo,
+		events:       events,
+	}
+}
+
+// DeliveryWatch is a subscription to one delivery's location and status events.
+// When the subscription is cut off the watch is reopened after the last event
+// the client was sent.
+type DeliveryWatch struct {
+	*Subscription
+
+	Delivery *domain.Delivery
+	// events stored after the resume point, to be sent before live ones
+	Backlog []*domain.Event
+	// events since the resume point were missed, the client must start over
+	// from the delivery snapshot
+	Reset bool
+
+	// the session carrying the delivery, Match runs on the bus
+	mu        sync.Mutex
+	sessionID string
+	// backlog event ids, the live stream may repeat them
+	seen map[string]bool
+}
+
+// WatchDelivery subscribes to a delivery of the organization in ctx. With a
+// lastEventID the events published after it are loaded into the backlog. When
+// they cannot all be replayed, because the id is unknown or pruned or too much
+// happened since, the watch is marked Reset and resumes from the live stream.
+func (s *WatchService) WatchDelivery(ctx context.Context, deliveryID, lastEventID string) (*DeliveryWatch, error) {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, &domain.DomainError{Code: "DELIVERY_NOT_FOUND", Message: "delivery does not exist"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	watch := &DeliveryWatch{
+		Delivery: delivery,
+		seen:     make(map[string]bool),
+	}
+	if delivery.AssignedSessionID != nil {
+		watch.sessionID = *delivery.AssignedSessionID
+	}
+
+	// subscribe before reading the backlog so nothing falls in between
+	watch.Subscription = s.events.Subscribe(organizationID, watch.Match)
+
+	if lastEventID == "" {
+		return watch, nil
+	}
+
+	stored, err := s.outboxRepo.ListPublishedAfter(ctx, lastEventID, deliveryID, resumeLimit)
+	if errors.Is(err, repository.ErrNotFound) || len(stored) == resumeLimit {
+		watch.Reset = true
+		return watch, nil
+	}
+	if err != nil {
+		watch.Close()
+		return nil, err
+	}
+
+	for _, event := range stored {
+		if watch.Match(event) {
+			watch.Backlog = append(watch.Backlog, event)
+			watch.seen[event.ID] = true
+		}
+	}
+
+	return watch, nil
+}
+
+// Replayed reports whether a live event was already sent with the backlog
+func (w *DeliveryWatch) Replayed(event *domain.Event) bool {
+	return w.seen[event.ID]
+}
+
+// Match reports whether an event belongs to the watched delivery: its status
+// events, and the locations of the session it is assigned to
+func (w *DeliveryWatch) Match(event *domain.Event) bool {
+	var ref struct {
+		DeliveryID string `json:"deliveryId"`
+		SessionID  string `json:"sessionId"`
+	}
+	if err := decodeEventData(event, &ref); err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	matched := false
+	switch event.Type {
+	case domain.EventDeliveryAssigned, domain.EventDeliveryStatusChanged,
+		domain.EventRiderArrivedAtPickup, domain.EventRiderArrivedAtDropoff, domain.EventDeliveryCompleted:
+		matched = ref.DeliveryID == w.Delivery.ID
+		if matched && ref.SessionID != "" {
+			// follow the session actually carrying the delivery
+			w.sessionID = ref.SessionID
+		}
+	case domain.EventLocationRecorded, domain.EventSessionStarted, domain.EventSessionStopped:
+		matched = w.sessionID != "" && ref.SessionID == w.sessionID
+	}
+
+	return matched
+}
+
+// decodeEventData reads an event's data into v, whether it was built in
+// process or restored from the outbox as raw JSON
+func decodeEventData(event *domain.Event, v interface{}) error {
+	raw, ok := event.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(event.Data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, v)
+}
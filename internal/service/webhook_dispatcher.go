@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+const (
+	SignatureHeader = "X-Easebox-Signature"
+	EventHeader     = "X-Easebox-Event"
+	DeliveryHeader  = "X-Easebox-Delivery"
+)
+
+// WebhookDispatcher sends queued webhook deliveries and retries failures with
+// exponential backoff until they succeed or are dead lettered
+type WebhookDispatcher struct {
+	webhookRepo repository.WebhookRepository
+	client      *http.Client
+	cfg         *config.WebhookConfig
+}
+
+// NewWebhookDispatcher creates a dispatcher. client may be nil, a client with the
+// configured timeout that only connects to public addresses is used then.
+func NewWebhookDispatcher(webhookRepo repository.WebhookRepository, client *http.Client, cfg *config.WebhookConfig) *WebhookDispatcher {
+	if client == nil {
+		client = newWebhookClient(cfg.Timeout)
+	}
+
+	return &WebhookDispatcher{
+		webhookRepo: webhookRepo,
+		client:      client,
+		cfg:         cfg,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		drain("WEBHOOK", d.cfg.BatchSize, func() (int, error) { return d.DispatchDue(ctx) })
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DispatchDue sends one batch of due deliveries and returns how many were attempted
+func (d *WebhookDispatcher) DispatchDue(ctx context.Context) (int, error) {
+	// the lease must outlive every attempt in the batch
+	lease := d.cfg.Timeout*time.Duration(d.cfg.BatchSize) + time.Minute
+
+	deliveries, err := d.webhookRepo.ClaimDue(ctx, d.cfg.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(ctx, delivery)
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *domain.WebhookDelivery) {
+	started := time.Now()
+	statusCode, sendErr := d.send(ctx, delivery)
+
+	attempt := &domain.WebhookAttempt{
+		DeliveryID:  delivery.ID,
+		Attempt:     delivery.Attempts + 1,
+		Duration:    time.Since(started).Milliseconds(),
+		AttemptedAt: started,
+	}
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+	if sendErr != nil {
+		message := sendErr.Error()
+		attempt.Error = &message
+	}
+
+	if err := d.webhookRepo.RecordAttempt(ctx, attempt); err != nil {
+		log.Printf("[WEBHOOK] %v", err)
+	}
+
+	var err error
+	switch {
+	case sendErr == nil:
+		err = d.webhookRepo.MarkSucceeded(ctx, delivery.ID)
+	case attempt.Attempt >= d.cfg.MaxAttempts:
+		log.Printf("[WEBHOOK] delivery %s dead lettered after %d attempts: %v", delivery.ID, attempt.Attempt, sendErr)
+		err = d.webhookRepo.MarkDead(ctx, delivery.ID, sendErr.Error())
+	default:
+		next := time.Now().Add(backoff(d.cfg.BaseBackoff, d.cfg.MaxBackoff, attempt.Attempt))
+		err = d.webhookRepo.ScheduleRetry(ctx, delivery.ID, next, sendErr.Error())
+	}
+
+	if err != nil {
+		log.Printf("[WEBHOOK] %v", err)
+	}
+}
+
+// send posts the signed payload and treats any 2xx response as success
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "easebox-webhooks/1")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+SignPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignPayload computes the v1 signature receivers use to verify a webhook:
+// hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
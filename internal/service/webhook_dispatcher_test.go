@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/config"
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+)
+
+// fakeWebhookRepo records what the dispatcher does with the deliveries it claims
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+
+	mu         sync.Mutex
+	due        []*domain.WebhookDelivery
+	attempts   []*domain.WebhookAttempt
+	succeeded  []string
+	retries    map[string]time.Time
+	dead       map[string]string
+	lastErrors map[string]string
+}
+
+func newFakeWebhookRepo(due ...*domain.WebhookDelivery) *fakeWebhookRepo {
+	return &fakeWebhookRepo{
+		due:        due,
+		retries:    make(map[string]time.Time),
+		dead:       make(map[string]string),
+		lastErrors: make(map[string]string),
+	}
+}
+
+func (r *fakeWebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := min(limit, len(r.due))
+	claimed := r.due[:n]
+	r.due = r.due[n:]
+	return claimed, nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(ctx context.Context, attempt *domain.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+func (r *fakeWebhookRepo) MarkSucceeded(ctx context.Context, deliveryID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.succeeded = append(r.succeeded, deliveryID)
+	return nil
+}
+
+func (r *fakeWebhookRepo) ScheduleRetry(ctx context.Context, deliveryID string, nextAttemptAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[deliveryID] = nextAttemptAt
+	r.lastErrors[deliveryID] = lastError
+	return nil
+}
+
+func (r *fakeWebhookRepo) MarkDead(ctx context.Context, deliveryID string, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dead[deliveryID] = lastError
+	return nil
+}
+
+func testWebhookConfig() *config.WebhookConfig {
+	return &config.WebhookConfig{
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Minute,
+		Timeout:      2 * time.Second,
+		PollInterval: time.Second,
+		BatchSize:    10,
+	}
+}
+
+func testDelivery(url string, attempts int) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:        "6f1c9a52-3f0e-4d8a-9b8e-2d4b1f0c7a11",
+		EventID:   "0b3f1d7e-8c2a-4e5f-9a6b-7c8d9e0f1a2b",
+		EventType: domain.EventSessionStarted,
+		Payload:   []byte(`{"type":"session.started","data":{"sessionId":"s-1"}}`),
+		Attempts:  attempts,
+		URL:       url,
+		Secret:    "whsec_test",
+	}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	type received struct {
+		signature, event, delivery string
+		body                       []byte
+	}
+	got := make(chan received, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{
+			signature: r.Header.Get(SignatureHeader),
+			event:     r.Header.Get(EventHeader),
+			delivery:  r.Header.Get(DeliveryHeader),
+			body:      body,
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := testDelivery(server.URL, 0)
+	repo := newFakeWebhookRepo(delivery)
+	dispatcher := NewWebhookDispatcher(repo, server.Client(), testWebhookConfig())
+
+	if _, err := dispatcher.DispatchDue(context.Background()); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	r := <-got
+	if string(r.body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", r.body, delivery.Payload)
+	}
+	if r.event != delivery.EventType || r.delivery != delivery.ID {
+		t.Errorf("event, delivery headers = %q, %q", r.event, r.delivery)
+	}
+
+	timestamp, signature, ok := strings.Cut(strings.TrimPrefix(r.signature, "t="), ",v1=")
+	if !ok {
+		t.Fatalf("malformed signature header %q", r.signature)
+	}
+	if want := SignPayload(delivery.Secret, timestamp, r.body); signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+
+	if len(repo.succeeded) != 1 || repo.succeeded[0] != delivery.ID {
+		t.Errorf("succeeded = %v, want [%s]", repo.succeeded, delivery.ID)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].Attempt != 1 || *repo.attempts[0].StatusCode != http.StatusNoContent {
+		t.Errorf("attempts = %+v, want one attempt answered with 204", repo.attempts)
+	}
+}
+
+func TestDispatcherRetriesFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := testWebhookConfig()
+	delivery := testDelivery(server.URL, 1)
+	repo := newFakeWebhookRepo(delivery)
+	dispatcher := NewWebhookDispatcher(repo, server.Client(), cfg)
+
+	before := time.Now()
+	if _, err := dispatcher.DispatchDue(context.Background()); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	next, ok := repo.retries[delivery.ID]
+	if !ok {
+		t.Fatalf("delivery was not scheduled for a retry, dead = %v", repo.dead)
+	}
+
+	// the second attempt failed, the third waits twice the base delay plus jitter
+	if wait := next.Sub(before); wait < 2*cfg.BaseBackoff || wait > 2*cfg.BaseBackoff*6/5+time.Second {
+		t.Errorf("retry in %v, want about %v", wait, 2*cfg.BaseBackoff)
+	}
+	if !strings.Contains(repo.lastErrors[delivery.ID], "503") {
+		t.Errorf("last error = %q, want the status code", repo.lastErrors[delivery.ID])
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].Attempt != 2 {
+		t.Errorf("attempts = %+v, want attempt 2", repo.attempts)
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := testWebhookConfig()
+	delivery := testDelivery(server.URL, cfg.MaxAttempts-1)
+	repo := newFakeWebhookRepo(delivery)
+	dispatcher := NewWebhookDispatcher(repo, server.Client(), cfg)
+
+	if _, err := dispatcher.DispatchDue(context.Background()); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	if _, ok := repo.dead[delivery.ID]; !ok {
+		t.Fatalf("delivery was not dead lettered, retries = %v", repo.retries)
+	}
+	if _, ok := repo.retries[delivery.ID]; ok {
+		t.Errorf("dead delivery was also scheduled for a retry")
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	var called atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer server.Close()
+
+	delivery := testDelivery(server.URL, 0)
+	repo := newFakeWebhookRepo(delivery)
+	// the default client, the test server listens on loopback
+	dispatcher := NewWebhookDispatcher(repo, nil, testWebhookConfig())
+
+	if _, err := dispatcher.DispatchDue(context.Background()); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	if called.Load() {
+		t.Errorf("receiver on a loopback address was called")
+	}
+	if !strings.Contains(repo.lastErrors[delivery.ID], "non-public") {
+		t.Errorf("last error = %q, want the address to be refused", repo.lastErrors[delivery.ID])
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/SarkiMudboy/easebox-api/internal/domain"
+	"github.com/SarkiMudboy/easebox-api/internal/repository"
+	"github.com/SarkiMudboy/easebox-api/internal/tenant"
+)
+
+// EventPublisher is notified of state changes in the tracking and delivery lifecycle
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType string, data interface{}) error
+}
+
+type WebhookService struct {
+	webhookRepo repository.WebhookRepository
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository) *WebhookService {
+	return &WebhookService{webhookRepo: webhookRepo}
+}
+
+// CreateEndpoint registers a receiver for the organization in ctx. The returned
+// endpoint carries the signing secret, which is not returned again.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, rawURL string, eventTypes []string) (*domain.WebhookEndpoint, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return nil, &domain.DomainError{Code: "INVALID_WEBHOOK_URL", Message: "url must be an absolute https url"}
+	}
+
+	// names are checked again when dialing, they may resolve anywhere
+	if !publicHost(parsed.Hostname()) {
+		return nil, &domain.DomainError{Code: "INVALID_WEBHOOK_URL", Message: "url must point at a public address"}
+	}
+
+	for _, eventType := range eventTypes {
+		if !knownEventType(eventType) {
+			return nil, &domain.DomainError{Code: "INVALID_EVENT_TYPE", Message: "unknown event type " + eventType}
+		}
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+
+	endpoint := &domain.WebhookEndpoint{
+		URL:        rawURL,
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+
+	if err := s.webhookRepo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context) ([]*domain.WebhookEndpoint, error) {
+	return s.webhookRepo.ListEndpoints(ctx)
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, endpointID string) error {
+	err := s.webhookRepo.DeleteEndpoint(ctx, endpointID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "WEBHOOK_ENDPOINT_NOT_FOUND", Message: "webhook endpoint does not exist"}
+	}
+	return err
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	return s.webhookRepo.ListDeliveries(ctx, endpointID, limit)
+}
+
+func (s *WebhookService) ListAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookAttempt, error) {
+	return s.webhookRepo.ListAttempts(ctx, deliveryID)
+}
+
+// RetryDelivery moves a dead lettered delivery back onto the queue. Its
+// attempts keep counting, so it is dead lettered again if the next one fails.
+func (s *WebhookService) RetryDelivery(ctx context.Context, deliveryID string) error {
+	err := s.webhookRepo.Retry(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &domain.DomainError{Code: "WEBHOOK_DELIVERY_NOT_FOUND", Message: "no dead webhook delivery with that id"}
+	}
+	return err
+}
+
+// Publish implements EventPublisher by queueing the event for every subscribed
+// endpoint of the organization in ctx
+func (s *WebhookService) Publish(ctx context.Context, eventType string, data interface{}) error {
+	organizationID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	event := &domain.Event{
+		ID:             newEventID(),
+		Type:           eventType,
+		OrganizationID: organizationID,
+		OccurredAt:     time.Now().UTC(),
+		Data:           data,
+	}
+
+	return s.Enqueue(ctx, event)
+}
+
+// Enqueue queues an already built event for delivery
+func (s *WebhookService) Enqueue(ctx context.Context, event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.Type, err)
+	}
+
+	return s.webhookRepo.Enqueue(ctx, event, payload)
+}
+
+func knownEventType(eventType string) bool {
+	for _, t := range domain.KnownEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// newEventID returns a random (version 4) UUID
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
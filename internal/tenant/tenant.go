@@ -0,0 +1,32 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMissingTenant is returned when tenant scoped data is accessed without an
+// organization in the context
+var ErrMissingTenant = errors.New("no organization in request context")
+
+type contextKey struct{}
+
+// WithOrganization returns a copy of ctx scoped to the given organization
+func WithOrganization(ctx context.Context, organizationID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, organizationID)
+}
+
+// FromContext returns the organization the context is scoped to
+func FromContext(ctx context.Context) (string, bool) {
+	organizationID, ok := ctx.Value(contextKey{}).(string)
+	return organizationID, ok && organizationID != ""
+}
+
+// Require is like FromContext but fails with ErrMissingTenant when no organization is set
+func Require(ctx context.Context) (string, error) {
+	organizationID, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissingTenant
+	}
+	return organizationID, nil
+}
@@ -1,6 +1,8 @@
 package env
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,7 +14,8 @@ import (
 func init() {
 	env := dir(".env.local")
 	err := godotenv.Load(env)
-	if err != nil {
+	// without the file every variable comes from the environment, as in tests
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatalf("Error loading environment variables: %s", err.Error())
 	}
 }
@@ -63,3 +66,16 @@ func GetInt(variable string, fallback int) int {
 	}
 	return intVal
 }
+
+func GetFloat(variable string, fallback float64) float64 {
+	value, ok := os.LookupEnv(variable)
+	if !ok {
+		return fallback
+	}
+
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback
+	}
+	return floatVal
+}
@@ -0,0 +1,118 @@
+package geo
+
+import "math"
+
+// EarthRadius is the mean earth radius in meters
+const EarthRadius = 6371008.8
+
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Haversine returns the great-circle distance between two points in meters
+func Haversine(a, b Point) float64 {
+	lat1, lat2 := toRadians(a.Latitude), toRadians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// PathLength sums the great-circle distance between consecutive points
+func PathLength(points []Point) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += Haversine(points[i-1], points[i])
+	}
+	return total
+}
+
+// ProjectToSegment projects p onto the segment a-b and returns the closest point on
+// the segment along with the fraction (0..1) of the way from a to b.
+// Uses a local equirectangular approximation, which is accurate for road-length segments.
+func ProjectToSegment(p, a, b Point) (Point, float64) {
+	cosLat := math.Cos(toRadians(p.Latitude))
+
+	ax, ay := a.Longitude*cosLat, a.Latitude
+	bx, by := b.Longitude*cosLat, b.Latitude
+	px, py := p.Longitude*cosLat, p.Latitude
+
+	dx, dy := bx-ax, by-ay
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return a, 0
+	}
+
+	t := ((px-ax)*dx + (py-ay)*dy) / lengthSq
+	t = math.Max(0, math.Min(1, t))
+
+	return Interpolate(a, b, t), t
+}
+
+// Interpolate returns the point at fraction t of the way from a to b
+func Interpolate(a, b Point, t float64) Point {
+	return Point{
+		Latitude:  a.Latitude + (b.Latitude-a.Latitude)*t,
+		Longitude: a.Longitude + (b.Longitude-a.Longitude)*t,
+	}
+}
+
+// BoundingBox returns the min/max corners of a box that contains every point
+// within radiusMeters of center
+func BoundingBox(center Point, radiusMeters float64) (min Point, max Point) {
+	dLat := radiusMeters / EarthRadius * 180 / math.Pi
+	dLon := dLat / math.Max(math.Cos(toRadians(center.Latitude)), 1e-6)
+
+	min = Point{Latitude: center.Latitude - dLat, Longitude: center.Longitude - dLon}
+	max = Point{Latitude: center.Latitude + dLat, Longitude: center.Longitude + dLon}
+	return
+}
+
+// Simplify reduces a path with the Douglas-Peucker algorithm, keeping every
+// point that deviates more than toleranceMeters from the simplified line. The
+// first and last points are always kept.
+func Simplify(points []Point, toleranceMeters float64) []Point {
+	if len(points) < 3 {
+		return append([]Point(nil), points...)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// explicit stack instead of recursion, routes can be long
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := -1, 0.0
+		for i := s.first + 1; i < s.last; i++ {
+			projected, _ := ProjectToSegment(points[i], points[s.first], points[s.last])
+			if d := Haversine(points[i], projected); d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+
+		if farthest != -1 && maxDistance > toleranceMeters {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	simplified := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
@@ -0,0 +1,354 @@
+// Package msgpack implements the subset of MessagePack needed to carry JSON
+// shaped values: nil, booleans, numbers, strings, binary, arrays and maps
+// with string keys. Decoded values use the same Go types as encoding/json
+// with UseNumber (integers become json.Number, floats float64), so they can be
+// re-marshalled into JSON structs without losing 64-bit precision.
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+var ErrTruncated = errors.New("msgpack: unexpected end of data")
+
+// maxDepth bounds nesting so hostile input cannot exhaust the stack
+const maxDepth = 32
+
+// Unmarshal decodes a single value that must span all of data
+func Unmarshal(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: nesting too deep")
+	}
+
+	head, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := head[0]
+
+	switch {
+	case c <= 0x7f:
+		return intNumber(int64(c)), nil
+	case c >= 0xe0:
+		return intNumber(int64(int8(c))), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.mapping(int(c&0x0f), depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend from the encoded width
+		shift := 64 - 8*size
+		return intNumber(int64(n<<shift) >> shift), nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(int(n), depth)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+func intNumber(n int64) json.Number {
+	return json.Number(strconv.FormatInt(n, 10))
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int, depth int) (interface{}, error) {
+	// every element takes at least a byte
+	if n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+
+	items := make([]interface{}, n)
+	for i := range items {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (d *decoder) mapping(n int, depth int) (interface{}, error) {
+	if 2*n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.New("msgpack: map keys must be strings")
+		}
+
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// Marshal encodes a JSON shaped value, as decoded by encoding/json with or
+// without UseNumber. Integers take the smallest format that holds them, and so
+// do floats without a fractional part; other floats are written as float64.
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.value(v, 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) head(c byte, n uint64, size int) {
+	e.buf = append(e.buf, c)
+	for i := size - 1; i >= 0; i-- {
+		e.buf = append(e.buf, byte(n>>(8*i)))
+	}
+}
+
+func (e *encoder) value(v interface{}, depth int) error {
+	if depth > maxDepth {
+		return errors.New("msgpack: nesting too deep")
+	}
+
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case float64:
+		e.float(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			e.int(n)
+		} else if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			e.head(0xcf, n, 8)
+		} else if f, err := v.Float64(); err == nil {
+			e.float(f)
+		} else {
+			return fmt.Errorf("msgpack: invalid number %q", v)
+		}
+	case string:
+		e.str(v)
+	case []byte:
+		e.length(len(v), 0, 0xc4, 0xc5, 0xc6)
+		e.buf = append(e.buf, v...)
+	case []interface{}:
+		e.length(len(v), 0x90, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := e.value(item, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		e.length(len(v), 0x80, 0, 0xde, 0xdf)
+		// sorted so equal values encode identically
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.str(k)
+			if err := e.value(v[k], depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", v)
+	}
+
+	return nil
+}
+
+func (e *encoder) float(v float64) {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+		e.int(int64(v))
+	} else {
+		e.head(0xcb, math.Float64bits(v), 8)
+	}
+}
+
+func (e *encoder) int(n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n < 0 && n >= -32:
+		e.buf = append(e.buf, byte(int8(n)))
+	case n >= 0 && n <= math.MaxUint8:
+		e.head(0xcc, uint64(n), 1)
+	case n >= 0 && n <= math.MaxUint16:
+		e.head(0xcd, uint64(n), 2)
+	case n >= 0 && n <= math.MaxUint32:
+		e.head(0xce, uint64(n), 4)
+	case n >= 0:
+		e.head(0xcf, uint64(n), 8)
+	case n >= math.MinInt8:
+		e.head(0xd0, uint64(uint8(n)), 1)
+	case n >= math.MinInt16:
+		e.head(0xd1, uint64(uint16(n)), 2)
+	case n >= math.MinInt32:
+		e.head(0xd2, uint64(uint32(n)), 4)
+	default:
+		e.head(0xd3, uint64(n), 8)
+	}
+}
+
+func (e *encoder) str(s string) {
+	switch n := len(s); {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.head(0xd9, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.head(0xda, uint64(n), 2)
+	default:
+		e.head(0xdb, uint64(n), 4)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+// length writes an array, map or binary header. fix is the fixed-size prefix
+// for up to 15 items, or 0 if the type has none.
+func (e *encoder) length(n int, fix, c8, c16, c32 byte) {
+	switch {
+	case fix != 0 && n <= 15:
+		e.buf = append(e.buf, fix|byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		e.head(c8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.head(c16, uint64(n), 2)
+	default:
+		e.head(c32, uint64(n), 4)
+	}
+}
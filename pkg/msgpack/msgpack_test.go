@@ -0,0 +1,149 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{"nil", nil, nil},
+		{"true", true, true},
+		{"false", false, false},
+		{"positive fixint", 7, json.Number("7")},
+		{"negative fixint", -5, json.Number("-5")},
+		{"uint8", 200, json.Number("200")},
+		{"uint16", 60000, json.Number("60000")},
+		{"uint32", int64(4000000000), json.Number("4000000000")},
+		{"int8", -100, json.Number("-100")},
+		{"int16", -30000, json.Number("-30000")},
+		{"int32", -2000000000, json.Number("-2000000000")},
+		{"max int64", int64(math.MaxInt64), json.Number("9223372036854775807")},
+		{"min int64", int64(math.MinInt64), json.Number("-9223372036854775808")},
+		{"whole float", 1700000000000.0, json.Number("1700000000000")},
+		{"float", 51.5074, 51.5074},
+		{"number int", json.Number("9007199254740993"), json.Number("9007199254740993")},
+		{"number uint64", json.Number("18446744073709551615"), json.Number("18446744073709551615")},
+		{"number float", json.Number("-0.1278"), -0.1278},
+		{"string", "abc", "abc"},
+		{"str8", string(bytes.Repeat([]byte("a"), 40)), string(bytes.Repeat([]byte("a"), 40))},
+		{"str16", string(bytes.Repeat([]byte("b"), 300)), string(bytes.Repeat([]byte("b"), 300))},
+		{"binary", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"array", []interface{}{1, "x", nil}, []interface{}{json.Number("1"), "x", nil}},
+		{
+			"map",
+			map[string]interface{}{"sessionId": "s-1", "latitude": 6.5244, "id": json.Number("123")},
+			map[string]interface{}{"sessionId": "s-1", "latitude": 6.5244, "id": json.Number("123")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.in)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round trip = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// values decoded by encoding/json with UseNumber keep every digit
+func TestLargeIntegersSurviveJSON(t *testing.T) {
+	raw := []byte(`{"createdAt":1700000000123,"id":9007199254740993}`)
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Marshal(value)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	back, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, raw) {
+		t.Errorf("got %s, want %s", back, raw)
+	}
+}
+
+func TestUnmarshalRejectsMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":          {},
+		"truncated str":  {0xa5, 'a'},
+		"truncated int":  {0xcd, 0x01},
+		"huge array":     {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"non string key": {0x81, 0x01, 0x02},
+		"trailing bytes": {0xc0, 0xc0},
+		"unsupported":    {0xc1},
+		"too deep":       append(bytes.Repeat([]byte{0x91}, maxDepth+2), 0xc0),
+	}
+
+	for name, data := range tests {
+		if _, err := Unmarshal(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, seed := range []interface{}{
+		nil,
+		map[string]interface{}{"type": "location", "data": map[string]interface{}{"latitude": 6.5, "longitude": 3.4}},
+		[]interface{}{json.Number("-1"), json.Number("18446744073709551615"), 1.25, "x", []byte{0}},
+	} {
+		data, err := Marshal(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, err := Unmarshal(data)
+		if err != nil {
+			return
+		}
+
+		// whatever decodes must encode, and encode the same way again
+		encoded, err := Marshal(value)
+		if err != nil {
+			t.Fatalf("Marshal of decoded value: %v", err)
+		}
+		again, err := Unmarshal(encoded)
+		if err != nil {
+			t.Fatalf("Unmarshal of re-encoded value: %v", err)
+		}
+		reencoded, err := Marshal(again)
+		if err != nil {
+			t.Fatalf("Marshal of re-decoded value: %v", err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("encoding is not stable: %x then %x", encoded, reencoded)
+		}
+	})
+}